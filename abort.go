@@ -0,0 +1,40 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"fmt"
+	"os"
+	"slices"
+)
+
+// ErrAborted is the error returned by all operations on a transaction that is
+// aborted through Database.AbortTransaction. It wraps os.ErrClosed.
+var ErrAborted = fmt.Errorf("transaction is aborted: %w", os.ErrClosed)
+
+// AbortTransaction aborts the live transaction with the input id, which is
+// typically found through LiveTransactions. Aborted transaction stops holding
+// back the compaction of older versions immediately. All further operations on
+// the transaction, including Commit, fail with an error wrapping ErrAborted,
+// but Rollback remains a no-op.
+//
+// Returns an error wrapping os.ErrNotExist if no live transaction has the
+// input id. Transactions that have already passed the commit validation can
+// no longer be aborted and are reported as not existing.
+func (d *Database) AbortTransaction(id uint64) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	i := slices.IndexFunc(d.liveTxes, func(t *Transaction) bool { return t.id == id })
+	if i < 0 || d.liveTxes[i].committed {
+		return fmt.Errorf("live transaction %d: %w", id, os.ErrNotExist)
+	}
+	t := d.liveTxes[i]
+
+	t.aborted.Store(true)
+	d.removeTransactionLocked(t)
+	for peer, txes := range d.concurrentMap {
+		d.concurrentMap[peer] = slices.DeleteFunc(txes, func(v *Transaction) bool { return v == t })
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestAbortTransaction(t *testing.T) {
+	ctx := context.Background()
+
+	db := New()
+	mustSet(ctx, t, db, "key", "v1")
+
+	// A stuck transaction pins the v1 version of the key.
+	stuck, _ := db.NewTransaction(ctx)
+	defer stuck.Rollback(ctx)
+	mustGet(ctx, t, stuck, "key")
+	stuck.Set(ctx, "other", strings.NewReader("value"))
+
+	peer, _ := db.NewTransaction(ctx)
+	defer peer.Rollback(ctx)
+
+	for _, v := range []string{"v2", "v3", "v4"} {
+		mustSet(ctx, t, db, "key", v)
+	}
+	if mv, _ := db.kvs.Load("key"); len(mv.Values()) < 2 {
+		t.Fatalf("versions %v are compacted while a transaction is live", mv.Values())
+	}
+
+	if err := db.AbortTransaction(stuck.ID()); err != nil {
+		t.Fatal(err)
+	}
+	for _, tx := range db.LiveTransactions() {
+		if tx.ID == stuck.ID() {
+			t.Errorf("aborted transaction is still live")
+		}
+	}
+	for _, txes := range db.concurrentMap {
+		for _, tx := range txes {
+			if tx == stuck {
+				t.Errorf("aborted transaction is still in a concurrent transactions list")
+			}
+		}
+	}
+
+	// All operations on the aborted transaction fail.
+	if _, err := stuck.Get(ctx, "key"); !errors.Is(err, ErrAborted) {
+		t.Errorf("Get on an aborted tx = %v, want ErrAborted", err)
+	}
+	if err := stuck.Set(ctx, "key", strings.NewReader("value")); !errors.Is(err, ErrAborted) {
+		t.Errorf("Set on an aborted tx = %v, want ErrAborted", err)
+	}
+	if err := stuck.Commit(ctx); !errors.Is(err, ErrAborted) || !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Commit on an aborted tx = %v, want ErrAborted wrapping os.ErrClosed", err)
+	}
+	if err := stuck.Rollback(ctx); err != nil {
+		t.Errorf("Rollback on an aborted tx = %v, want nil", err)
+	}
+	if s := stuck.State(); s != TxRolledBack {
+		t.Errorf("aborted tx state = %v, want %v", s, TxRolledBack)
+	}
+
+	// Compaction is no longer held back by the aborted transaction. Only the
+	// v4 version visible to the committing transaction is retained.
+	peer.Rollback(ctx)
+	mustSet(ctx, t, db, "key", "v5")
+	if mv, _ := db.kvs.Load("key"); mv.Values()[0].Data() != "v4" {
+		t.Errorf("versions %v are not compacted after the abort", mv.Values())
+	}
+
+	// Unknown and closed transactions.
+	if err := db.AbortTransaction(stuck.ID()); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("AbortTransaction on an aborted tx = %v, want os.ErrNotExist", err)
+	}
+	if err := db.AbortTransaction(1 << 60); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("AbortTransaction on an unknown tx = %v, want os.ErrNotExist", err)
+	}
+	closed, _ := db.NewTransaction(ctx)
+	closed.Rollback(ctx)
+	if err := db.AbortTransaction(closed.ID()); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("AbortTransaction on a closed tx = %v, want os.ErrNotExist", err)
+	}
+
+	// Transactions that are validated for commit cannot be aborted.
+	p := pauseAt(db, hookCommitApply)[hookCommitApply]
+	defer p.resume()
+
+	committing, _ := db.NewTransaction(ctx)
+	committing.Set(ctx, "key", strings.NewReader("v6"))
+	errc := make(chan error, 1)
+	go func() { errc <- committing.Commit(ctx) }()
+	<-p.reached
+	if err := db.AbortTransaction(committing.ID()); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("AbortTransaction on a committing tx = %v, want os.ErrNotExist", err)
+	}
+	p.resume()
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAbortTransactionDuringCommit(t *testing.T) {
+	ctx := context.Background()
+
+	db := New()
+
+	blocker, _ := db.NewTransaction(ctx)
+	blocker.Set(ctx, "key", strings.NewReader("value"))
+	tx, _ := db.NewTransaction(ctx)
+	tx.Set(ctx, "other", strings.NewReader("value"))
+
+	// Transaction is aborted after Commit has started, while another commit
+	// is holding the database mutex.
+	p := pauseAt(db, hookCommitValidate)[hookCommitValidate]
+	defer p.resume()
+
+	blocked := make(chan error, 1)
+	go func() { blocked <- blocker.Commit(ctx) }()
+	<-p.reached
+
+	errc := make(chan error, 1)
+	go func() { errc <- tx.Commit(ctx) }()
+
+	// Abort and the tx validation both wait for the database mutex held by
+	// the paused validation, so they can be ordered either way.
+	aborted := make(chan error, 1)
+	go func() { aborted <- db.AbortTransaction(tx.ID()) }()
+	p.resume()
+	if err := <-blocked; err != nil {
+		t.Fatal(err)
+	}
+
+	abortErr, commitErr := <-aborted, <-errc
+	if (abortErr == nil) == (commitErr == nil) {
+		t.Fatalf("exactly one of abort (%v) and commit (%v) must succeed", abortErr, commitErr)
+	}
+	if abortErr == nil && !errors.Is(commitErr, ErrAborted) {
+		t.Errorf("Commit of an aborted tx = %v, want ErrAborted", commitErr)
+	}
+	if h := db.Health(); h.LiveTransactions != 0 {
+		t.Errorf("Health = %+v, want no live transactions", h)
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/visvasity/kvmemdb/mvcc"
+)
+
+// Absorb merges the updates and the reads of the child transaction into the
+// transaction, as if they were performed by the transaction itself, and rolls
+// back the child. Updates staged by the child replace the updates staged by
+// the transaction for the same keys. Keys and ranges read by the child are
+// validated for conflicts when the transaction commits.
+//
+// Child must be an active transaction on the same database. When the child
+// was created at a different version, the keys and ranges it read must be
+// unchanged between the two versions; otherwise, an error is returned and
+// both transactions are left unchanged. A child without read tracking
+// disables the read tracking of the transaction.
+func (t *Transaction) Absorb(ctx context.Context, child *Transaction) error {
+	if err := t.check(); err != nil {
+		return err
+	}
+	if child == nil || child == t {
+		return fmt.Errorf("child must be another transaction: %w", os.ErrInvalid)
+	}
+	if err := child.check(); err != nil {
+		return fmt.Errorf("child tx %d: %w", child.id, err)
+	}
+	if child.db != t.db {
+		return fmt.Errorf("child tx %d must be on the same database: %w", child.id, os.ErrInvalid)
+	}
+	if ks := t.changedReads(child); len(ks) > 0 {
+		return fmt.Errorf("ssi: keys %v read by the child tx %d differ at this tx read version", ks, child.id)
+	}
+
+	for key := range child.writes {
+		if !t.db.hiddenKey(key) {
+			t.discardStagedChunks(key, child.writes)
+		}
+	}
+	for key, value := range child.writes {
+		t.stage(key, value)
+	}
+	for key := range child.sequences {
+		if t.sequences == nil {
+			t.sequences = make(map[string]struct{})
+		}
+		t.sequences[key] = struct{}{}
+	}
+	if !child.trackReads {
+		t.trackReads = false
+	}
+	for key, value := range child.reads {
+		if _, ok := t.reads[key]; ok {
+			continue
+		}
+		// Read values are identical at both versions, but the value visible
+		// at this transaction's version is kept for consistency.
+		if child.snapshotVersion != t.snapshotVersion {
+			value = t.db.fetch(key, t.snapshotVersion)
+		}
+		t.reads[key] = value
+		t.numReads.Add(1)
+		t.watch(key)
+	}
+	t.scanRanges = append(t.scanRanges, child.scanRanges...)
+	t.lockedRanges = append(t.lockedRanges, child.lockedRanges...)
+
+	return child.Rollback(ctx)
+}
+
+// changedReads returns the keys read by the child transaction, including the
+// keys in its scanned ranges, whose values differ between the snapshot
+// versions of the two transactions, and the keys in its locked ranges that
+// exist at only one of the two versions.
+func (t *Transaction) changedReads(child *Transaction) []string {
+	if child.snapshotVersion == t.snapshotVersion {
+		return nil
+	}
+	changed := func(key string) bool {
+		return !sameValue(t.db.fetch(key, child.snapshotVersion), t.db.fetch(key, t.snapshotVersion))
+	}
+
+	var keys []string
+	for key := range child.reads {
+		if changed(key) {
+			keys = append(keys, key)
+		}
+	}
+	if len(child.scanRanges) == 0 && len(child.lockedRanges) == 0 {
+		return keys
+	}
+	live := func(v *mvcc.Value) bool { return v != nil && !v.IsDeleted() }
+	inRanges := func(ranges []Range, key string) bool {
+		return slices.ContainsFunc(ranges, func(r Range) bool { return r.contains(key) })
+	}
+	for key := range t.db.rangeKeys {
+		if _, ok := child.reads[key]; ok {
+			continue
+		}
+		if inRanges(child.scanRanges, key) && changed(key) {
+			keys = append(keys, key)
+			continue
+		}
+		if inRanges(child.lockedRanges, key) && live(t.db.fetch(key, child.snapshotVersion)) != live(t.db.fetch(key, t.snapshotVersion)) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// sameValue returns true if both values are absent or if both are the same
+// version of the key.
+func sameValue(a, b *mvcc.Value) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Version() == b.Version() && a.IsDeleted() == b.IsDeleted()
+}
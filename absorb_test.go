@@ -0,0 +1,143 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestAbsorb(t *testing.T) {
+	ctx := context.Background()
+
+	db := New()
+	mustSet(ctx, t, db, "a", "1")
+	mustSet(ctx, t, db, "b", "2")
+
+	parent, _ := db.NewTransaction(ctx)
+	defer parent.Rollback(ctx)
+	child, _ := db.NewTransaction(ctx)
+	defer child.Rollback(ctx)
+
+	parent.Set(ctx, "x", strings.NewReader("parent"))
+	parent.Set(ctx, "y", strings.NewReader("parent"))
+	child.Set(ctx, "y", strings.NewReader("child"))
+	child.Delete(ctx, "b")
+	mustGet(ctx, t, child, "a")
+
+	if err := parent.Absorb(ctx, child); err != nil {
+		t.Fatal(err)
+	}
+	if s := child.State(); s != TxRolledBack {
+		t.Errorf("child state: want %v, got %v", TxRolledBack, s)
+	}
+	if err := child.Set(ctx, "z", strings.NewReader("z")); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Set on the absorbed child: want os.ErrClosed, got %v", err)
+	}
+	if err := parent.Absorb(ctx, child); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("absorbing a closed child: want os.ErrClosed, got %v", err)
+	}
+	if err := parent.Absorb(ctx, parent); !errors.Is(err, os.ErrInvalid) {
+		t.Errorf("absorbing itself: want os.ErrInvalid, got %v", err)
+	}
+	if err := parent.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	snap, _ := db.NewSnapshot(ctx)
+	defer snap.Discard(ctx)
+	ascend, _ := readAll(ctx, t, snap, "", "")
+	if want := "a=1 x=parent y=child"; strings.Join(ascend, " ") != want {
+		t.Errorf("committed state: want %q, got %q", want, ascend)
+	}
+
+	other, _ := New().NewTransaction(ctx)
+	defer other.Rollback(ctx)
+	tx, _ := db.NewTransaction(ctx)
+	defer tx.Rollback(ctx)
+	if err := tx.Absorb(ctx, other); !errors.Is(err, os.ErrInvalid) {
+		t.Errorf("absorbing a tx from another database: want os.ErrInvalid, got %v", err)
+	}
+}
+
+func TestAbsorbConflicts(t *testing.T) {
+	ctx := context.Background()
+
+	for _, test := range []struct {
+		name        string
+		readByChild bool
+	}{
+		{"parent reads", false},
+		{"child reads", true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			db := New()
+			mustSet(ctx, t, db, "a", "1")
+			mustSet(ctx, t, db, "b", "2")
+
+			parent, _ := db.NewTransaction(ctx)
+			defer parent.Rollback(ctx)
+			child, _ := db.NewTransaction(ctx)
+			defer child.Rollback(ctx)
+
+			if test.readByChild {
+				mustGet(ctx, t, child, "a")
+			} else {
+				mustGet(ctx, t, parent, "a")
+			}
+			child.Set(ctx, "c", strings.NewReader("3"))
+			if err := parent.Absorb(ctx, child); err != nil {
+				t.Fatal(err)
+			}
+
+			mustSet(ctx, t, db, "a", "updated")
+			if err := parent.Commit(ctx); err == nil {
+				t.Errorf("commit after a concurrent update to a read key: want a conflict")
+			}
+		})
+	}
+
+	// Reads of a child at a different version must match the parent's version.
+	db := New()
+	mustSet(ctx, t, db, "a", "1")
+
+	child, _ := db.NewTransaction(ctx)
+	defer child.Rollback(ctx)
+	mustGet(ctx, t, child, "a")
+
+	mustSet(ctx, t, db, "a", "2")
+	mustSet(ctx, t, db, "b", "2")
+
+	parent, _ := db.NewTransaction(ctx)
+	defer parent.Rollback(ctx)
+	if err := parent.Absorb(ctx, child); err == nil || child.State() != TxActive {
+		t.Errorf("absorbing a stale read: want an error with an active child, got %v and %v", err, child.State())
+	}
+
+	// Ranges scanned by the child are validated the same way.
+	child2, _ := db.NewTransaction(ctx)
+	defer child2.Rollback(ctx)
+	if _, err := child2.CountPhantomSafe(ctx, "b", "c"); err != nil {
+		t.Fatal(err)
+	}
+	mustSet(ctx, t, db, "b0", "new")
+
+	parent2, _ := db.NewTransaction(ctx)
+	defer parent2.Rollback(ctx)
+	if err := parent2.Absorb(ctx, child2); err == nil {
+		t.Errorf("absorbing a stale range scan: want an error")
+	}
+
+	child3, _ := db.NewTransaction(ctx)
+	defer child3.Rollback(ctx)
+	mustGet(ctx, t, child3, "a")
+	mustSet(ctx, t, db, "c", "unrelated")
+	parent3, _ := db.NewTransaction(ctx)
+	defer parent3.Rollback(ctx)
+	if err := parent3.Absorb(ctx, child3); err != nil {
+		t.Errorf("absorbing unchanged reads at an older version: %v", err)
+	}
+}
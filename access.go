@@ -0,0 +1,69 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// KeyInfo holds the last access times of a key. Zero time values indicate
+// that the key was not read or written since access tracking is enabled.
+type KeyInfo struct {
+	LastRead  time.Time
+	LastWrite time.Time
+}
+
+// keyAccess holds the last access times of a key in unix nanoseconds.
+type keyAccess struct {
+	lastRead  atomic.Int64
+	lastWrite atomic.Int64
+}
+
+// KeyInfo returns the last access times for a live key. Returns false if
+// access tracking is not enabled or the key doesn't exist.
+func (d *Database) KeyInfo(key string) (KeyInfo, bool) {
+	if d.access == nil {
+		return KeyInfo{}, false
+	}
+	a, ok := d.access.Load(key)
+	if !ok {
+		return KeyInfo{}, false
+	}
+	var info KeyInfo
+	if ns := a.lastRead.Load(); ns != 0 {
+		info.LastRead = time.Unix(0, ns)
+	}
+	if ns := a.lastWrite.Load(); ns != 0 {
+		info.LastWrite = time.Unix(0, ns)
+	}
+	return info, true
+}
+
+// touchRead records the current time as the last read time for a key, if
+// access tracking is enabled.
+func (d *Database) touchRead(key string) {
+	if d.access == nil {
+		return
+	}
+	if a, ok := d.access.Load(key); ok {
+		a.lastRead.Store(d.now().UnixNano())
+	}
+}
+
+// touchWrite records the current time as the last write time for a key, if
+// access tracking is enabled. Access information is dropped for deleted keys.
+func (d *Database) touchWrite(key string, deleted bool) {
+	if d.access == nil {
+		return
+	}
+	if deleted {
+		d.access.Delete(key)
+		return
+	}
+	a, ok := d.access.Load(key)
+	if !ok {
+		a, _ = d.access.LoadOrStore(key, new(keyAccess))
+	}
+	a.lastWrite.Store(d.now().UnixNano())
+}
@@ -0,0 +1,80 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeClock is a manually advanced clock for tests.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func TestAccessTracking(t *testing.T) {
+	ctx := context.Background()
+
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	db := New(WithClock(clock.Now), WithAccessTracking())
+
+	if _, ok := db.KeyInfo("key"); ok {
+		t.Fatalf("KeyInfo must fail for a missing key")
+	}
+
+	mustSet(ctx, t, db, "key", "value")
+	written := clock.Now()
+
+	info, ok := db.KeyInfo("key")
+	if !ok {
+		t.Fatalf("KeyInfo failed for a live key")
+	}
+	if !info.LastWrite.Equal(written) || !info.LastRead.IsZero() {
+		t.Errorf("KeyInfo = %+v, want only LastWrite at %v", info, written)
+	}
+
+	clock.Advance(time.Minute)
+	snap, _ := db.NewSnapshot(ctx)
+	mustGet(ctx, t, snap, "key")
+	snap.Discard(ctx)
+	read := clock.Now()
+
+	clock.Advance(time.Minute)
+	tx, _ := db.NewTransaction(ctx)
+	tx.Set(ctx, "other", strings.NewReader("value"))
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	info, _ = db.KeyInfo("key")
+	if !info.LastWrite.Equal(written) || !info.LastRead.Equal(read) {
+		t.Errorf("KeyInfo = %+v, want LastWrite %v and LastRead %v", info, written, read)
+	}
+
+	tx, _ = db.NewTransaction(ctx)
+	mustGet(ctx, t, tx, "key")
+	tx.Delete(ctx, "key")
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := db.KeyInfo("key"); ok {
+		t.Errorf("KeyInfo must fail for a deleted key")
+	}
+
+	// Access tracking is disabled by default.
+	plain := New()
+	mustSet(ctx, t, plain, "key", "value")
+	if _, ok := plain.KeyInfo("key"); ok {
+		t.Errorf("KeyInfo must fail when access tracking is disabled")
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"hash/maphash"
+	"math"
+	"sync/atomic"
+
+	"github.com/visvasity/kvmemdb/mvcc"
+)
+
+const (
+	// defaultBloomKeys and defaultBloomFalsePositiveRate are used for the
+	// bloom filter parameters that are not configured.
+	defaultBloomKeys              = 1024
+	defaultBloomFalsePositiveRate = 0.01
+)
+
+// bloomFilter is a set of keys with false positives, but no false negatives.
+// Keys can be added and looked up concurrently.
+type bloomFilter struct {
+	seed   maphash.Seed
+	bits   []atomic.Uint64
+	hashes uint64
+
+	// capacity is the number of keys for which the filter is sized and count
+	// is the number of keys added when the filter is built.
+	capacity, count int
+}
+
+// newBloomFilter returns a filter sized for nkeys keys with the input false
+// positive rate.
+func newBloomFilter(nkeys int, fpr float64) *bloomFilter {
+	nkeys = max(nkeys, 1)
+	bitsPerKey := -math.Log(fpr) / (math.Ln2 * math.Ln2)
+	nbits := int(math.Ceil(float64(nkeys) * bitsPerKey))
+	return &bloomFilter{
+		seed:     maphash.MakeSeed(),
+		bits:     make([]atomic.Uint64, (nbits+63)/64),
+		hashes:   uint64(max(1, math.Round(bitsPerKey*math.Ln2))),
+		capacity: nkeys,
+	}
+}
+
+// positions calls fn with the bit positions for the key, using the double
+// hashing scheme.
+func (f *bloomFilter) positions(key string, fn func(word int, mask uint64) bool) bool {
+	h := maphash.String(f.seed, key)
+	h1, h2 := h, h>>33|1
+	nbits := uint64(len(f.bits)) * 64
+	for i := uint64(0); i < f.hashes; i++ {
+		pos := (h1 + i*h2) % nbits
+		if !fn(int(pos/64), 1<<(pos%64)) {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *bloomFilter) add(key string) {
+	f.positions(key, func(word int, mask uint64) bool {
+		f.bits[word].Or(mask)
+		return true
+	})
+}
+
+// mayContain returns false if the key is definitely not in the filter.
+func (f *bloomFilter) mayContain(key string) bool {
+	return f.positions(key, func(word int, mask uint64) bool {
+		return f.bits[word].Load()&mask != 0
+	})
+}
+
+// loadKey returns the multi-value for the key, skipping the lookup when the
+// key is definitely not in the database.
+func (d *Database) loadKey(key string) (*mvcc.MultiValue, bool) {
+	if f := d.bloom.Load(); f != nil && !f.mayContain(key) {
+		return nil, false
+	}
+	return d.kvs.Load(key)
+}
+
+// addKey adds a key to the bloom filter, if any. It must be called before a
+// live value for the key is stored in the database, while holding its shard
+// lock.
+func (d *Database) addKey(key string) {
+	if f := d.bloom.Load(); f != nil {
+		f.add(key)
+	}
+}
+
+// maybeRebuildBloom rebuilds the bloom filter when enough keys are deleted
+// since the last rebuild, because deleted keys cannot be removed from a bloom
+// filter, or when more keys are added than the filter is sized for. Caller
+// must not hold any shard locks.
+func (d *Database) maybeRebuildBloom() {
+	f := d.bloom.Load()
+	if f == nil {
+		return
+	}
+	deleted := d.bloomDeletes.Load() > int64(f.capacity/2)
+	full := int64(f.count)+d.bloomAdds.Load() > int64(f.capacity)
+	if !deleted && !full {
+		return
+	}
+	if !d.bloomRebuilding.CompareAndSwap(false, true) {
+		return
+	}
+	defer d.bloomRebuilding.Store(false)
+
+	// All shards are locked so that no new keys are added to the old filter
+	// while the new filter is built.
+	for i := range d.shards {
+		d.shards[i].Lock()
+	}
+	defer func() {
+		for i := range d.shards {
+			d.shards[i].Unlock()
+		}
+	}()
+
+	// Keys deleted at or before the smallest version readable by the live
+	// and the future readers are invisible to all of them.
+	d.mu.Lock()
+	minVersion := min(d.minVersionLocked(), d.maxCommitVersion.Load())
+	d.mu.Unlock()
+
+	var keys []string
+	for key, mv := range d.kvs.Range {
+		vs := mv.Values()
+		if latest := vs[len(vs)-1]; latest.IsDeleted() && latest.Version() <= minVersion {
+			continue
+		}
+		keys = append(keys, key)
+	}
+
+	// Filter is sized with room for as many new keys as it has.
+	nf := newBloomFilter(max(d.bloomKeys, 2*len(keys)), d.bloomFPR)
+	for _, key := range keys {
+		nf.add(key)
+	}
+	nf.count = len(keys)
+	d.bloom.Store(nf)
+	d.bloomDeletes.Store(0)
+	d.bloomAdds.Store(0)
+}
@@ -0,0 +1,175 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestKeyBloomFilter(t *testing.T) {
+	ctx := context.Background()
+
+	const nkeys = 100
+	key := func(i int) string { return fmt.Sprintf("key%03d", i) }
+
+	db := New(WithKeyBloomFilter(nkeys))
+	tx, _ := db.NewTransaction(ctx)
+	for i := 0; i < nkeys; i++ {
+		tx.Set(ctx, key(i), strings.NewReader("value"))
+	}
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Old snapshot keeps the deleted keys readable across filter rebuilds.
+	old, _ := db.NewSnapshot(ctx)
+	defer old.Discard(ctx)
+
+	filter := db.bloom.Load()
+	tx, _ = db.NewTransaction(ctx)
+	for i := 0; i < nkeys*3/4; i++ {
+		tx.Delete(ctx, key(i))
+	}
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if db.bloom.Load() == filter {
+		t.Fatalf("bloom filter is not rebuilt after deleting most keys")
+	}
+	for i := 0; i < nkeys; i++ {
+		mustGet(ctx, t, old, key(i))
+	}
+
+	// Keys deleted before all readers are dropped from the rebuilt filter.
+	old.Discard(ctx)
+	tx, _ = db.NewTransaction(ctx)
+	for i := nkeys * 3 / 4; i < nkeys; i++ {
+		tx.Delete(ctx, key(i))
+	}
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// Rebuild is triggered by the deletions in the next transaction.
+	tx, _ = db.NewTransaction(ctx)
+	for i := 0; i < nkeys; i++ {
+		tx.Delete(ctx, fmt.Sprintf("other%03d", i))
+	}
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	filter = db.bloom.Load()
+	misses := 0
+	for i := 0; i < nkeys; i++ {
+		if !filter.mayContain(key(i)) {
+			misses++
+		}
+	}
+	if misses < nkeys*9/10 {
+		t.Errorf("rebuilt filter skips %d of %d deleted keys, want most of them", misses, nkeys)
+	}
+
+	// Deleted keys can be recreated after they are dropped from the filter.
+	for i := 0; i < nkeys; i++ {
+		mustSet(ctx, t, db, key(i), "recreated")
+	}
+	snap, _ := db.NewSnapshot(ctx)
+	defer snap.Discard(ctx)
+	for i := 0; i < nkeys; i++ {
+		if got := mustGet(ctx, t, snap, key(i)); got != "recreated" {
+			t.Errorf("%s = %q, want recreated", key(i), got)
+		}
+	}
+	if _, err := snap.Get(ctx, "missing"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Get on a missing key = %v, want os.ErrNotExist", err)
+	}
+
+	// Keys loaded from a map are in the filter.
+	fdb, err := FromMap(ctx, map[string][]byte{"a": []byte("1")}, WithKeyBloomFilter(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ftx, _ := fdb.NewTransaction(ctx)
+	defer ftx.Rollback(ctx)
+	mustGet(ctx, t, ftx, "a")
+}
+
+func TestBloomFilterGrowth(t *testing.T) {
+	ctx := context.Background()
+
+	const nkeys = 20000
+	db := New(WithBloomFilter(0.01))
+	for i := 0; i < nkeys; i += 1000 {
+		tx, _ := db.NewTransaction(ctx)
+		for j := i; j < i+1000; j++ {
+			tx.Set(ctx, fmt.Sprintf("key%05d", j), strings.NewReader("value"))
+		}
+		if err := tx.Commit(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f := db.bloom.Load()
+	if f.capacity < nkeys {
+		t.Errorf("filter capacity = %d, want at least %d keys", f.capacity, nkeys)
+	}
+	snap, _ := db.NewSnapshot(ctx)
+	defer snap.Discard(ctx)
+	for i := 0; i < nkeys; i += 97 {
+		mustGet(ctx, t, snap, fmt.Sprintf("key%05d", i))
+	}
+
+	// False positive rate is close to the configured rate.
+	positives := 0
+	for i := 0; i < nkeys; i++ {
+		if f.mayContain(fmt.Sprintf("miss%05d", i)) {
+			positives++
+		}
+	}
+	if rate := float64(positives) / nkeys; rate > 0.03 {
+		t.Errorf("false positive rate = %.3f, want about 0.01", rate)
+	}
+}
+
+func BenchmarkGetMiss(b *testing.B) {
+	ctx := context.Background()
+
+	const nkeys = 100000
+	m := make(map[string][]byte, nkeys)
+	for i := 0; i < nkeys; i++ {
+		m[fmt.Sprintf("key/%08d", i)] = []byte("value")
+	}
+
+	for _, bench := range []struct {
+		name string
+		opts []Option
+	}{
+		{"default", nil},
+		{"bloom", []Option{WithKeyBloomFilter(nkeys)}},
+		{"bloom-fpr", []Option{WithBloomFilter(0.001)}},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			db, err := FromMap(ctx, m, bench.opts...)
+			if err != nil {
+				b.Fatal(err)
+			}
+			snap, _ := db.NewSnapshot(ctx)
+			defer snap.Discard(ctx)
+
+			misses := make([]string, 1024)
+			for i := range misses {
+				misses[i] = fmt.Sprintf("miss/%08d", i)
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := snap.Get(ctx, misses[i%len(misses)]); err == nil {
+					b.Fatal("missing key is found")
+				}
+			}
+		})
+	}
+}
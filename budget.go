@@ -0,0 +1,66 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"context"
+	"io"
+	"iter"
+	"sort"
+	"strings"
+)
+
+// AscendBudget is similar to Ascend, but stops before the key-value pair whose
+// value would make the total size of the yielded values larger than maxBytes,
+// so that the yielded pairs fit in a response of bounded size. First pair is
+// always yielded, even when its value alone is larger than maxBytes, so that
+// a paginated scan always makes progress.
+//
+// Scan can be resumed after the last yielded key with ResumeKey as the begin
+// key, using the same snapshot for a consistent view of the range. Range is
+// exhausted when a resumed scan yields no pairs.
+func (s *Snapshot) AscendBudget(ctx context.Context, begin, end string, maxBytes int64, errp *error) iter.Seq2[string, io.Reader] {
+	return func(yield func(string, io.Reader) bool) {
+		*errp = nil
+		if err := checkRange(begin, end); err != nil {
+			*errp = err
+			return
+		}
+		s.recordRange(begin, end)
+
+		keys := s.keys(begin, end)
+		sort.Strings(keys)
+
+		var total int64
+		first := true
+		for _, key := range keys {
+			v := s.fetch(key)
+			if err := s.check(); err != nil {
+				*errp = err
+				return
+			}
+			if v == nil || v.IsDeleted() {
+				continue
+			}
+			data, err := s.value(key, v.Data())
+			if err != nil {
+				*errp = err
+				return
+			}
+			total += int64(len(data))
+			if total > maxBytes && !first {
+				return
+			}
+			first = false
+			if !yield(key, strings.NewReader(data)) {
+				return
+			}
+		}
+	}
+}
+
+// ResumeKey returns the smallest key that is larger than the input key, which
+// can be used as the begin key to resume a scan after the input key.
+func ResumeKey(key string) string {
+	return key + "\x00"
+}
@@ -0,0 +1,59 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAscendBudget(t *testing.T) {
+	ctx := context.Background()
+
+	db := New()
+	for key, value := range map[string]string{"a": "123", "b": "45", "c": "6789012", "d": "3", "e": "45", "z": "x"} {
+		mustSet(ctx, t, db, key, value)
+	}
+	tx, _ := db.NewTransaction(ctx)
+	tx.Delete(ctx, "d")
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	snap, _ := db.NewSnapshot(ctx)
+	defer snap.Discard(ctx)
+
+	// Pages are filled up to the budget; a large value takes its own page.
+	var pages [][]string
+	for begin := "a"; ; {
+		var page []string
+		var err error
+		for key, value := range snap.AscendBudget(ctx, begin, "f", 5, &err) {
+			data, _ := io.ReadAll(value)
+			page = append(page, key+"="+string(data))
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page) == 0 {
+			break
+		}
+		pages = append(pages, page)
+		last, _, _ := strings.Cut(page[len(page)-1], "=")
+		begin = ResumeKey(last)
+	}
+	want := [][]string{{"a=123", "b=45"}, {"c=6789012"}, {"e=45"}}
+	if !reflect.DeepEqual(pages, want) {
+		t.Errorf("pages = %q, want %q", pages, want)
+	}
+
+	var err error
+	for range snap.AscendBudget(ctx, "b", "a", 5, &err) {
+	}
+	if err == nil {
+		t.Errorf("AscendBudget with an invalid range succeeded")
+	}
+}
@@ -0,0 +1,272 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Chunked values are stored as a manifest under the user key and as chunks
+// under the internal keys in the reserved namespace, which are written and
+// deleted in the same transaction as the manifest. Every stored value that
+// begins with the manifest magic is a manifest, because user values beginning
+// with the magic are always chunked.
+const (
+	chunkPrefix   = "\x00kvmemdb.chunk\x00"
+	manifestMagic = "\x00kvmemdb.chunked\x00"
+)
+
+// chunkKey returns the internal key for the i-th chunk of the key. Chunk
+// indices have a fixed width, so chunk keys of different keys never collide.
+func chunkKey(key string, i int) string {
+	return fmt.Sprintf("%s%s\x00%08x", chunkPrefix, key, i)
+}
+
+// hiddenKey returns true if the key is an internal key that is not visible
+// through the scans.
+func (d *Database) hiddenKey(key string) bool {
+	return d.chunkSize > 0 && strings.HasPrefix(key, chunkPrefix)
+}
+
+// manifest describes a chunked value.
+type manifest struct {
+	chunks int
+	size   int64
+}
+
+func (m manifest) String() string {
+	return fmt.Sprintf("%s%d:%d", manifestMagic, m.chunks, m.size)
+}
+
+// parseManifest returns the manifest and true if the stored value is a
+// manifest of a chunked value.
+func (d *Database) parseManifest(data string) (manifest, bool) {
+	if d.chunkSize <= 0 || !strings.HasPrefix(data, manifestMagic) {
+		return manifest{}, false
+	}
+	chunks, size, ok := strings.Cut(data[len(manifestMagic):], ":")
+	if !ok {
+		return manifest{}, false
+	}
+	var m manifest
+	var err1, err2 error
+	m.chunks, err1 = strconv.Atoi(chunks)
+	m.size, err2 = strconv.ParseInt(size, 10, 64)
+	if err1 != nil || err2 != nil {
+		return manifest{}, false
+	}
+	return m, true
+}
+
+// chunkReader is the reader returned for the chunked values, which streams
+// the chunks without copying them.
+type chunkReader struct {
+	io.Reader
+	size int64
+}
+
+// Size returns the size of the value.
+func (r *chunkReader) Size() int64 {
+	return r.size
+}
+
+// loadChunks returns the chunks of a chunked value, read with the input
+// function, which returns false for missing chunks.
+func loadChunks(key string, m manifest, get func(key string) (string, bool)) ([]string, error) {
+	chunks := make([]string, m.chunks)
+	for i := range chunks {
+		data, ok := get(chunkKey(key, i))
+		if !ok {
+			return nil, fmt.Errorf("chunk %d of key %s is missing", i, key)
+		}
+		chunks[i] = data
+	}
+	return chunks, nil
+}
+
+// newChunkReader returns a reader streaming the chunks.
+func newChunkReader(m manifest, chunks []string) *chunkReader {
+	readers := make([]io.Reader, len(chunks))
+	for i, c := range chunks {
+		readers[i] = strings.NewReader(c)
+	}
+	return &chunkReader{Reader: io.MultiReader(readers...), size: m.size}
+}
+
+// readChunks reads all data from the user supplied reader into chunks of the
+// input size, so that large values are never held in a single buffer. It has
+// the same context semantics as readValue.
+func readChunks(ctx context.Context, r io.Reader, size int) ([]string, error) {
+	return readContext(ctx, r, func(r io.Reader) ([]string, error) {
+		var chunks []string
+		buf := make([]byte, size)
+		for {
+			n, err := io.ReadFull(r, buf)
+			if n > 0 {
+				chunks = append(chunks, string(buf[:n]))
+			}
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return chunks, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+	})
+}
+
+// setData stages the in-memory value of the key, in chunks when the chunked
+// values are enabled, like Set.
+func (t *Transaction) setData(key, data string) {
+	size := t.db.chunkSize
+	if size <= 0 {
+		t.stage(key, &data)
+		return
+	}
+	var chunks []string
+	for len(data) > size {
+		chunks = append(chunks, data[:size])
+		data = data[size:]
+	}
+	if len(data) > 0 {
+		chunks = append(chunks, data)
+	}
+	t.setChunked(key, chunks)
+}
+
+// deleteData stages the deletion of the key, along with the chunks of its
+// current value, like Delete.
+func (t *Transaction) deleteData(key string) {
+	t.deleteChunks(key, 0)
+	t.stage(key, nil)
+}
+
+// setChunked stages the value of the key in chunks, along with the deletion
+// of the chunks of its previous value that are not overwritten. Values that
+// fit in a single chunk are stored as is, unless they begin with the manifest
+// magic.
+func (t *Transaction) setChunked(key string, chunks []string) {
+	if len(chunks) <= 1 {
+		data := strings.Join(chunks, "")
+		if !strings.HasPrefix(data, manifestMagic) {
+			t.deleteChunks(key, 0)
+			t.stage(key, &data)
+			return
+		}
+	}
+
+	m := manifest{chunks: len(chunks)}
+	for _, c := range chunks {
+		m.size += int64(len(c))
+	}
+	t.deleteChunks(key, m.chunks)
+	for i := range chunks {
+		t.stage(chunkKey(key, i), &chunks[i])
+	}
+	data := m.String()
+	t.stage(key, &data)
+}
+
+// deleteChunks stages the deletion of the chunks of the key's current value
+// starting from the input index. Key is recorded in the read set when the
+// chunked values are enabled.
+func (t *Transaction) deleteChunks(key string, from int) {
+	if t.db.chunkSize <= 0 {
+		return
+	}
+	data, err := t.getStored(key)
+	if err != nil {
+		return
+	}
+	m, ok := t.db.parseManifest(data)
+	if !ok {
+		return
+	}
+	for i := from; i < m.chunks; i++ {
+		t.stage(chunkKey(key, i), nil)
+	}
+}
+
+// discardStagedChunks stages the deletion of the chunks of the key's value
+// staged by the transaction, except for the chunks in the input writes, which
+// replace them. It is used when the key's value is replaced by the updates of
+// another transaction.
+func (t *Transaction) discardStagedChunks(key string, writes map[string]*string) {
+	value, ok := t.writes[key]
+	if !ok || value == nil {
+		return
+	}
+	m, ok := t.db.parseManifest(*value)
+	if !ok {
+		return
+	}
+	for i := 0; i < m.chunks; i++ {
+		if _, ok := writes[chunkKey(key, i)]; !ok {
+			t.stage(chunkKey(key, i), nil)
+		}
+	}
+}
+
+// restoreChunks stages the chunks of a chunked value committed at the input
+// version, which is restored by Undelete. Returns os.ErrNotExist if any chunk
+// is no longer retained.
+func (t *Transaction) restoreChunks(key string, m manifest, version int64) error {
+	chunks, err := loadChunks(key, m, func(key string) (string, bool) {
+		v := t.db.fetch(key, version)
+		if v == nil || v.IsDeleted() || v.Version() != version {
+			return "", false
+		}
+		return v.Data(), true
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %w", err, os.ErrNotExist)
+	}
+	for i := range chunks {
+		t.stage(chunkKey(key, i), &chunks[i])
+	}
+	return nil
+}
+
+// chunks returns the chunks of a chunked value visible to the transaction.
+func (t *Transaction) chunks(key string, m manifest) ([]string, error) {
+	return loadChunks(key, m, func(key string) (string, bool) {
+		data, err := t.getStored(key)
+		return data, err == nil
+	})
+}
+
+// chunks returns the chunks of a chunked value visible to the snapshot.
+func (s *Snapshot) chunks(key string, m manifest) ([]string, error) {
+	chunks, err := loadChunks(key, m, func(key string) (string, bool) {
+		if v := s.fetch(key); v != nil && !v.IsDeleted() {
+			return v.Data(), true
+		}
+		return "", false
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.check(); err != nil {
+		return nil, err
+	}
+	return chunks, nil
+}
+
+// value returns the data of a live value visible to the snapshot for the
+// key, with the chunked values stitched back together.
+func (s *Snapshot) value(key string, data string) (string, error) {
+	m, ok := s.db.parseManifest(data)
+	if !ok {
+		return data, nil
+	}
+	chunks, err := s.chunks(key, m)
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(chunks, ""), nil
+}
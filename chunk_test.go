@@ -0,0 +1,280 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// liveChunkKeys returns the number of internal chunk keys with a live latest
+// value.
+func liveChunkKeys(db *Database) int {
+	n := 0
+	for key, mv := range db.kvs.Range {
+		vs := mv.Values()
+		if strings.HasPrefix(key, chunkPrefix) && !vs[len(vs)-1].IsDeleted() {
+			n++
+		}
+	}
+	return n
+}
+
+func TestChunkedValues(t *testing.T) {
+	ctx := context.Background()
+
+	const chunkSize = 1 << 20
+	db := New(WithChunkSize(chunkSize))
+
+	large := make([]byte, 64<<20)
+	rand.New(rand.NewSource(1)).Read(large)
+
+	tx, _ := db.NewTransaction(ctx)
+	if err := tx.Set(ctx, "large", bytes.NewReader(large)); err != nil {
+		t.Fatal(err)
+	}
+	tx.Set(ctx, "small", strings.NewReader("small"))
+	tx.Set(ctx, "magic", strings.NewReader(manifestMagic+"1:1"))
+
+	// Staged chunked values are readable by the transaction.
+	r, err := tx.Get(ctx, "large")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := io.ReadAll(r); !bytes.Equal(data, large) {
+		t.Errorf("staged value: round-trip mismatch")
+	}
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := liveChunkKeys(db); n != 65 {
+		t.Errorf("want 64 chunks for the large value and 1 for the magic value, got %d", n)
+	}
+
+	snap, _ := db.NewSnapshot(ctx)
+	defer snap.Discard(ctx)
+
+	// Partial reads only need the leading chunks.
+	r, err = snap.Get(ctx, "large")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := r.(interface{ Size() int64 }).Size(); size != int64(len(large)) {
+		t.Errorf("reader size: want %d, got %d", len(large), size)
+	}
+	prefix := make([]byte, chunkSize+10)
+	if _, err := io.ReadFull(r, prefix); err != nil || !bytes.Equal(prefix, large[:len(prefix)]) {
+		t.Errorf("partial read: want the leading bytes, got mismatch or %v", err)
+	}
+
+	var data []byte
+	if err := snap.GetInto(ctx, "large", &data); err != nil || !bytes.Equal(data, large) {
+		t.Errorf("GetInto: round-trip mismatch or %v", err)
+	}
+	if got := mustGet(ctx, t, snap, "magic"); got != manifestMagic+"1:1" {
+		t.Errorf("value with the manifest magic: got %q", got)
+	}
+
+	// Scans only report the user keys.
+	var keys []string
+	for key := range snap.Scan(ctx, &err) {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	if want := []string{"large", "magic", "small"}; !reflect.DeepEqual(keys, want) || err != nil {
+		t.Errorf("scan: want %q, got %q and %v", want, keys, err)
+	}
+	tx, _ = db.NewTransaction(ctx)
+	defer tx.Rollback(ctx)
+	keys = nil
+	for key := range tx.Ascend(ctx, "", "", &err) {
+		keys = append(keys, key)
+	}
+	if want := []string{"large", "magic", "small"}; !reflect.DeepEqual(keys, want) || err != nil {
+		t.Errorf("tx scan: want %q, got %q and %v", want, keys, err)
+	}
+
+	// Reserved keys are rejected.
+	if err := tx.Set(ctx, chunkKey("large", 0), strings.NewReader("x")); !errors.Is(err, os.ErrInvalid) {
+		t.Errorf("Set on a reserved key: want os.ErrInvalid, got %v", err)
+	}
+
+	// Overwriting with a smaller value and deleting remove the old chunks
+	// in the same commit.
+	tx.Set(ctx, "large", bytes.NewReader(large[:3*chunkSize]))
+	tx.Delete(ctx, "magic")
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := liveChunkKeys(db); n != 3 {
+		t.Errorf("after the overwrite: want 3 chunks, got %d", n)
+	}
+	tx, _ = db.NewTransaction(ctx)
+	tx.Delete(ctx, "large")
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := liveChunkKeys(db); n != 0 {
+		t.Errorf("after the delete: want no chunks, got %d", n)
+	}
+
+	// Older snapshots still read the old value.
+	if err := snap.GetInto(ctx, "large", &data); err != nil || !bytes.Equal(data, large) {
+		t.Errorf("snapshot after the delete: round-trip mismatch or %v", err)
+	}
+}
+
+func TestChunkedValuesCommitInto(t *testing.T) {
+	ctx := context.Background()
+
+	const value = "0123456789abcdef"
+	for _, chunkSize := range []int{0, 8} {
+		src, dst := New(WithChunkSize(4)), New(WithChunkSize(chunkSize))
+
+		tx, _ := src.NewTransaction(ctx)
+		tx.Set(ctx, "large", strings.NewReader(value))
+		if err := tx.CommitInto(ctx, dst); err != nil {
+			t.Fatal(err)
+		}
+
+		// Values are copied, not the source database's chunks.
+		if n, want := liveChunkKeys(dst), chunkSize/4; n != want {
+			t.Errorf("chunk size %d: want %d chunks, got %d", chunkSize, want, n)
+		}
+		snap, _ := dst.NewSnapshot(ctx)
+		if got := mustGet(ctx, t, snap, "large"); got != value {
+			t.Errorf("chunk size %d: got %q, want %q", chunkSize, got, value)
+		}
+		snap.Discard(ctx)
+	}
+}
+
+func TestChunkedValuesAbsorb(t *testing.T) {
+	ctx := context.Background()
+
+	db := New(WithChunkSize(4))
+	parent, _ := db.NewTransaction(ctx)
+	parent.Set(ctx, "x", strings.NewReader("0123456789abcdef"))
+	child, _ := db.NewTransaction(ctx)
+	child.Set(ctx, "x", strings.NewReader("x"))
+	if err := parent.Absorb(ctx, child); err != nil {
+		t.Fatal(err)
+	}
+	if err := parent.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Chunks staged by the parent for the overwritten value are not stored.
+	if n := liveChunkKeys(db); n != 0 {
+		t.Errorf("want no chunks, got %d", n)
+	}
+	snap, _ := db.NewSnapshot(ctx)
+	defer snap.Discard(ctx)
+	if got := mustGet(ctx, t, snap, "x"); got != "x" {
+		t.Errorf("got %q, want x", got)
+	}
+}
+
+func TestChunkedValuesWritePaths(t *testing.T) {
+	ctx := context.Background()
+
+	const value = "0123456789abcdef"
+	newDB := func(keys ...string) *Database {
+		db := New(WithChunkSize(4))
+		for _, key := range keys {
+			mustSet(ctx, t, db, key, value)
+		}
+		return db
+	}
+	commit := func(tx *Transaction) {
+		if err := tx.Commit(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	get := func(db *Database, key string) string {
+		snap, _ := db.NewSnapshot(ctx)
+		defer snap.Discard(ctx)
+		return mustGet(ctx, t, snap, key)
+	}
+
+	// GetAndDelete removes the chunks with the key.
+	db := newDB("x")
+	tx, _ := db.NewTransaction(ctx)
+	r, err := tx.GetAndDelete(ctx, "x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := io.ReadAll(r); string(data) != value {
+		t.Errorf("GetAndDelete: got %q, want %q", data, value)
+	}
+	commit(tx)
+	if n := liveChunkKeys(db); n != 0 {
+		t.Errorf("after GetAndDelete: want no chunks, got %d", n)
+	}
+
+	// Update chunks the new value and removes the chunks of the old value.
+	db = newDB("x", "y")
+	tx, _ = db.NewTransaction(ctx)
+	tx.Update(ctx, "x", func([]byte) ([]byte, error) { return []byte("small"), nil })
+	tx.Update(ctx, "y", func([]byte) ([]byte, error) { return []byte(manifestMagic + "1:1"), nil })
+	tx.Update(ctx, "z", func([]byte) ([]byte, error) { return []byte(value + value), nil })
+	commit(tx)
+	if n := liveChunkKeys(db); n != 2+5+8 {
+		t.Errorf("after Update: want 15 chunks, got %d", n)
+	}
+	for key, want := range map[string]string{"x": "small", "y": manifestMagic + "1:1", "z": value + value} {
+		if got := get(db, key); got != want {
+			t.Errorf("after Update: %s = %q, want %q", key, got, want)
+		}
+	}
+
+	// Copies are chunked under the destination keys.
+	db = newDB("a/x", "a/y")
+	tx, _ = db.NewTransaction(ctx)
+	if n, err := tx.CopyRange(ctx, "a/", "a0", "b/"); err != nil || n != 2 {
+		t.Fatalf("CopyRange = %d, %v, want 2 keys", n, err)
+	}
+	commit(tx)
+	if n := liveChunkKeys(db); n != 16 {
+		t.Errorf("after CopyRange: want 16 chunks, got %d", n)
+	}
+	for _, key := range []string{"a/x", "a/y", "b/x", "b/y"} {
+		if got := get(db, key); got != value {
+			t.Errorf("after CopyRange: %s = %q, want %q", key, got, value)
+		}
+	}
+
+	// Moves delete the chunks of the source keys.
+	db = newDB("a/x")
+	tx, _ = db.NewTransaction(ctx)
+	if n, err := tx.MoveRange(ctx, "a/", "a0", "b/"); err != nil || n != 1 {
+		t.Fatalf("MoveRange = %d, %v, want 1 key", n, err)
+	}
+	commit(tx)
+	if n := liveChunkKeys(db); n != 4 {
+		t.Errorf("after MoveRange: want 4 chunks, got %d", n)
+	}
+	if got := get(db, "b/x"); got != value {
+		t.Errorf("after MoveRange: b/x = %q, want %q", got, value)
+	}
+
+	// Moved values can be committed into other databases.
+	db = newDB("a/x")
+	other := New()
+	tx, _ = db.NewTransaction(ctx)
+	tx.MoveRange(ctx, "a/", "a0", "b/")
+	if err := tx.CommitInto(ctx, other); err != nil {
+		t.Fatal(err)
+	}
+	if got := get(other, "b/x"); got != value {
+		t.Errorf("after CommitInto: b/x = %q, want %q", got, value)
+	}
+}
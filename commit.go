@@ -3,35 +3,253 @@
 package kvmemdb
 
 import (
+	"context"
 	"fmt"
 	"math"
 	"os"
+	"slices"
+	"strconv"
+	"time"
 
 	"github.com/visvasity/kvmemdb/mvcc"
 )
 
-func commit(db *Database, tx *Transaction) error {
+func commit(ctx context.Context, db *Database, tx *Transaction) error {
 	if tx.db == nil {
 		return fmt.Errorf("input transaction is already closed: %w", os.ErrInvalid)
 	}
 	if tx.db != db {
 		return fmt.Errorf("input transaction does not belong to this db: %w", os.ErrInvalid)
 	}
+	if err := db.limitWrites(ctx, tx); err != nil {
+		return err
+	}
+	if db.groupCommitter != nil {
+		return db.groupCommit(ctx, tx)
+	}
+
+	// Phase timings are only collected when slow commits are logged.
+	var timing commitTiming
+	timed := db.slowCommitThreshold > 0
+	if timed {
+		timing.start = time.Now()
+	}
+
+	// Shards of the updated keys are locked before the validation, so that
+	// updates to a key are applied in the order of their commit versions.
+	//
+	// Context cancellation is only honored while waiting for the locks. Once
+	// the transaction is validated its updates are always applied in full.
+	shards, err := db.lockShards(ctx, tx.writes)
+	if err != nil {
+		return fmt.Errorf("could not lock the shards: %w", err)
+	}
+	if timed {
+		timing.locked = time.Now()
+	}
+
+	version, minVersion, err := validate(ctx, db, tx)
+	if timed {
+		timing.validated = time.Now()
+	}
+	if err != nil || version == 0 {
+		db.unlockShards(shards)
+		if timed && err == nil {
+			timing.applied = timing.validated
+			db.logSlowCommit(tx, &timing)
+		}
+		return err
+	}
+
+	db.hook(hookCommitApply)
+	timing.compacted, err = apply(ctx, db, tx, version, minVersion)
+	db.unlockShards(shards)
+	if err != nil {
+		// Version is published anyway, so that the later commits are not
+		// blocked forever.
+		db.publish(version)
+		return err
+	}
+	if timed {
+		timing.applied = time.Now()
+	}
+
+	db.finishCommit(ctx, tx, version)
+	if timed {
+		db.logSlowCommit(tx, &timing)
+	}
+	return nil
+}
 
-	db.mu.Lock()
+// finishCommit publishes the updates of a transaction applied at the input
+// version and performs the post-commit maintenance.
+func (d *Database) finishCommit(ctx context.Context, tx *Transaction, version int64) {
+	d.publish(version)
+	d.afterCommit(ctx, tx)
+}
+
+// afterCommit performs the post-commit maintenance for a transaction whose
+// updates are published. Maintenance may commit new transactions, e.g., from
+// the OnEvict function, so all earlier versions must be published too.
+func (d *Database) afterCommit(ctx context.Context, tx *Transaction) {
+	d.notifyDropped(tx)
+	d.pruneValues(tx)
+	d.notifyInvalidations(tx)
+	d.maybeRebuildBloom()
+	d.maybeEvict(ctx)
+}
+
+// validate checks the transaction for conflicts and assigns it a new commit
+// version. Returns zero version for read-only transactions, which do not need
+// to apply any updates. Also returns the min version that is safe to use for
+// compacting the updated keys. Returns the context error if the context is
+// canceled while waiting for the database mutex.
+func validate(ctx context.Context, db *Database, tx *Transaction) (version, minVersion int64, err error) {
+	if err := db.mu.LockContext(ctx); err != nil {
+		return 0, 0, fmt.Errorf("could not lock the database: %w", err)
+	}
 	defer db.mu.Unlock()
 
+	db.hook(hookCommitValidate)
+
+	if err := checkCommit(db, tx); err != nil {
+		return 0, 0, err
+	}
+	version, minVersion = assignVersion(db, tx)
+	return version, minVersion, nil
+}
+
+// checkCommit returns a non-nil error if the transaction cannot be committed.
+// It doesn't modify the transaction or the database. Caller must hold the
+// database mutex.
+func checkCommit(db *Database, tx *Transaction) error {
 	if tx.committed {
 		return fmt.Errorf("tx is already committed: %w", os.ErrInvalid)
 	}
+	if tx.aborted.Load() {
+		return fmt.Errorf("tx %d: %w", tx.id, ErrAborted)
+	}
+	if err := db.checkPoisoned(); err != nil {
+		return err
+	}
+
+	// Retries of the commits with an idempotency key are no-ops.
+	if _, ok := db.idempotentVersionLocked(tx); ok {
+		return nil
+	}
 
 	// Read-Only transactions can be committed immediately. They don't conflict
 	// with any other transaction.
+	if len(tx.writes) == 0 {
+		return nil
+	}
+	if db.frozen.Load() {
+		return fmt.Errorf("database is frozen: %w", os.ErrPermission)
+	}
+	return checkConflicts(db, tx)
+}
+
+// assignVersion marks a transaction that has passed the checks as committed
+// and returns its commit version, which is zero for read-only transactions
+// and the retries of the commits with an idempotency key, and the min version
+// that is safe to use for compacting the updated keys. Caller must hold the
+// database mutex.
+func assignVersion(db *Database, tx *Transaction) (version, minVersion int64) {
+	if v, ok := db.idempotentVersionLocked(tx); ok {
+		tx.committed = true
+		tx.commitVersion = v
+		return 0, 0
+	}
 	if len(tx.writes) == 0 {
 		tx.committed = true
+		tx.commitVersion = tx.snapshotVersion
+		db.recordIdempotencyKeyLocked(tx, tx.commitVersion)
+		return 0, 0
+	}
+
+	// Counter keys are updated with the last allocated sequence numbers, which
+	// are larger than the values written by the earlier commits.
+	for key := range tx.sequences {
+		s := strconv.FormatUint(db.sequences[key], 10)
+		tx.writes[key] = &s
+	}
+
+	// New snapshots and transactions can be created at the maxCommitVersion
+	// while this transaction's updates are applied, so compaction must retain
+	// the versions visible at the maxCommitVersion.
+	minVersion = min(db.minVersionLocked(), db.maxCommitVersion.Load())
+
+	db.commitVersion++
+	tx.committed = true
+	tx.commitVersion = db.commitVersion
+	db.recordIdempotencyKeyLocked(tx, tx.commitVersion)
+	return db.commitVersion, minVersion
+}
+
+// checkConflicts returns a non-nil *ErrConflict error if the transaction
+// conflicts with the committed transactions. Caller must hold the database
+// mutex.
+//
+// Checks stop at the first conflict, unless the transaction reports all
+// conflicts, in which case all conflicts are collected from all checks and
+// their keys are reported in the sorted order.
+func checkConflicts(db *Database, tx *Transaction) error {
+	var conflicts []*ErrConflict
+	fail := func(err *ErrConflict) error {
+		if !tx.reportAllConflicts {
+			return err
+		}
+		conflicts = append(conflicts, err)
 		return nil
 	}
 
+	start := time.Now()
+	err := checkReadConflicts(db, tx, fail)
+	checked := time.Now()
+	db.commitStats.validate.observe(checked.Sub(start))
+	if err != nil {
+		return err
+	}
+	err = checkWriteConflicts(db, tx, fail)
+	db.commitStats.writeCheck.observe(time.Since(checked))
+	if err != nil {
+		return err
+	}
+
+	if len(conflicts) > 0 {
+		var keys []string
+		for _, c := range conflicts {
+			keys = append(keys, c.Keys...)
+		}
+		slices.Sort(keys)
+		keys = slices.Compact(keys)
+		return &ErrConflict{
+			Keys:      keys,
+			Conflicts: conflicts,
+			msg:       fmt.Sprintf("ssi: keys %v conflict with the committed transactions", keys),
+		}
+	}
+	return nil
+}
+
+// newConflict returns an *ErrConflict error for the input keys, which are
+// sorted in place, with a message formatted with the keys as the first
+// argument.
+func newConflict(keys []string, txID uint64, r *Range, format string, args ...any) *ErrConflict {
+	slices.Sort(keys)
+	return &ErrConflict{
+		Keys:  keys,
+		TxID:  txID,
+		Range: r,
+		msg:   fmt.Sprintf(format, append([]any{keys}, args...)...),
+	}
+}
+
+// checkReadConflicts checks the reads and the scanned ranges of the
+// transaction for rw-dependencies with the committed transactions. Conflicts
+// are reported through the fail function, which returns a non-nil error to
+// stop the checks.
+func checkReadConflicts(db *Database, tx *Transaction, fail func(*ErrConflict) error) error {
 	// Serializable Snapshot Isolation requires that we identify rw-dependencies
 	// between concurrent transactions and allow the first-committer-win policy.
 	//
@@ -51,18 +269,76 @@ func commit(db *Database, tx *Transaction) error {
 			continue
 		}
 		if ks := overlappingKeys(tx.reads, v.writes); len(ks) > 0 {
-			return fmt.Errorf("ssi: keys %v read were updated by a committed tx %v", ks, v)
+			if err := fail(newConflict(ks, v.id, nil, "ssi: keys %v read were updated by a committed tx %d", v.id)); err != nil {
+				return err
+			}
 		}
 		if ks := overlappingKeys(v.reads, tx.writes); len(ks) > 0 {
-			return fmt.Errorf("ssi: keys %v written were read by a committed tx %v", ks, v)
+			if err := fail(newConflict(ks, v.id, nil, "ssi: keys %v written were read by a committed tx %d", v.id)); err != nil {
+				return err
+			}
+		}
+		for _, r := range tx.scanRanges {
+			if ks := keysInRange(r, v.writes); len(ks) > 0 {
+				if err := fail(newConflict(ks, v.id, &r, "ssi: keys %v in the scanned ranges were updated by a committed tx %d, conflicting with the scan of %v", v.id, r)); err != nil {
+					return err
+				}
+			}
+		}
+		for _, r := range v.scanRanges {
+			if ks := keysInRange(r, tx.writes); len(ks) > 0 {
+				if err := fail(newConflict(ks, v.id, &r, "ssi: keys %v written were in the ranges scanned by a committed tx %d, conflicting with the scan of %v", v.id, r)); err != nil {
+					return err
+				}
+			}
+		}
+		for _, r := range tx.lockedRanges {
+			if ks := insertedKeys(db, tx, r, v.writes); len(ks) > 0 {
+				if err := fail(newConflict(ks, v.id, &r, "ssi: keys %v in the locked ranges were created by a committed tx %d, conflicting with the lock of %v", v.id, r)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	// Pinned transactions may be created after other transactions have
+	// committed on top of their version, which are not tracked as concurrent
+	// transactions, so their reads are validated against the current database
+	// state.
+	if tx.pinned {
+		if ks := staleReads(db, tx); len(ks) > 0 {
+			if err := fail(newConflict(ks, 0, nil, "ssi: keys %v read were updated after this tx has begun")); err != nil {
+				return err
+			}
+		}
+		for _, r := range tx.lockedRanges {
+			if ks := phantomKeys(db, tx, r); len(ks) > 0 {
+				if err := fail(newConflict(ks, 0, &r, "ssi: keys %v in the locked ranges were created after this tx has begun, conflicting with the lock of %v", r)); err != nil {
+					return err
+				}
+			}
 		}
 	}
 
-	// Check for all write-write conflicts with the current state of the
-	// database. Identify and skip blind writes.
+	return nil
+}
+
+// checkWriteConflicts checks the writes of the transaction for write-write
+// conflicts with the current state of the database. Conflicts are reported
+// through the fail function, which returns a non-nil error to stop the
+// checks.
+func checkWriteConflicts(db *Database, tx *Transaction, fail func(*ErrConflict) error) error {
+	// Identify and skip blind writes.
 	for key := range tx.writes {
-		if _, ok := tx.reads[key]; !ok {
-			// Skipping blind writes from write-write conflicts.
+		if _, ok := tx.sequences[key]; ok {
+			// Counter keys are allocated under the database mutex, so they
+			// cannot be lost by the concurrent updates.
+			continue
+		}
+		if _, ok := tx.reads[key]; !ok && tx.trackReads {
+			// Skipping blind writes from write-write conflicts. Transactions
+			// without read tracking check all writes, because their read
+			// dependencies are unknown.
 			continue
 		}
 		mv, ok := db.kvs.Load(key)
@@ -77,46 +353,154 @@ func commit(db *Database, tx *Transaction) error {
 			continue
 		}
 		if !cok && iok {
-			return fmt.Errorf("ww-conflict: key %v is deleted by another tx", key)
+			if err := fail(&ErrConflict{Keys: []string{key}, msg: fmt.Sprintf("ww-conflict: key %v is deleted by another tx", key)}); err != nil {
+				return err
+			}
 		}
 		if cok && !iok {
-			return fmt.Errorf("ww-conflict: key %v is also created by another tx", key)
+			if err := fail(&ErrConflict{Keys: []string{key}, msg: fmt.Sprintf("ww-conflict: key %v is also created by another tx", key)}); err != nil {
+				return err
+			}
 		}
 		if current.Version() != initial.Version() {
-			return fmt.Errorf("ww-conflict: key %v is updated after this tx has begun", key)
+			if err := fail(&ErrConflict{Keys: []string{key}, msg: fmt.Sprintf("ww-conflict: key %v is updated after this tx has begun", key)}); err != nil {
+				return err
+			}
 		}
 	}
+	return nil
+}
+
+// update is the new state of a key updated by a transaction, which is
+// prepared before any changes are made to the database.
+type update struct {
+	key   string
+	value *string
 
-	minVersion := db.minVersionLocked()
-	newCommitVersion := db.maxCommitVersion + 1
+	// prev is the current multi-value of the key, or nil for new keys; next
+	// is its replacement, or nil if the key is removed.
+	prev, next *mvcc.MultiValue
+
+	// compacted is true if any versions older than minVersion were removed
+	// from the key.
+	compacted bool
+}
+
+// apply updates the database with the transaction's side effects at the
+// input version and returns true if the compaction removed any versions older
+// than minVersion. Caller must hold the shard locks for all updated keys.
+//
+// Updates are always applied once the transaction is validated, but the
+// compaction of older versions is skipped if the context is canceled.
+//
+// New multi-values for all keys are prepared before the database is updated,
+// so a panic while preparing them leaves the database unchanged. Panics are
+// recovered and mark the database as corrupted, because the transaction was
+// already assigned the version and any panic while updating the database
+// could have applied only a part of the updates. Returns an error wrapping
+// ErrCorrupted in that case.
+func apply(ctx context.Context, db *Database, tx *Transaction, version, minVersion int64) (compacted bool, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			compacted, err = false, db.poison(tx, r)
+		}
+	}()
+
+	start := time.Now()
+	updates := prepare(ctx, db, tx, version, minVersion)
+	prepared := time.Now()
+	db.commitStats.compact.observe(prepared.Sub(start))
+
+	for i := range updates {
+		store(db, tx, &updates[i], minVersion)
+		compacted = compacted || updates[i].compacted
+	}
+	db.commitStats.apply.observe(time.Since(prepared))
+	return compacted, nil
+}
 
-	// Update the database with the transaction's side effects.
+// prepare returns the new multi-values for all keys updated by the
+// transaction at the input version. It doesn't modify the database.
+func prepare(ctx context.Context, db *Database, tx *Transaction, version, minVersion int64) []update {
+	updates := make([]update, 0, len(tx.writes))
 	for key, value := range tx.writes {
-		v := mvcc.NewValue(newCommitVersion)
+		db.hook(hookCommitPrepare)
+
+		v := mvcc.NewValue(version)
 		if value == nil {
 			v.Delete()
 		} else {
 			v.SetData(*value)
 		}
 
+		u := update{key: key, value: value}
 		mv, ok := db.kvs.Load(key)
 		if !ok {
-			db.kvs.Store(key, mvcc.NewMultiValue(v))
+			u.next = mvcc.NewMultiValue(v)
+			updates = append(updates, u)
 			continue
 		}
+		u.prev = mv
 
 		// Remove unnecessary versions from very old transactions.
-		nmv := mvcc.Compact(mvcc.Append(mv, v), minVersion)
-		if nmv == nil {
-			db.kvs.Delete(key)
-		} else {
-			db.kvs.Store(key, nmv)
+		db.hook(hookCompact)
+		// Canceled compaction returns the uncompacted multi-value, which is
+		// stored as is.
+		amv := mvcc.Append(mv, v)
+		nmv, _ := mvcc.Compact(ctx, amv, minVersion)
+		if nmv != amv {
+			// Only the versions older than minVersion are removed.
+			u.compacted = true
+			if e, ok := droppedValue(key, amv, nmv); ok {
+				tx.dropped = append(tx.dropped, e)
+			}
+		}
+		if nmv == nil && db.base != nil && db.base.fetch(key, math.MaxInt64) != nil {
+			// Deletion marker is retained to hide the key in the base.
+			nmv = mvcc.NewMultiValue(v)
 		}
+		if debugChecks && nmv != nil {
+			if err := nmv.Validate(); err != nil {
+				panic(fmt.Sprintf("key %q is corrupted at version %d: %v", key, version, err))
+			}
+		}
+		u.next = nmv
+		updates = append(updates, u)
 	}
-	db.maxCommitVersion = newCommitVersion
+	return updates
+}
 
-	tx.committed = true
-	return nil
+// store updates the database with a prepared update and its indexes.
+func store(db *Database, tx *Transaction, u *update, minVersion int64) {
+	db.touchWrite(u.key, u.value == nil)
+	db.indexValue(u.key, u.value)
+	if u.value == nil {
+		db.bloomDeletes.Add(1)
+	} else {
+		// Existing keys may have been dropped from the filter by a rebuild
+		// when only their deletion marker was left.
+		db.addKey(u.key)
+	}
+	if u.compacted {
+		db.advanceCompacted(minVersion)
+	}
+
+	if u.prev == nil {
+		db.bloomAdds.Add(1)
+		db.size.Add(entrySize(u.key, u.value))
+		db.indexKey(u.key)
+		db.kvs.Store(u.key, u.next)
+		db.noteWrite(u.key, u.next)
+		return
+	}
+	db.size.Add(entrySize(u.key, u.value) - latestSize(u.key, u.prev))
+	if u.next == nil {
+		db.kvs.Delete(u.key)
+		db.unindexKey(u.key)
+		return
+	}
+	db.kvs.Store(u.key, u.next)
+	db.noteWrite(u.key, u.next)
 }
 
 func overlappingKeys(reads map[string]*mvcc.Value, writes map[string]*string) []string {
@@ -128,3 +512,79 @@ func overlappingKeys(reads map[string]*mvcc.Value, writes map[string]*string) []
 	}
 	return keys
 }
+
+// keysInRange returns the updated keys that belong to the input range.
+func keysInRange(r Range, writes map[string]*string) []string {
+	var keys []string
+	for k := range writes {
+		if r.contains(k) {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// insertedKeys returns the keys created by the input writes in a range locked
+// by the transaction, which did not exist at the transaction's snapshot
+// version.
+func insertedKeys(db *Database, tx *Transaction, r Range, writes map[string]*string) []string {
+	var keys []string
+	for _, k := range keysInRange(r, writes) {
+		if writes[k] == nil {
+			continue
+		}
+		if v := db.fetch(k, tx.snapshotVersion); v == nil || v.IsDeleted() {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// phantomKeys returns the keys in a range locked by the transaction that
+// exist in the database, but did not exist at the transaction's snapshot
+// version.
+func phantomKeys(db *Database, tx *Transaction, r Range) []string {
+	var keys []string
+	for key, mv := range db.kvs.Range {
+		if !r.contains(key) {
+			continue
+		}
+		latest, ok := mv.Fetch(math.MaxInt64)
+		if !ok || latest.IsDeleted() || latest.Version() <= tx.snapshotVersion {
+			continue
+		}
+		if v := db.fetch(key, tx.snapshotVersion); v == nil || v.IsDeleted() {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// staleReads returns the keys read by the transaction, including the keys in
+// its scanned ranges, that are updated after the transaction's snapshot
+// version.
+func staleReads(db *Database, tx *Transaction) []string {
+	updated := func(mv *mvcc.MultiValue) bool {
+		latest, ok := mv.Fetch(math.MaxInt64)
+		return ok && latest.Version() > tx.snapshotVersion
+	}
+
+	var keys []string
+	for key := range tx.reads {
+		if mv, ok := db.kvs.Load(key); ok && updated(mv) {
+			keys = append(keys, key)
+		}
+	}
+	if len(tx.scanRanges) == 0 {
+		return keys
+	}
+	for key, mv := range db.kvs.Range {
+		if _, ok := tx.reads[key]; ok {
+			continue
+		}
+		if slices.ContainsFunc(tx.scanRanges, func(r Range) bool { return r.contains(key) }) && updated(mv) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
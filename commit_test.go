@@ -0,0 +1,361 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestShardedCommits(t *testing.T) {
+	ctx := context.Background()
+
+	const ncounters = 8
+	const nworkers = 8
+	const nincrements = 100
+
+	increment := func(old []byte) ([]byte, error) {
+		n, _ := strconv.Atoi(string(old))
+		return []byte(strconv.Itoa(n + 1)), nil
+	}
+
+	for _, nshards := range []int{1, 4, 16} {
+		t.Run(fmt.Sprintf("shards=%d", nshards), func(t *testing.T) {
+			db := New(WithMutexShards(nshards))
+
+			var wg sync.WaitGroup
+			for w := 0; w < nworkers; w++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+
+					for i := 0; i < nincrements; {
+						tx, err := db.NewTransaction(ctx)
+						if err != nil {
+							t.Error(err)
+							return
+						}
+						key := fmt.Sprintf("counter%d", (w+i)%ncounters)
+						if err := tx.Update(ctx, key, increment); err != nil {
+							t.Error(err)
+							return
+						}
+						if err := tx.Commit(ctx); err == nil {
+							i++
+						}
+					}
+				}()
+			}
+			wg.Wait()
+
+			snap, _ := db.NewSnapshot(ctx)
+			defer snap.Discard(ctx)
+
+			total := 0
+			for i := 0; i < ncounters; i++ {
+				n, _ := strconv.Atoi(mustGet(ctx, t, snap, fmt.Sprintf("counter%d", i)))
+				total += n
+			}
+			if want := nworkers * nincrements; total != want {
+				t.Errorf("sum of all counters = %d, want %d", total, want)
+			}
+		})
+	}
+}
+
+func BenchmarkShardedCommits(b *testing.B) {
+	ctx := context.Background()
+
+	const nkeys = 1000000
+
+	for _, nshards := range []int{1, 4, 16} {
+		b.Run(fmt.Sprintf("shards=%d", nshards), func(b *testing.B) {
+			db := New(WithMutexShards(nshards))
+
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					key := fmt.Sprintf("key%07d", rand.IntN(nkeys))
+					if err := setKey(ctx, db, key, "value"); err != nil {
+						b.Error(err)
+						return
+					}
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkCommitPrefixedWrites(b *testing.B) {
+	ctx := context.Background()
+
+	const nkeys = 1000000
+	const nwrites = 100
+
+	m := make(map[string][]byte, nkeys)
+	for i := range nkeys {
+		m[fmt.Sprintf("user/%07d", i)] = []byte("value")
+	}
+	db, err := FromMap(ctx, m)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for range b.N {
+		tx, _ := db.NewTransaction(ctx)
+		for range nwrites {
+			key := fmt.Sprintf("user/%07d", rand.IntN(nkeys))
+			tx.Set(ctx, key, strings.NewReader("value"))
+		}
+		if err := tx.Commit(ctx); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestCommitDeadline(t *testing.T) {
+	ctx := context.Background()
+
+	db := New()
+	mustSet(ctx, t, db, "key", "initial")
+
+	// A commit paused in the validation holds the database mutex and the
+	// shard of its updated key, while another commit for the same key is
+	// applied after it.
+	pauses := pauseAt(db, hookCommitValidate, hookCommitApply)
+	validating, applying := pauses[hookCommitValidate], pauses[hookCommitApply]
+	defer validating.resume()
+	defer applying.resume()
+
+	holder, _ := db.NewTransaction(ctx)
+	holder.Set(ctx, "held", strings.NewReader("value"))
+	blocked, _ := db.NewTransaction(ctx)
+	blocked.Set(ctx, "other", strings.NewReader("value"))
+
+	errc := make(chan error, 1)
+	go func() { errc <- holder.Commit(ctx) }()
+	<-validating.reached
+
+	// Commit waiting for the database mutex gives up at the deadline.
+	dctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := blocked.Commit(dctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Commit waiting for the database mutex = %v, want context.DeadlineExceeded", err)
+	}
+	if s := blocked.State(); s != TxRolledBack {
+		t.Errorf("timed out tx state = %v, want %v", s, TxRolledBack)
+	}
+
+	// Commit waiting for a shard lock held by an applying commit also gives
+	// up at the deadline.
+	validating.resume()
+	<-applying.reached
+	shardBlocked, _ := db.NewTransaction(ctx)
+	shardBlocked.Set(ctx, "held", strings.NewReader("other"))
+	dctx, cancel = context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := shardBlocked.Commit(dctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Commit waiting for a shard lock = %v, want context.DeadlineExceeded", err)
+	}
+
+	applying.resume()
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing is applied from the timed out commits.
+	snap, _ := db.NewSnapshot(ctx)
+	defer snap.Discard(ctx)
+	if _, err := snap.Get(ctx, "other"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Get on a key from a timed out commit = %v, want os.ErrNotExist", err)
+	}
+	if got := mustGet(ctx, t, snap, "held"); got != "value" {
+		t.Errorf("held = %q, want value", got)
+	}
+	// Timed out transactions are removed from the database in the background.
+	for start := time.Now(); db.Health().LiveTransactions != 0; time.Sleep(time.Millisecond) {
+		if time.Since(start) > time.Second {
+			t.Fatalf("Health = %+v, want no live transactions", db.Health())
+		}
+	}
+
+	// Canceled contexts do not affect the updates once validated.
+	p := pauseAt(db, hookCommitApply)[hookCommitApply]
+	defer p.resume()
+	cctx, cancel := context.WithCancel(ctx)
+	tx, _ := db.NewTransaction(ctx)
+	tx.Set(ctx, "key", strings.NewReader("updated"))
+	go func() { errc <- tx.Commit(cctx) }()
+	<-p.reached
+	cancel()
+	p.resume()
+	if err := <-errc; err != nil {
+		t.Fatalf("Commit canceled after the validation = %v, want nil", err)
+	}
+	latest, _ := db.NewSnapshot(ctx)
+	defer latest.Discard(ctx)
+	if got := mustGet(ctx, t, latest, "key"); got != "updated" {
+		t.Errorf("key = %q, want updated", got)
+	}
+}
+
+func TestReadYourWrites(t *testing.T) {
+	ctx := context.Background()
+
+	db := New(WithMutexShards(4))
+	for i := 0; i < 100; i++ {
+		key, value := fmt.Sprintf("key%d", i%10), strconv.Itoa(i)
+		mustSet(ctx, t, db, key, value)
+
+		snap, _ := db.NewSnapshot(ctx)
+		if got := mustGet(ctx, t, snap, key); got != value {
+			t.Errorf("snapshot after commit: %s = %q, want %q", key, got, value)
+		}
+		snap.Discard(ctx)
+
+		tx, _ := db.NewTransaction(ctx)
+		if got := mustGet(ctx, t, tx, key); got != value {
+			t.Errorf("transaction after commit: %s = %q, want %q", key, got, value)
+		}
+		tx.Rollback(ctx)
+	}
+}
+
+func TestWaitForVersion(t *testing.T) {
+	ctx := context.Background()
+
+	db := New()
+	mustSet(ctx, t, db, "key", "v1")
+	if err := db.WaitForVersion(ctx, 1); err != nil {
+		t.Errorf("WaitForVersion on a published version = %v, want nil", err)
+	}
+
+	// Version is not published while the commit is applying its updates.
+	p := pauseAt(db, hookCommitApply)[hookCommitApply]
+	defer p.resume()
+	errc := make(chan error, 1)
+	go func() { errc <- setKey(ctx, db, "key", "v2") }()
+	<-p.reached
+
+	dctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := db.WaitForVersion(dctx, 2); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("WaitForVersion on an unpublished version = %v, want context.DeadlineExceeded", err)
+	}
+
+	waited := make(chan error, 1)
+	go func() { waited <- db.WaitForVersion(ctx, 2) }()
+	p.resume()
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	if err := <-waited; err != nil {
+		t.Fatal(err)
+	}
+	snap, _ := db.NewSnapshot(ctx)
+	defer snap.Discard(ctx)
+	if got := mustGet(ctx, t, snap, "key"); got != "v2" {
+		t.Errorf("key = %q after WaitForVersion, want v2", got)
+	}
+}
+
+func TestCommitVersionHandoff(t *testing.T) {
+	ctx := context.Background()
+
+	db := New()
+	mustSet(ctx, t, db, "key", "v1")
+
+	tx, _ := db.NewTransaction(ctx)
+	if v := tx.CommitVersion(); v != 0 {
+		t.Errorf("CommitVersion before commit = %d, want 0", v)
+	}
+	if v := tx.SnapshotVersion(); v != 1 {
+		t.Errorf("SnapshotVersion = %d, want 1", v)
+	}
+	tx.Set(ctx, "key", strings.NewReader("v2"))
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if v := tx.CommitVersion(); v != 2 {
+		t.Errorf("CommitVersion = %d, want 2", v)
+	}
+	if v := tx.SnapshotVersion(); v != 1 {
+		t.Errorf("SnapshotVersion after commit = %d, want 1", v)
+	}
+
+	// Reader in another goroutine waits for the version from the writer.
+	versions := make(chan int64, 1)
+	values := make(chan string, 1)
+	go func() {
+		if err := db.WaitForVersion(ctx, <-versions); err != nil {
+			t.Error(err)
+		}
+		snap, _ := db.NewSnapshot(ctx)
+		defer snap.Discard(ctx)
+		v, _ := snap.Get(ctx, "key")
+		data, _ := io.ReadAll(v)
+		values <- string(data)
+	}()
+	versions <- tx.CommitVersion()
+	if got := <-values; got != "v2" {
+		t.Errorf("reader got %q, want v2", got)
+	}
+
+	ro, _ := db.NewTransaction(ctx)
+	mustGet(ctx, t, ro, "key")
+	if err := ro.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if v := ro.CommitVersion(); v != 2 {
+		t.Errorf("CommitVersion of a read-only tx = %d, want its snapshot version 2", v)
+	}
+}
+
+func TestCommitWithDeadline(t *testing.T) {
+	ctx := context.Background()
+
+	db := New()
+	pauses := pauseAt(db, hookCommitValidate)
+	validating := pauses[hookCommitValidate]
+	defer validating.resume()
+
+	holder, _ := db.NewTransaction(ctx)
+	holder.Set(ctx, "held", strings.NewReader("value"))
+	blocked, _ := db.NewTransaction(ctx)
+	blocked.Set(ctx, "other", strings.NewReader("value"))
+
+	errc := make(chan error, 1)
+	go func() { errc <- holder.Commit(ctx) }()
+	<-validating.reached
+
+	start := time.Now()
+	if err := blocked.CommitWithDeadline(ctx, 20*time.Millisecond); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("CommitWithDeadline behind a stalled commit = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("CommitWithDeadline took %v to give up", elapsed)
+	}
+	if err := blocked.Rollback(ctx); err != nil {
+		t.Errorf("Rollback after a timed out commit = %v, want nil", err)
+	}
+
+	validating.resume()
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	tx, _ := db.NewTransaction(ctx)
+	defer tx.Rollback(ctx)
+	tx.Set(ctx, "other", strings.NewReader("value"))
+	if err := tx.CommitWithDeadline(ctx, time.Second); err != nil {
+		t.Errorf("CommitWithDeadline without contention = %v, want nil", err)
+	}
+}
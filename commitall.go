@@ -0,0 +1,162 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"os"
+	"slices"
+)
+
+// CommitAll commits transactions from different databases atomically: either
+// all transactions are committed or all of them are rolled back. Transactions
+// are validated for conflicts in their own databases and a conflict in any
+// database rolls back all transactions.
+//
+// Databases are locked in the order of their creation, so concurrent
+// CommitAll calls cannot deadlock. Commit versions are assigned by each
+// database independently, and the updates become visible in each database
+// separately, so readers may briefly observe the updates in some databases
+// before the others.
+//
+// Transactions are closed irrespective of the result, as with Commit. Returns
+// os.ErrInvalid if any transaction is nil or multiple transactions belong to
+// the same database, in which case all transactions are rolled back.
+func CommitAll(ctx context.Context, txes ...*Transaction) error {
+	txes = slices.Clone(txes)
+	if err := checkCommitAll(txes); err != nil {
+		for _, tx := range txes {
+			if tx != nil {
+				tx.Rollback(ctx)
+			}
+		}
+		return err
+	}
+	slices.SortFunc(txes, func(a, b *Transaction) int { return cmp.Compare(a.db.id, b.db.id) })
+
+	dbs := make([]*Database, len(txes))
+	for i, tx := range txes {
+		dbs[i] = tx.db
+	}
+
+	if err := commitAll(ctx, txes); err != nil {
+		for i, tx := range txes {
+			tx.state = TxRolledBack
+			if ctx.Err() != nil {
+				// See Commit for removing the transaction in the background.
+				go dbs[i].closeTransaction(tx)
+				continue
+			}
+			dbs[i].closeTransaction(tx)
+		}
+		return err
+	}
+	for i, tx := range txes {
+		tx.state = TxCommitted
+		dbs[i].closeTransaction(tx)
+	}
+	return nil
+}
+
+// checkCommitAll returns a non-nil error if the transactions cannot be
+// committed together.
+func checkCommitAll(txes []*Transaction) error {
+	for i, tx := range txes {
+		if tx == nil {
+			return fmt.Errorf("transaction cannot be nil: %w", os.ErrInvalid)
+		}
+		if err := tx.check(); err != nil {
+			return err
+		}
+		for _, other := range txes[:i] {
+			if other.db == tx.db {
+				return fmt.Errorf("transactions %d and %d belong to the same db: %w", other.id, tx.id, os.ErrInvalid)
+			}
+		}
+	}
+	return nil
+}
+
+// commitAll commits the transactions that are sorted in the database locking
+// order.
+func commitAll(ctx context.Context, txes []*Transaction) error {
+	for _, tx := range txes {
+		if err := tx.db.limitWrites(ctx, tx); err != nil {
+			return err
+		}
+	}
+
+	shards := make([][]int, 0, len(txes))
+	defer func() {
+		for i, indices := range shards {
+			txes[i].db.unlockShards(indices)
+		}
+	}()
+	for _, tx := range txes {
+		indices, err := tx.db.lockShards(ctx, tx.writes)
+		if err != nil {
+			return fmt.Errorf("could not lock the shards: %w", err)
+		}
+		shards = append(shards, indices)
+	}
+
+	versions, minVersions, err := validateAll(ctx, txes)
+	if err != nil {
+		return err
+	}
+
+	// Updates of all transactions are applied even if a database is corrupted
+	// by a panic, because all of them are already assigned the versions.
+	var applyErr error
+	for i, tx := range txes {
+		db := tx.db
+		if versions[i] == 0 {
+			continue
+		}
+		db.hook(hookCommitApply)
+		_, err := apply(ctx, db, tx, versions[i], minVersions[i])
+		db.unlockShards(shards[i])
+		shards[i] = nil
+		if err != nil {
+			db.publish(versions[i])
+			applyErr = cmp.Or(applyErr, fmt.Errorf("tx %d: %w", tx.id, err))
+			continue
+		}
+		db.finishCommit(ctx, tx, versions[i])
+	}
+	return applyErr
+}
+
+// validateAll checks all transactions for conflicts with all database
+// mutexes held and assigns them commit versions only if all checks pass.
+func validateAll(ctx context.Context, txes []*Transaction) (versions, minVersions []int64, err error) {
+	for i, tx := range txes {
+		if err := tx.db.mu.LockContext(ctx); err != nil {
+			for _, locked := range txes[:i] {
+				locked.db.mu.Unlock()
+			}
+			return nil, nil, fmt.Errorf("could not lock the database: %w", err)
+		}
+	}
+	defer func() {
+		for _, tx := range txes {
+			tx.db.mu.Unlock()
+		}
+	}()
+
+	for _, tx := range txes {
+		tx.db.hook(hookCommitValidate)
+		if err := checkCommit(tx.db, tx); err != nil {
+			return nil, nil, fmt.Errorf("tx %d: %w", tx.id, err)
+		}
+	}
+
+	versions = make([]int64, len(txes))
+	minVersions = make([]int64, len(txes))
+	for i, tx := range txes {
+		versions[i], minVersions[i] = assignVersion(tx.db, tx)
+	}
+	return versions, minVersions, nil
+}
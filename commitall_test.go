@@ -0,0 +1,119 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestCommitAll(t *testing.T) {
+	ctx := context.Background()
+
+	a, b := New(), New()
+	mustSet(ctx, t, a, "key", "a0")
+	mustSet(ctx, t, b, "key", "b0")
+
+	// A conflict in one database rolls back the transactions in all databases.
+	txa, _ := a.NewTransaction(ctx)
+	txb, _ := b.NewTransaction(ctx)
+	mustGet(ctx, t, txb, "key")
+	txa.Set(ctx, "key", strings.NewReader("a1"))
+	txb.Set(ctx, "other", strings.NewReader("b1"))
+	mustSet(ctx, t, b, "key", "b2")
+	if err := CommitAll(ctx, txa, txb); err == nil {
+		t.Fatalf("CommitAll with a conflict in one database must fail")
+	}
+	if txa.State() != TxRolledBack || txb.State() != TxRolledBack {
+		t.Errorf("want all transactions rolled back, got %v and %v", txa.State(), txb.State())
+	}
+	for _, db := range []*Database{a, b} {
+		snap, _ := db.NewSnapshot(ctx)
+		if v := mustGet(ctx, t, snap, "key"); v == "a1" {
+			t.Errorf("update from a rolled back transaction is visible")
+		}
+		if _, err := snap.Get(ctx, "other"); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("update from a rolled back transaction is visible")
+		}
+		snap.Discard(ctx)
+	}
+
+	txa, _ = a.NewTransaction(ctx)
+	txb, _ = a.NewTransaction(ctx)
+	if err := CommitAll(ctx, txa, txb); !errors.Is(err, os.ErrInvalid) {
+		t.Errorf("CommitAll with transactions from the same database = %v, want os.ErrInvalid", err)
+	}
+	if txa.State() != TxRolledBack || txb.State() != TxRolledBack {
+		t.Errorf("want invalid transactions rolled back, got %v and %v", txa.State(), txb.State())
+	}
+
+	txa, _ = a.NewTransaction(ctx)
+	if err := CommitAll(ctx, txa, nil); !errors.Is(err, os.ErrInvalid) {
+		t.Errorf("CommitAll with a nil transaction = %v, want os.ErrInvalid", err)
+	}
+	if txa.State() != TxRolledBack {
+		t.Errorf("want the transaction rolled back, got %v", txa.State())
+	}
+	if n := len(a.liveTxes); n != 0 {
+		t.Errorf("database has %d live transactions after invalid CommitAll calls, want 0", n)
+	}
+}
+
+func TestCommitAllConcurrent(t *testing.T) {
+	ctx := context.Background()
+
+	a, b := New(WithMutexShards(4)), New(WithMutexShards(4))
+	mustSet(ctx, t, a, "counter", "0")
+	mustSet(ctx, t, b, "counter", "0")
+
+	increment := func(tx *Transaction) {
+		n, _ := strconv.Atoi(mustGet(ctx, t, tx, "counter"))
+		tx.Set(ctx, "counter", strings.NewReader(strconv.Itoa(n+1)))
+	}
+
+	// Concurrent CommitAll calls, which list the databases in different
+	// orders, increment the counters in both databases.
+	const ncallers, nrounds = 4, 50
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	committed := 0
+	for i := 0; i < ncallers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < nrounds; j++ {
+				txa, _ := a.NewTransaction(ctx)
+				txb, _ := b.NewTransaction(ctx)
+				increment(txa)
+				increment(txb)
+				txes := []*Transaction{txa, txb}
+				if (i+j)%2 == 1 {
+					txes = []*Transaction{txb, txa}
+				}
+				if err := CommitAll(ctx, txes...); err == nil {
+					mu.Lock()
+					committed++
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	want := strconv.Itoa(committed)
+	for _, db := range []*Database{a, b} {
+		snap, _ := db.NewSnapshot(ctx)
+		if v := mustGet(ctx, t, snap, "counter"); v != want {
+			t.Errorf("want counter %s after %d atomic commits, got %s", want, committed, v)
+		}
+		snap.Discard(ctx)
+	}
+	if committed == 0 {
+		t.Errorf("no CommitAll call has succeeded")
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"slices"
+	"sync/atomic"
+	"time"
+)
+
+// histogramBounds are the inclusive upper bounds of the buckets of the commit
+// phase histograms.
+var histogramBounds = [...]time.Duration{
+	time.Microsecond,
+	4 * time.Microsecond,
+	16 * time.Microsecond,
+	64 * time.Microsecond,
+	256 * time.Microsecond,
+	time.Millisecond,
+	4 * time.Millisecond,
+	16 * time.Millisecond,
+	64 * time.Millisecond,
+	256 * time.Millisecond,
+	time.Second,
+}
+
+// Histogram holds the distribution of the durations of a commit phase.
+type Histogram struct {
+	// Bounds are the inclusive upper bounds of the buckets in the ascending
+	// order.
+	Bounds []time.Duration
+
+	// Counts holds the number of durations in each bucket, with an extra
+	// count at the end for the durations larger than the last bound.
+	Counts []int64
+
+	// Count and Sum are the number and the total of all durations.
+	Count int64
+	Sum   time.Duration
+}
+
+// Stats holds the durations of the commit pipeline phases for the commits
+// with updates since the database was created or the stats were reset.
+type Stats struct {
+	// Validate is the time spent checking the reads and the scanned ranges
+	// against the concurrent transactions.
+	Validate Histogram
+
+	// WriteCheck is the time spent checking the write-write conflicts
+	// against the current database state.
+	WriteCheck Histogram
+
+	// Compact is the time spent preparing the new versions of the updated
+	// keys, which is dominated by the compaction of their older versions.
+	Compact Histogram
+
+	// Apply is the time spent storing the new versions of the updated keys
+	// and updating the indexes.
+	Apply Histogram
+}
+
+// histogram is a fixed bucket histogram updated with atomic counters.
+type histogram struct {
+	counts [len(histogramBounds) + 1]atomic.Int64
+	sum    atomic.Int64
+}
+
+func (h *histogram) observe(d time.Duration) {
+	i := 0
+	for i < len(histogramBounds) && d > histogramBounds[i] {
+		i++
+	}
+	h.counts[i].Add(1)
+	h.sum.Add(int64(d))
+}
+
+func (h *histogram) load() Histogram {
+	v := Histogram{
+		Bounds: slices.Clone(histogramBounds[:]),
+		Counts: make([]int64, len(h.counts)),
+		Sum:    time.Duration(h.sum.Load()),
+	}
+	for i := range h.counts {
+		v.Counts[i] = h.counts[i].Load()
+		v.Count += v.Counts[i]
+	}
+	return v
+}
+
+func (h *histogram) reset() {
+	for i := range h.counts {
+		h.counts[i].Store(0)
+	}
+	h.sum.Store(0)
+}
+
+// commitStats holds the histograms of the commit pipeline phases.
+type commitStats struct {
+	validate, writeCheck, compact, apply histogram
+}
+
+// Stats returns the durations of the commit pipeline phases. Histograms are
+// updated with atomic counters without any locks, so the histograms of a
+// commit in progress may be observed partially updated.
+func (d *Database) Stats() Stats {
+	return Stats{
+		Validate:   d.commitStats.validate.load(),
+		WriteCheck: d.commitStats.writeCheck.load(),
+		Compact:    d.commitStats.compact.load(),
+		Apply:      d.commitStats.apply.load(),
+	}
+}
+
+// ResetStats clears the durations of the commit pipeline phases.
+func (d *Database) ResetStats() {
+	d.commitStats.validate.reset()
+	d.commitStats.writeCheck.reset()
+	d.commitStats.compact.reset()
+	d.commitStats.apply.reset()
+}
@@ -0,0 +1,73 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	ctx := context.Background()
+
+	db := New()
+	const n = 10
+	for i := 0; i < n; i++ {
+		if err := setKey(ctx, db, fmt.Sprintf("key%d", i%3), "value"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Read-only commits do not go through the pipeline.
+	tx, _ := db.NewTransaction(ctx)
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	stats := db.Stats()
+	for name, h := range map[string]Histogram{
+		"Validate":   stats.Validate,
+		"WriteCheck": stats.WriteCheck,
+		"Compact":    stats.Compact,
+		"Apply":      stats.Apply,
+	} {
+		if h.Count != n {
+			t.Errorf("%s: want %d durations, got %d", name, n, h.Count)
+		}
+		if len(h.Counts) != len(h.Bounds)+1 {
+			t.Errorf("%s: want %d buckets, got %d", name, len(h.Bounds)+1, len(h.Counts))
+		}
+		var total int64
+		for _, c := range h.Counts {
+			total += c
+		}
+		if total != h.Count {
+			t.Errorf("%s: bucket counts add up to %d, want %d", name, total, h.Count)
+		}
+		if h.Sum <= 0 {
+			t.Errorf("%s: want a positive sum, got %v", name, h.Sum)
+		}
+	}
+
+	// Bounds are copied, so that callers cannot modify the shared bounds.
+	stats.Apply.Bounds[0] = time.Hour
+	if h := db.Stats().Apply; h.Bounds[0] == time.Hour {
+		t.Errorf("modifying the returned Bounds changed the histogram bounds")
+	}
+
+	db.ResetStats()
+	stats = db.Stats()
+	if h := stats.Apply; h.Count != 0 || h.Sum != 0 {
+		t.Errorf("Apply after reset: want no durations, got %d with sum %v", h.Count, h.Sum)
+	}
+
+	var h histogram
+	for _, d := range []time.Duration{0, time.Microsecond, time.Microsecond + 1, time.Hour} {
+		h.observe(d)
+	}
+	got := h.load().Counts
+	if got[0] != 2 || got[1] != 1 || got[len(got)-1] != 1 {
+		t.Errorf("bucket counts: got %v", got)
+	}
+}
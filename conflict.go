@@ -0,0 +1,32 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+// ErrConflict is the error returned by the commits that conflict with the
+// committed transactions.
+type ErrConflict struct {
+	// Keys holds the conflicting keys in the sorted order.
+	Keys []string
+
+	// TxID is the id of the committed transaction that the commit conflicts
+	// with. It is zero when the transaction is not known, e.g., for the
+	// write-write conflicts, which are detected from the database state.
+	TxID uint64
+
+	// Range is the range scanned or locked by either transaction, which holds
+	// the conflicting keys, for the conflicts detected through the ranges. It
+	// is nil for the conflicts on the individual keys.
+	Range *Range
+
+	// Conflicts holds all individual conflicts, in the order they are
+	// detected, for the transactions reporting all conflicts with the
+	// TxOptions.ReportAllConflicts option. Keys holds the keys from all of
+	// them in that case.
+	Conflicts []*ErrConflict
+
+	msg string
+}
+
+func (e *ErrConflict) Error() string {
+	return e.msg
+}
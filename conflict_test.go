@@ -0,0 +1,75 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestConflictDiagnostics(t *testing.T) {
+	ctx := context.Background()
+
+	db := New()
+	mustSet(ctx, t, db, "aa", "1")
+
+	// Scanned range conflicts with a phantom insert.
+	tx, _ := db.NewTransaction(ctx)
+	defer tx.Rollback(ctx)
+	if _, err := tx.CountPhantomSafe(ctx, "a", "b"); err != nil {
+		t.Fatal(err)
+	}
+	tx.Set(ctx, "count", strings.NewReader("1"))
+
+	other, _ := db.NewTransaction(ctx)
+	other.Set(ctx, "ab3", strings.NewReader("1"))
+	other.Set(ctx, "c", strings.NewReader("1"))
+	if err := other.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	var cerr *ErrConflict
+	if err := tx.Commit(ctx); !errors.As(err, &cerr) {
+		t.Fatalf("commit after a phantom insert = %v, want an *ErrConflict", err)
+	}
+	if !reflect.DeepEqual(cerr.Keys, []string{"ab3"}) || cerr.TxID != other.ID() {
+		t.Errorf("conflict keys, tx = %q, %d, want [ab3], %d", cerr.Keys, cerr.TxID, other.ID())
+	}
+	if cerr.Range == nil || *cerr.Range != (Range{Begin: "a", End: "b"}) {
+		t.Errorf("conflict range = %v, want [a, b)", cerr.Range)
+	}
+	if !strings.Contains(cerr.Error(), "[a, b)") {
+		t.Errorf("conflict error %q doesn't name the range", cerr)
+	}
+
+	// Locked range conflicts name the lock, and key conflicts have no range.
+	tx, _ = db.NewTransactionWithOptions(ctx, TxOptions{ReportAllConflicts: true})
+	defer tx.Rollback(ctx)
+	mustGet(ctx, t, tx, "aa")
+	tx.LockRange(ctx, "x", "")
+	tx.Set(ctx, "count", strings.NewReader("2"))
+
+	other, _ = db.NewTransaction(ctx)
+	other.Set(ctx, "aa", strings.NewReader("2"))
+	other.Set(ctx, "xyz", strings.NewReader("1"))
+	if err := other.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tx.Commit(ctx); !errors.As(err, &cerr) {
+		t.Fatalf("commit after conflicting updates = %v, want an *ErrConflict", err)
+	}
+	if !reflect.DeepEqual(cerr.Keys, []string{"aa", "xyz"}) || len(cerr.Conflicts) != 2 {
+		t.Fatalf("conflict keys = %q with %d conflicts, want [aa xyz] with 2 conflicts", cerr.Keys, len(cerr.Conflicts))
+	}
+	read, locked := cerr.Conflicts[0], cerr.Conflicts[1]
+	if read.Range != nil || read.TxID != other.ID() || !reflect.DeepEqual(read.Keys, []string{"aa"}) {
+		t.Errorf("read conflict = %+v, want key aa by tx %d without a range", read, other.ID())
+	}
+	if locked.Range == nil || *locked.Range != (Range{Begin: "x"}) || locked.TxID != other.ID() {
+		t.Errorf("locked range conflict = %+v, want range [x, ) by tx %d", locked, other.ID())
+	}
+}
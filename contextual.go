@@ -0,0 +1,61 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"context"
+)
+
+// ContextualDB is a database handle that carries a default context for the
+// operations that take no context argument, e.g., to propagate a tracing span
+// or a deadline through a database session. All other methods of the
+// database are available through the embedded *Database.
+type ContextualDB struct {
+	*Database
+
+	ctx context.Context
+}
+
+// With returns a handle to the database that uses the input context for the
+// operations invoked without a context.
+func (d *Database) With(ctx context.Context) *ContextualDB {
+	return &ContextualDB{Database: d, ctx: ctx}
+}
+
+// Context returns the default context of the handle.
+func (c *ContextualDB) Context() context.Context {
+	return c.ctx
+}
+
+// NewTransaction is similar to Database.NewTransaction, but uses the default
+// context. Returns the context error if the default context is already done.
+func (c *ContextualDB) NewTransaction() (*Transaction, error) {
+	if err := c.ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.Database.NewTransaction(c.ctx)
+}
+
+// NewTransactionWithOptions is similar to Database.NewTransactionWithOptions,
+// but uses the default context.
+func (c *ContextualDB) NewTransactionWithOptions(opts TxOptions) (*Transaction, error) {
+	if err := c.ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.Database.NewTransactionWithOptions(c.ctx, opts)
+}
+
+// NewSnapshot is similar to Database.NewSnapshot, but uses the default
+// context. Returns the context error if the default context is already done.
+func (c *ContextualDB) NewSnapshot() (*Snapshot, error) {
+	if err := c.ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.Database.NewSnapshot(c.ctx)
+}
+
+// Commit commits the transaction with the default context, so that the
+// commit gives up waiting for the locks when the context is done.
+func (c *ContextualDB) Commit(tx *Transaction) error {
+	return tx.Commit(c.ctx)
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestContextualDB(t *testing.T) {
+	ctx := context.Background()
+
+	type key struct{}
+	db := New()
+	cdb := db.With(context.WithValue(ctx, key{}, "span"))
+	if got := cdb.Context().Value(key{}); got != "span" {
+		t.Errorf("default context: want the injected value, got %v", got)
+	}
+
+	tx, err := cdb.NewTransaction()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx.Set(ctx, "a", strings.NewReader("1"))
+	if err := cdb.Commit(tx); err != nil {
+		t.Fatal(err)
+	}
+	snap, err := cdb.NewSnapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer snap.Discard(ctx)
+	if got := mustGet(ctx, t, snap, "a"); got != "1" {
+		t.Errorf("want 1, got %q", got)
+	}
+
+	// Other methods are delegated to the database.
+	if cdb.SizeBytes() != db.SizeBytes() {
+		t.Errorf("SizeBytes must be delegated to the database")
+	}
+
+	// Deadline of the default context is respected.
+	dctx, cancel := context.WithTimeout(ctx, time.Millisecond)
+	defer cancel()
+	<-dctx.Done()
+	cdb = db.With(dctx)
+	if _, err := cdb.NewTransaction(); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("NewTransaction past the deadline: want context.DeadlineExceeded, got %v", err)
+	}
+	if _, err := cdb.NewSnapshot(); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("NewSnapshot past the deadline: want context.DeadlineExceeded, got %v", err)
+	}
+
+	// Commits waiting for the locks give up at the deadline.
+	tx, _ = db.NewTransaction(ctx)
+	tx.Set(ctx, "b", strings.NewReader("2"))
+	pauses := pauseAt(db, hookCommitApply)
+	holder, _ := db.NewTransaction(ctx)
+	holder.Set(ctx, "b", strings.NewReader("holder"))
+	done := make(chan error, 1)
+	go func() { done <- holder.Commit(ctx) }()
+	<-pauses[hookCommitApply].reached
+
+	dctx, cancel = context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if err := db.With(dctx).Commit(tx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Commit past the deadline: want context.DeadlineExceeded, got %v", err)
+	}
+	pauses[hookCommitApply].resume()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -4,16 +4,26 @@ package kvmemdb
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"hash/maphash"
+	"log/slog"
 	"math"
+	"os"
 	"slices"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/visvasity/kvmemdb/mvcc"
 	"github.com/visvasity/syncmap"
 )
 
 type Database struct {
-	mu sync.Mutex
+	// mu protects the transaction bookkeeping and the commit validation. Commits
+	// wait for it with their context, so that they can give up when queued for
+	// too long.
+	mu ctxMutex
 
 	// liveTxes holds list of all live transactions in no-specific order.
 	liveTxes []*Transaction
@@ -21,33 +31,366 @@ type Database struct {
 	// liveSnaps holds list of all live snapshots in no-specific order.
 	liveSnaps []*Snapshot
 
+	// gcFloor, when non-nil, is the sentinel snapshot in the liveSnaps that
+	// pins the GC watermark for SetGCFloor.
+	gcFloor *Snapshot
+
+	// liveGroups holds list of all open transaction groups in no-specific
+	// order.
+	liveGroups []*TransactionGroup
+
 	// concurrentMap holds mapping from a live transaction to the list of other
 	// transactions that have an overlapping, some of which could've already been
 	// committed (i.e., not live).
 	concurrentMap map[*Transaction][]*Transaction
 
+	// sequences holds the last sequence number allocated for each counter key
+	// used with NextSequence. Entries are never removed; see NextSequence.
+	sequences map[string]uint64
+
+	// commitVersion holds the largest version assigned to a transaction that
+	// has passed the commit validation. Updates from versions larger than
+	// maxCommitVersion may still be in the process of being applied.
+	commitVersion int64
+
 	// maxCommitVersion holds the largest tx version that has been committed
-	// successfully.
+	// successfully. It is only advanced in the commit version order after all
+	// updates of a transaction are applied, under the vmu lock.
 	//
 	// New snapshots and transactions will reference the database state at this
 	// version as their private snapshot. Future updates to the database by other
 	// transactions are not invisible to them.
-	maxCommitVersion int64
+	maxCommitVersion atomic.Int64
+
+	// vmu and vcond are used to advance the maxCommitVersion in order.
+	vmu   sync.Mutex
+	vcond sync.Cond
+
+	// seed is the hash seed for mapping keys to shards.
+	seed maphash.Seed
+
+	// shards hold the mutexes protecting updates to the keys in kvs. A key is
+	// protected by the shard at index hash(key) % len(shards). Shards must be
+	// locked in the increasing index order and before the database mutex.
+	shards []ctxMutex
+
+	// maxKeySize, when positive, is the max size of keys in bytes.
+	maxKeySize int
+
+	// chunkSize, when positive, is the max size of the chunks for storing
+	// the large values. Keys in the reserved namespace are not accepted.
+	chunkSize int
+
+	// keySchema holds the validators for all keys updated in the database.
+	keySchema KeySchema
+
+	// now returns the current time.
+	now func() time.Time
+
+	// maxSnapshotAge, when positive, is the duration after which the
+	// snapshots are expired.
+	maxSnapshotAge time.Duration
+
+	// logger receives the diagnostic log records of the database.
+	logger *slog.Logger
+
+	// slowCommitThreshold, when positive, is the duration beyond which commits
+	// and transaction creations are logged as slow.
+	slowCommitThreshold time.Duration
+
+	// stagingObserver, when non-nil, is invoked when a key is staged more than
+	// stagingLimit times in a single transaction.
+	stagingObserver func(txID uint64, key string, count int)
+	stagingLimit    int
+
+	// id is a unique number assigned to the database at creation, which
+	// orders the locking of multiple databases in CommitAll.
+	id uint64
+
+	// lastTxID holds the id of the most recently created transaction.
+	lastTxID uint64
+
+	// lastSnapID holds the id of the most recently created snapshot.
+	lastSnapID uint64
+
+	// bloom, when non-nil, holds a bloom filter with all keys in kvs, sized
+	// for at least bloomKeys keys with the bloomFPR false positive rate. Keys
+	// are added before they are stored in kvs. bloomDeletes and bloomAdds
+	// count the deletions and the new keys since the filter was built.
+	bloom           atomic.Pointer[bloomFilter]
+	bloomKeys       int
+	bloomFPR        float64
+	bloomDeletes    atomic.Int64
+	bloomAdds       atomic.Int64
+	bloomRebuilding atomic.Bool
+
+	// size holds the total size of the live keys and their latest values.
+	size atomic.Int64
+
+	// memoryLimit, when positive, is the max size of the database in bytes,
+	// enforced by evicting the least recently written keys after the commits.
+	// evicting is set while an eviction is in progress. evictions orders the
+	// keys for the eviction.
+	memoryLimit      int64
+	evictionCallback func(key string)
+	evicting         atomic.Bool
+	evictions        evictionQueue
+
+	// groupCommitter, when non-nil, batches the commits for the
+	// WithGroupCommit option.
+	groupCommitter *groupCommitter
+
+	// writeLimits hold the rate limiters for the writes to the key prefixes,
+	// which are applied according to the rateLimitPolicy.
+	writeLimits     []writeLimit
+	rateLimitPolicy RateLimitPolicy
+
+	// commitStats holds the durations of the commit pipeline phases.
+	commitStats commitStats
+
+	// onEvict, when non-nil, holds the function registered with OnEvict.
+	onEvict atomic.Pointer[func(key string, value []byte, version int64)]
+
+	// compactedVersion holds the largest minVersion used by the compactions
+	// that have removed any versions. History of all keys after this version
+	// is retained.
+	compactedVersion atomic.Int64
+
+	// frozen is set when the database is frozen and no longer accepts any
+	// updates.
+	frozen atomic.Bool
+
+	// poisoned, when non-nil, holds the error wrapping ErrCorrupted that all
+	// operations fail with after a commit has panicked.
+	poisoned atomic.Pointer[error]
+
+	// base, when non-nil, is the frozen database that holds the keys that are
+	// not in kvs, for databases created by NewOverlay.
+	base *Database
+
+	// prefixIndex, when non-nil, holds all keys in kvs for enumerating the
+	// keys with a prefix without visiting all keys.
+	prefixIndex *prefixIndex
+
+	// defaultTimeout, when positive, bounds the transaction operations that
+	// are invoked with a context without a deadline.
+	defaultTimeout time.Duration
+
+	// reverseIndex, when non-nil, maps the value hashes to the keys holding
+	// them for FindByValue.
+	reverseIndex *reverseIndex
+
+	// access holds the last access times for live keys when access tracking is
+	// enabled; it is nil otherwise.
+	access *syncmap.Map[string, *keyAccess]
 
 	// kvs holds the successfully committed key-value pairs of the
 	// database. Uncommitted changes are cached in their respective transactions.
 	kvs syncmap.Map[string, *mvcc.MultiValue]
+
+	// idempotency holds the idempotency keys of the recent commits, which are
+	// retained for the idempotencyWindow. It is protected by the mu.
+	idempotency       idempotencyTable
+	idempotencyWindow time.Duration
+
+	// optionErrs holds the errors for the invalid option values, which are
+	// reported by NewWithOptions.
+	optionErrs []error
+
+	// hooks, when non-nil, is invoked at named points in the database
+	// operations. It is meant for tests only to reproduce races
+	// deterministically.
+	hooks func(point string)
+}
+
+// lastDatabaseID holds the id of the most recently created database.
+var lastDatabaseID atomic.Uint64
+
+// New creates an empty in-memory database. Invalid option values are
+// adjusted to the nearest valid values; use NewWithOptions to reject them.
+func New(opts ...Option) *Database {
+	d := newDatabase(opts)
+	d.init()
+	return d
 }
 
-// New creates an empty in-memory database.
-func New() *Database {
-	return &Database{
+// NewWithOptions creates an empty in-memory database, like New, but validates
+// all options first. Returns an error wrapping os.ErrInvalid, which names
+// every invalid option, if any option value is invalid, e.g., a negative size
+// or duration, or a nil function.
+func NewWithOptions(opts ...Option) (*Database, error) {
+	d := newDatabase(opts)
+	if err := errors.Join(d.optionErrs...); err != nil {
+		return nil, err
+	}
+	d.init()
+	return d, nil
+}
+
+// newDatabase creates an empty database with the input options applied, but
+// not initialized with init.
+func newDatabase(opts []Option) *Database {
+	d := &Database{
+		id:            lastDatabaseID.Add(1),
+		mu:            newCtxMutex(),
 		concurrentMap: make(map[*Transaction][]*Transaction),
+		sequences:     make(map[string]uint64),
+		seed:          maphash.MakeSeed(),
+		now:           time.Now,
+		logger:        slog.Default(),
+
+		idempotencyWindow: defaultIdempotencyWindow,
+	}
+	d.vcond.L = &d.vmu
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// init completes the setup of the database after the options are applied.
+func (d *Database) init() {
+	d.optionErrs = nil
+	if len(d.shards) == 0 {
+		d.shards = newCtxMutexes(1)
+	}
+	if d.bloomKeys > 0 || d.bloomFPR > 0 {
+		if d.bloomKeys == 0 {
+			d.bloomKeys = defaultBloomKeys
+		}
+		if d.bloomFPR == 0 {
+			d.bloomFPR = defaultBloomFalsePositiveRate
+		}
+		d.bloom.Store(newBloomFilter(d.bloomKeys, d.bloomFPR))
+	}
+}
+
+// FromMap creates a new database with all key-value pairs from the input map
+// committed at version one, without the overhead of a transaction. Options
+// are validated as with NewWithOptions.
+func FromMap(ctx context.Context, m map[string][]byte, opts ...Option) (*Database, error) {
+	d, err := NewWithOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+	if len(m) == 0 {
+		return d, nil
 	}
+
+	const version = 1
+	for key, value := range m {
+		if err := d.checkKey(key); err != nil {
+			return nil, err
+		}
+		v := mvcc.NewValue(version)
+		v.SetData(string(value))
+		d.addKey(key)
+		d.bloomAdds.Add(1)
+		d.indexKey(key)
+		d.kvs.Store(key, mvcc.NewMultiValue(v))
+		d.touchWrite(key, false)
+	}
+	d.commitVersion = version
+	d.maxCommitVersion.Store(version)
+	d.recountSize()
+	d.rebuildReverseIndex()
+	d.maybeRebuildBloom()
+	return d, nil
 }
 
-// minVersionLocked returns the smallest value version among all live snapshots
-// and transactions with their concurrent counterparts.
+// checkKey returns a non-nil error if the input key is not acceptable for
+// updates to the database.
+func (d *Database) checkKey(key string) error {
+	if err := d.checkKeySize(key); err != nil {
+		return err
+	}
+	if d.hiddenKey(key) {
+		return fmt.Errorf("key %q is in the reserved namespace: %w", key, os.ErrInvalid)
+	}
+	return d.keySchema.Validate(key)
+}
+
+// checkKeySize returns a non-nil error wrapping os.ErrInvalid if the input
+// key is empty or larger than the max key size. It applies to all operations
+// accepting a key.
+func (d *Database) checkKeySize(key string) error {
+	if len(key) == 0 {
+		return os.ErrInvalid
+	}
+	if d.maxKeySize > 0 && len(key) > d.maxKeySize {
+		return fmt.Errorf("key size %d is larger than %d bytes: %w", len(key), d.maxKeySize, os.ErrInvalid)
+	}
+	return nil
+}
+
+// publish advances the maxCommitVersion to the input version after all
+// smaller commit versions are published.
+func (d *Database) publish(version int64) {
+	d.vmu.Lock()
+	defer d.vmu.Unlock()
+
+	for d.maxCommitVersion.Load() != version-1 {
+		d.vcond.Wait()
+	}
+	d.maxCommitVersion.Store(version)
+	d.vcond.Broadcast()
+}
+
+// WaitForVersion blocks until the updates of all transactions committed up to
+// the input version are visible to new snapshots and transactions. Returns
+// the context error if the context is canceled before that.
+func (d *Database) WaitForVersion(ctx context.Context, version int64) error {
+	if d.maxCommitVersion.Load() >= version {
+		return nil
+	}
+
+	stop := context.AfterFunc(ctx, func() {
+		d.vmu.Lock()
+		defer d.vmu.Unlock()
+		d.vcond.Broadcast()
+	})
+	defer stop()
+
+	d.vmu.Lock()
+	defer d.vmu.Unlock()
+
+	for d.maxCommitVersion.Load() < version {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		d.vcond.Wait()
+	}
+	return nil
+}
+
+// lockShards locks the shards for all input keys in the increasing index order
+// and returns the locked shard indices. Returns the context error, with no
+// shards locked, if the context is canceled while waiting for a shard.
+func (d *Database) lockShards(ctx context.Context, keys map[string]*string) ([]int, error) {
+	var indices []int
+	for key := range keys {
+		indices = append(indices, int(maphash.String(d.seed, key)%uint64(len(d.shards))))
+	}
+	slices.Sort(indices)
+	indices = slices.Compact(indices)
+	for j, i := range indices {
+		if err := d.shards[i].LockContext(ctx); err != nil {
+			d.unlockShards(indices[:j])
+			return nil, err
+		}
+	}
+	return indices, nil
+}
+
+// unlockShards unlocks the shards locked by lockShards.
+func (d *Database) unlockShards(indices []int) {
+	for _, i := range indices {
+		d.shards[i].Unlock()
+	}
+}
+
+// minVersionLocked returns the smallest value version among all live snapshots,
+// transaction groups and transactions with their concurrent counterparts.
 func (d *Database) minVersionLocked() int64 {
 	v := int64(math.MaxInt64)
 	for _, tx := range d.liveTxes {
@@ -59,36 +402,145 @@ func (d *Database) minVersionLocked() int64 {
 	for _, s := range d.liveSnaps {
 		v = min(v, s.snapshotVersion)
 	}
+	for _, g := range d.liveGroups {
+		v = min(v, g.snapshotVersion)
+	}
 	return v
 }
 
 // NewSnapshot creates a read-only snapshot of the database.
 func (d *Database) NewSnapshot(ctx context.Context) (*Snapshot, error) {
+	return d.NewSnapshotWithOptions(ctx, SnapshotOptions{})
+}
+
+// NewSnapshotWithOptions creates a read-only snapshot of the database with
+// non-default options.
+func (d *Database) NewSnapshotWithOptions(ctx context.Context, opts SnapshotOptions) (*Snapshot, error) {
+	if err := d.checkPoisoned(); err != nil {
+		return nil, err
+	}
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
+	s := d.newSnapshotLocked(d.maxCommitVersion.Load())
+	if opts.TrackReads {
+		s.trackReads = true
+		s.reads = make(map[string]struct{})
+	}
+	return s, nil
+}
+
+// NewSnapshots creates n read-only snapshots of the database at the same
+// version, so that a fan-out of reads observes a single consistent database
+// state. Each snapshot must be discarded independently.
+func (d *Database) NewSnapshots(ctx context.Context, n int) ([]*Snapshot, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("number of snapshots must be positive: %w", os.ErrInvalid)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	version := d.maxCommitVersion.Load()
+	snaps := make([]*Snapshot, n)
+	for i := range snaps {
+		snaps[i] = d.newSnapshotLocked(version)
+	}
+	return snaps, nil
+}
+
+// newSnapshotLocked creates a live snapshot reading the database state at the
+// input version. Caller must hold the database mutex.
+func (d *Database) newSnapshotLocked(version int64) *Snapshot {
+	d.lastSnapID++
 	s := &Snapshot{
 		db:              d,
-		snapshotVersion: d.maxCommitVersion,
+		id:              d.lastSnapID,
+		snapshotVersion: version,
+		created:         d.now(),
 	}
-	return s, nil
+	d.liveSnaps = append(d.liveSnaps, s)
+	if d.maxSnapshotAge > 0 {
+		s.expiry = time.AfterFunc(d.maxSnapshotAge, func() { d.expireSnapshot(s) })
+	}
+	return s
 }
 
 func (d *Database) closeSnapshot(s *Snapshot) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if s.expiry != nil {
+		s.expiry.Stop()
+	}
 	d.liveSnaps = slices.DeleteFunc(d.liveSnaps, func(v *Snapshot) bool { return v == s })
 	s.db = nil
 }
 
+// expireSnapshot removes a snapshot from the live snapshots when it is older
+// than the maxSnapshotAge. Unlike closeSnapshot, it can run concurrently with
+// the snapshot operations, so the snapshot is only marked as expired.
+//
+// Snapshot is marked before it is removed, so values fetched by the snapshot
+// before it observes the mark cannot have been compacted.
+func (d *Database) expireSnapshot(s *Snapshot) {
+	s.expired.Store(true)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if slices.Contains(d.liveSnaps, s) {
+		d.liveSnaps = slices.DeleteFunc(d.liveSnaps, func(v *Snapshot) bool { return v == s })
+		d.logger.Warn("kvmemdb: snapshot expired", "snapshot", s.String())
+	}
+}
+
 // NewTransaction creates a read-write transaction on the database.
 func (d *Database) NewTransaction(ctx context.Context) (*Transaction, error) {
-	d.mu.Lock()
+	return d.NewTransactionWithOptions(ctx, TxOptions{})
+}
+
+// NewTransactionWithOptions creates a read-write transaction on the database
+// with non-default options.
+func (d *Database) NewTransactionWithOptions(ctx context.Context, opts TxOptions) (*Transaction, error) {
+	if err := d.checkPoisoned(); err != nil {
+		return nil, err
+	}
+	if d.slowCommitThreshold > 0 {
+		start := time.Now()
+		d.mu.Lock()
+		d.logSlowLock(start)
+	} else {
+		d.mu.Lock()
+	}
 	defer d.mu.Unlock()
 
+	d.hook(hookNewTransaction)
+	return d.newTransactionLocked(d.maxCommitVersion.Load(), opts), nil
+}
+
+// newTransactionLocked creates a live transaction reading the database state
+// at the input version. Caller must hold the database mutex.
+func (d *Database) newTransactionLocked(version int64, opts TxOptions) *Transaction {
+	d.lastTxID++
 	t := &Transaction{
-		db:              d,
-		snapshotVersion: d.maxCommitVersion,
-		reads:           make(map[string]*mvcc.Value),
-		writes:          make(map[string]*string),
+		id:                 d.lastTxID,
+		db:                 d,
+		snapshotVersion:    version,
+		trackReads:         !opts.DisableReadTracking,
+		reportAllConflicts: opts.ReportAllConflicts,
+		idempotencyKey:     opts.IdempotencyKey,
+		created:            d.now(),
+		reads:              make(map[string]*mvcc.Value),
+		writes:             make(map[string]*string),
+		stages:             make(map[string]int),
+		ignoredPrefixes:    slices.Clone(opts.IgnoreReadConflictPrefixes),
+	}
+	if len(opts.IgnoreReadConflictKeys) > 0 {
+		t.ignoredKeys = make(map[string]struct{}, len(opts.IgnoreReadConflictKeys))
+		for _, key := range opts.IgnoreReadConflictKeys {
+			t.ignoredKeys[key] = struct{}{}
+		}
 	}
 
 	// Update the live and concurrent transactions mappings.
@@ -97,11 +549,33 @@ func (d *Database) NewTransaction(ctx context.Context) (*Transaction, error) {
 		d.concurrentMap[tx] = append(d.concurrentMap[tx], t)
 	}
 	d.liveTxes = append(d.liveTxes, t)
-	return t, nil
+	return t
 }
 
 func (d *Database) closeTransaction(t *Transaction) {
+	d.hook(hookCloseTransaction)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// Aborted transactions are already removed from the live transactions.
+	if !t.aborted.Load() {
+		d.removeTransactionLocked(t)
+	}
+	t.db = nil
+}
+
+// removeTransactionLocked removes the transaction from the live transactions
+// and from the concurrent transaction lists. Caller must hold the database
+// mutex.
+func (d *Database) removeTransactionLocked(t *Transaction) {
 	d.liveTxes = slices.DeleteFunc(d.liveTxes, func(v *Transaction) bool { return v == t })
 	delete(d.concurrentMap, t)
-	t.db = nil
+	if t.invalidations != nil && !t.invalidationsClosed {
+		close(t.invalidations)
+		t.invalidationsClosed = true
+	}
+	if t.group != nil {
+		t.group.closeTransactionLocked()
+	}
 }
@@ -0,0 +1,47 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"context"
+	"io"
+	"iter"
+)
+
+// All returns an iterator over all key-value pairs of the database in the
+// ascending order of the keys. It is a shorthand for ranging over a snapshot
+// with Ascend.
+//
+// Every range takes a new snapshot of the database, which is discarded when
+// the range ends, even if the loop breaks early. Errors stop the iteration
+// and are reported through *errp, as with the scan methods of the snapshots.
+func (d *Database) All(ctx context.Context, errp *error) iter.Seq2[string, []byte] {
+	return d.Range(ctx, "", "", errp)
+}
+
+// Range is similar to All, but ranges over the key-value pairs between
+// 'begin' and 'end' keys in the ascending order.
+func (d *Database) Range(ctx context.Context, begin, end string, errp *error) iter.Seq2[string, []byte] {
+	return func(yield func(string, []byte) bool) {
+		*errp = nil
+		snap, err := d.NewSnapshot(ctx)
+		if err != nil {
+			*errp = err
+			return
+		}
+		defer snap.Discard(ctx)
+
+		var serr error
+		for key, value := range snap.Ascend(ctx, begin, end, &serr) {
+			data, err := io.ReadAll(value)
+			if err != nil {
+				*errp = err
+				return
+			}
+			if !yield(key, data) {
+				return
+			}
+		}
+		*errp = serr
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"context"
+	"errors"
+	"os"
+	"slices"
+	"testing"
+)
+
+func TestDatabaseAll(t *testing.T) {
+	ctx := context.Background()
+
+	db := New()
+	for _, key := range []string{"c", "a", "d", "b"} {
+		if err := setKey(ctx, db, key, "value-"+key); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var err error
+	var keys []string
+	for key, value := range db.All(ctx, &err) {
+		if want := "value-" + key; string(value) != want {
+			t.Errorf("key %s: want %q, got %q", key, want, value)
+		}
+		keys = append(keys, key)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a", "b", "c", "d"}; !slices.Equal(keys, want) {
+		t.Errorf("All: want %v, got %v", want, keys)
+	}
+
+	keys = nil
+	for key := range db.Range(ctx, "b", "d", &err) {
+		keys = append(keys, key)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"b", "c"}; !slices.Equal(keys, want) {
+		t.Errorf("Range: want %v, got %v", want, keys)
+	}
+
+	// Snapshot is discarded when the loop breaks early.
+	for range db.All(ctx, &err) {
+		break
+	}
+	if n := len(db.LiveSnapshots()); n != 0 {
+		t.Errorf("want no live snapshots after an early break, got %d", n)
+	}
+
+	for range db.Range(ctx, "d", "a", &err) {
+		t.Errorf("invalid range must not yield any pairs")
+	}
+	if !errors.Is(err, os.ErrInvalid) {
+		t.Errorf("invalid range: want os.ErrInvalid, got %v", err)
+	}
+	if n := len(db.LiveSnapshots()); n != 0 {
+		t.Errorf("want no live snapshots after an error, got %d", n)
+	}
+}
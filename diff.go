@@ -0,0 +1,77 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// Change describes the update to a key between two database states. Versions
+// are zero and the deleted flags are true for the keys that do not exist in a
+// state.
+type Change struct {
+	Key string
+
+	OldVersion int64
+	NewVersion int64
+
+	OldDeleted bool
+	NewDeleted bool
+}
+
+// DiffSince returns the changes to all keys between the base snapshot and the
+// latest database state, in the ascending order of the keys. Keys that are
+// deleted or do not exist in both states are not reported, even if they were
+// created and deleted in between.
+//
+// Base snapshot must belong to this database and must not be a layered
+// snapshot. Its versions are retained while it is live, so the changes are
+// always exact.
+func (d *Database) DiffSince(ctx context.Context, base *Snapshot) ([]Change, error) {
+	if base == nil || base.db != d || base.overlay != nil {
+		return nil, fmt.Errorf("base snapshot does not belong to this db: %w", os.ErrInvalid)
+	}
+
+	snap, err := d.NewSnapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer snap.Discard(ctx)
+
+	state := func(s *Snapshot, key string) (version int64, deleted bool) {
+		v := s.fetch(key)
+		if v == nil {
+			return 0, true
+		}
+		return v.Version(), v.IsDeleted()
+	}
+
+	kset := make(map[string]struct{})
+	base.collectKeys(kset, "")
+	snap.collectKeys(kset, "")
+
+	var changes []Change
+	for key := range kset {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if d.hiddenKey(key) {
+			continue
+		}
+		c := Change{Key: key}
+		c.OldVersion, c.OldDeleted = state(base, key)
+		if err := base.check(); err != nil {
+			return nil, err
+		}
+		c.NewVersion, c.NewDeleted = state(snap, key)
+		if c.OldVersion == c.NewVersion || (c.OldDeleted && c.NewDeleted) {
+			continue
+		}
+		changes = append(changes, c)
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	return changes, nil
+}
@@ -0,0 +1,64 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiffSince(t *testing.T) {
+	ctx := context.Background()
+
+	db := New()
+	update := func(sets map[string]string, deletes ...string) int64 {
+		t.Helper()
+		tx, _ := db.NewTransaction(ctx)
+		defer tx.Rollback(ctx)
+		for k, v := range sets {
+			tx.Set(ctx, k, strings.NewReader(v))
+		}
+		for _, k := range deletes {
+			tx.Delete(ctx, k)
+		}
+		if err := tx.Commit(ctx); err != nil {
+			t.Fatal(err)
+		}
+		return tx.CommitVersion()
+	}
+
+	v1 := update(map[string]string{"same": "1", "updated": "1", "deleted": "1", "deleted-twice": "1"})
+	update(nil, "deleted-twice")
+	base, _ := db.NewSnapshot(ctx)
+	defer base.Discard(ctx)
+
+	if changes, err := db.DiffSince(ctx, base); err != nil || len(changes) != 0 {
+		t.Errorf("DiffSince without updates = %v, %v, want no changes", changes, err)
+	}
+
+	v3 := update(map[string]string{"updated": "2", "added": "1", "transient": "1"}, "deleted")
+	update(nil, "transient", "deleted-twice")
+	v5 := update(map[string]string{"late": "1"})
+
+	want := []Change{
+		{Key: "added", OldVersion: 0, NewVersion: v3, OldDeleted: true},
+		{Key: "deleted", OldVersion: v1, NewVersion: v3, NewDeleted: true},
+		{Key: "late", OldVersion: 0, NewVersion: v5, OldDeleted: true},
+		{Key: "updated", OldVersion: v1, NewVersion: v3},
+	}
+	changes, err := db.DiffSince(ctx, base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("DiffSince = %+v, want %+v", changes, want)
+	}
+
+	if _, err := New().DiffSince(ctx, base); !errors.Is(err, os.ErrInvalid) {
+		t.Errorf("DiffSince with a snapshot from another db = %v, want os.ErrInvalid", err)
+	}
+}
@@ -0,0 +1,13 @@
+// Copyright (c) 2025 Visvasity LLC
+
+// Package kvmemdb implements an in-memory key-value database with
+// Serializable Snapshot Isolation transactions.
+//
+// Snapshots and transactions read the database state as of their creation.
+// A successful Commit returns only after the transaction's updates are
+// visible to new snapshots and transactions, so a caller always reads its own
+// writes in the snapshots and transactions it creates after the commit.
+// Commits from other goroutines are visible only when they are ordered before
+// the creation through other means, which Database.WaitForVersion can provide
+// with a known version.
+package kvmemdb
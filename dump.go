@@ -0,0 +1,180 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+
+	"github.com/visvasity/kvmemdb/mvcc"
+)
+
+// dumpFormat identifies the format of the database dumps.
+const dumpFormat = "kvmemdb-dump/1"
+
+// DumpOptions controls the contents of a database dump.
+type DumpOptions struct {
+	// LatestOnly when true, writes only the live values visible at the
+	// snapshot version and skips the deleted keys. Otherwise, all versions
+	// retained at or below the snapshot version are written, including the
+	// deleted versions.
+	LatestOnly bool
+}
+
+// dumpHeader is the first record in a database dump.
+type dumpHeader struct {
+	Format  string `json:"format"`
+	Version int64  `json:"version"`
+}
+
+// dumpRecord is a single version of a key in a database dump.
+type dumpRecord struct {
+	Key     string `json:"key"`
+	Version int64  `json:"version"`
+	Deleted bool   `json:"deleted,omitempty"`
+	Value   []byte `json:"value"`
+}
+
+// Dump writes the key-value pairs visible to the snapshot into the writer as
+// a stream of JSON records in the ascending order of keys. All records belong
+// to the snapshot version, so the dump is consistent even when the database
+// is updated concurrently.
+func (s *Snapshot) Dump(ctx context.Context, w io.Writer, opts DumpOptions) error {
+	if s.db == nil || s.overlay != nil {
+		return os.ErrInvalid
+	}
+
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	if err := enc.Encode(&dumpHeader{Format: dumpFormat, Version: s.snapshotVersion}); err != nil {
+		return err
+	}
+
+	// Internal keys are included, so that the chunked values can be
+	// restored.
+	kset := make(map[string]struct{})
+	s.collectKeys(kset, "")
+	keys := slices.Sorted(maps.Keys(kset))
+
+	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if opts.LatestOnly {
+			v := s.fetch(key)
+			if err := s.check(); err != nil {
+				return err
+			}
+			if v == nil || v.IsDeleted() {
+				continue
+			}
+			if err := enc.Encode(newDumpRecord(key, v)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		mv, ok := s.db.kvs.Load(key)
+		if err := s.check(); err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		for _, v := range mv.Values() {
+			if v.Version() > s.snapshotVersion {
+				break
+			}
+			if err := enc.Encode(newDumpRecord(key, v)); err != nil {
+				return err
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+func newDumpRecord(key string, v *mvcc.Value) *dumpRecord {
+	r := &dumpRecord{
+		Key:     key,
+		Version: v.Version(),
+		Deleted: v.IsDeleted(),
+	}
+	if !r.Deleted {
+		r.Value = []byte(v.Data())
+	}
+	return r
+}
+
+// Restore creates a new database from a dump written by Snapshot.Dump. The new
+// database's commit version is the same as the dumped snapshot's version.
+// Options are validated as with NewWithOptions.
+func Restore(ctx context.Context, r io.Reader, opts ...Option) (*Database, error) {
+	dec := json.NewDecoder(bufio.NewReader(r))
+
+	var header dumpHeader
+	if err := dec.Decode(&header); err != nil {
+		return nil, fmt.Errorf("could not decode dump header: %w", err)
+	}
+	if header.Format != dumpFormat {
+		return nil, fmt.Errorf("unsupported dump format %q: %w", header.Format, os.ErrInvalid)
+	}
+
+	db, err := NewWithOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		var record dumpRecord
+		if err := dec.Decode(&record); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("could not decode dump record: %w", err)
+		}
+		if err := db.checkKey(record.Key); err != nil {
+			return nil, err
+		}
+		if record.Version <= 0 || record.Version > header.Version {
+			return nil, fmt.Errorf("invalid dump record for key %q at version %d: %w", record.Key, record.Version, os.ErrInvalid)
+		}
+
+		v := mvcc.NewValue(record.Version)
+		if record.Deleted {
+			v.Delete()
+		} else {
+			v.SetData(string(record.Value))
+		}
+
+		mv, ok := db.kvs.Load(record.Key)
+		if !ok {
+			db.addKey(record.Key)
+			db.bloomAdds.Add(1)
+			db.indexKey(record.Key)
+			db.kvs.Store(record.Key, mvcc.NewMultiValue(v))
+			continue
+		}
+		if last, _ := mv.Fetch(header.Version); last.Version() >= v.Version() {
+			return nil, fmt.Errorf("dump records for key %q are not in version order: %w", record.Key, os.ErrInvalid)
+		}
+		db.kvs.Store(record.Key, mvcc.Append(mv, v))
+	}
+
+	db.commitVersion = header.Version
+	db.maxCommitVersion.Store(header.Version)
+	db.recountSize()
+	db.rebuildReverseIndex()
+	db.maybeRebuildBloom()
+	return db, nil
+}
@@ -0,0 +1,111 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDumpRestore(t *testing.T) {
+	ctx := context.Background()
+
+	db := New()
+	mustSet(ctx, t, db, "a", "a1")
+	mustSet(ctx, t, db, "b", "b1")
+	mustSet(ctx, t, db, "empty", "")
+
+	// Keep a snapshot alive so that older versions are retained.
+	old, _ := db.NewSnapshot(ctx)
+	defer old.Discard(ctx)
+
+	mustSet(ctx, t, db, "a", "a2")
+	tx, _ := db.NewTransaction(ctx)
+	tx.Delete(ctx, "b")
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	snap, _ := db.NewSnapshot(ctx)
+	defer snap.Discard(ctx)
+
+	// Updates after the snapshot must not be part of the dump.
+	mustSet(ctx, t, db, "a", "a3")
+	mustSet(ctx, t, db, "c", "c1")
+
+	t.Run("LatestOnly", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := snap.Dump(ctx, &buf, DumpOptions{LatestOnly: true}); err != nil {
+			t.Fatal(err)
+		}
+		if n := strings.Count(buf.String(), "\n"); n != 3 {
+			t.Errorf("latest-only dump has %d lines, want 3:\n%s", n, buf.String())
+		}
+
+		rdb, err := Restore(ctx, &buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v := rdb.maxCommitVersion.Load(); v != snap.snapshotVersion {
+			t.Errorf("restored version = %d, want %d", v, snap.snapshotVersion)
+		}
+
+		rsnap, _ := rdb.NewSnapshot(ctx)
+		defer rsnap.Discard(ctx)
+
+		ascend, _ := readAll(ctx, t, rsnap, "", "")
+		if want := []string{"a=a2", "empty="}; !reflect.DeepEqual(ascend, want) {
+			t.Errorf("restored key-value pairs = %v, want %v", ascend, want)
+		}
+	})
+
+	t.Run("FullHistory", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := snap.Dump(ctx, &buf, DumpOptions{}); err != nil {
+			t.Fatal(err)
+		}
+
+		rdb, err := Restore(ctx, &buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rsnap, _ := rdb.NewSnapshot(ctx)
+		defer rsnap.Discard(ctx)
+
+		ascend, _ := readAll(ctx, t, rsnap, "", "")
+		if want := []string{"a=a2", "empty="}; !reflect.DeepEqual(ascend, want) {
+			t.Errorf("restored key-value pairs = %v, want %v", ascend, want)
+		}
+
+		// Older versions and the tombstone are restored as well.
+		rold := &Snapshot{db: rdb, snapshotVersion: old.snapshotVersion}
+		ascend, _ = readAll(ctx, t, rold, "", "")
+		if want := []string{"a=a1", "b=b1", "empty="}; !reflect.DeepEqual(ascend, want) {
+			t.Errorf("restored older key-value pairs = %v, want %v", ascend, want)
+		}
+		if mv, ok := rdb.kvs.Load("b"); !ok {
+			t.Errorf("deleted key is not restored")
+		} else if v, _ := mv.Fetch(snap.snapshotVersion); !v.IsDeleted() {
+			t.Errorf("restored value %v must be deleted", v)
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		if _, err := Restore(ctx, strings.NewReader(`{"format":"unknown"}`)); !errors.Is(err, os.ErrInvalid) {
+			t.Errorf("want os.ErrInvalid, got %v", err)
+		}
+		input := `{"format":"kvmemdb-dump/1","version":2}
+{"key":"a","version":2,"value":"YQ=="}
+{"key":"a","version":1,"value":"YQ=="}
+`
+		if _, err := Restore(ctx, strings.NewReader(input)); !errors.Is(err, os.ErrInvalid) {
+			t.Errorf("want os.ErrInvalid, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,173 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/visvasity/kv"
+	"github.com/visvasity/kv/kvutil"
+)
+
+func TestEmptyValues(t *testing.T) {
+	ctx := context.Background()
+
+	set := func(key, value string) func(context.Context, kv.ReadWriter) error {
+		return func(ctx context.Context, rw kv.ReadWriter) error {
+			return rw.Set(ctx, key, strings.NewReader(value))
+		}
+	}
+	del := func(key string) func(context.Context, kv.ReadWriter) error {
+		return func(ctx context.Context, rw kv.ReadWriter) error {
+			return rw.Delete(ctx, key)
+		}
+	}
+
+	type step = func(context.Context, kv.ReadWriter) error
+
+	tests := []struct {
+		name   string
+		steps  []step
+		exists bool
+		value  string
+	}{
+		{
+			name:   "set empty",
+			steps:  []step{set("key", "")},
+			exists: true,
+		},
+		{
+			name:   "overwrite with empty",
+			steps:  []step{set("key", "value"), set("key", "")},
+			exists: true,
+		},
+		{
+			name:   "delete then set empty",
+			steps:  []step{set("key", "value"), del("key"), set("key", "")},
+			exists: true,
+		},
+		{
+			name:   "set empty then delete",
+			steps:  []step{set("key", ""), del("key")},
+			exists: false,
+		},
+		{
+			name:   "set empty then overwrite",
+			steps:  []step{set("key", ""), set("key", "value")},
+			exists: true,
+			value:  "value",
+		},
+		{
+			name: "set empty and delete in one tx",
+			steps: []step{
+				func(ctx context.Context, rw kv.ReadWriter) error {
+					if err := rw.Set(ctx, "key", strings.NewReader("")); err != nil {
+						return err
+					}
+					return rw.Delete(ctx, "key")
+				},
+			},
+			exists: false,
+		},
+		{
+			name: "delete and set empty in one tx",
+			steps: []step{
+				set("key", "value"),
+				func(ctx context.Context, rw kv.ReadWriter) error {
+					if err := rw.Delete(ctx, "key"); err != nil {
+						return err
+					}
+					return rw.Set(ctx, "key", strings.NewReader(""))
+				},
+			},
+			exists: true,
+		},
+		{
+			name: "many empty versions compacted",
+			steps: []step{
+				set("key", ""), set("key", ""), set("key", ""), set("key", ""),
+			},
+			exists: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mdb := New()
+			db := kv.DatabaseFrom(mdb.NewTransaction, mdb.NewSnapshot)
+
+			// Hold a snapshot to observe the state before all steps.
+			before, err := mdb.NewSnapshot(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer before.Discard(ctx)
+
+			for i, step := range tt.steps {
+				if err := kvutil.WithReadWriter(ctx, db, step); err != nil {
+					t.Fatalf("step %d failed: %v", i, err)
+				}
+			}
+
+			if _, err := before.Get(ctx, "key"); !errors.Is(err, os.ErrNotExist) {
+				t.Errorf("old snapshot: want os.ErrNotExist, got %v", err)
+			}
+
+			check := func(t *testing.T, r kv.Reader) {
+				value, err := r.Get(ctx, "key")
+				if !tt.exists {
+					if !errors.Is(err, os.ErrNotExist) {
+						t.Errorf("Get: want os.ErrNotExist, got %v", err)
+					}
+				} else if err != nil {
+					t.Errorf("Get: unexpected error %v", err)
+				} else if data, _ := io.ReadAll(value); string(data) != tt.value {
+					t.Errorf("Get: got %q, want %q", data, tt.value)
+				}
+
+				var scanned, ascended, descended []string
+				var scanErr, ascendErr, descendErr error
+				for k, v := range r.Scan(ctx, &scanErr) {
+					if data, _ := io.ReadAll(v); string(data) != tt.value {
+						t.Errorf("Scan: got %q, want %q", data, tt.value)
+					}
+					scanned = append(scanned, k)
+				}
+				for k := range r.Ascend(ctx, "", "", &ascendErr) {
+					ascended = append(ascended, k)
+				}
+				for k := range r.Descend(ctx, "", "", &descendErr) {
+					descended = append(descended, k)
+				}
+				if err := errors.Join(scanErr, ascendErr, descendErr); err != nil {
+					t.Fatal(err)
+				}
+				want := 0
+				if tt.exists {
+					want = 1
+				}
+				if len(scanned) != want || len(ascended) != want || len(descended) != want {
+					t.Errorf("scans returned %v, %v and %v, want %d keys", scanned, ascended, descended, want)
+				}
+			}
+
+			t.Run("snapshot", func(t *testing.T) {
+				kvutil.WithReader(ctx, db, func(ctx context.Context, r kv.Reader) error {
+					check(t, r)
+					return nil
+				})
+			})
+			t.Run("transaction", func(t *testing.T) {
+				kvutil.WithReadWriter(ctx, db, func(ctx context.Context, rw kv.ReadWriter) error {
+					check(t, rw)
+					return nil
+				})
+			})
+		})
+	}
+}
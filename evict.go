@@ -0,0 +1,309 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"container/heap"
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/visvasity/kvmemdb/mvcc"
+)
+
+// SizeBytes returns the total size of the live keys and their latest values
+// in bytes. Older versions retained for the live snapshots and transactions
+// and the bookkeeping overheads are not included.
+func (d *Database) SizeBytes() int64 {
+	return d.size.Load()
+}
+
+// entrySize returns the size of a key-value pair counted in SizeBytes. A nil
+// value stands for a deleted key, which has zero size.
+func entrySize(key string, value *string) int64 {
+	if value == nil {
+		return 0
+	}
+	return int64(len(key) + len(*value))
+}
+
+// latestSize returns the size of the latest value of a key in the multi-value.
+func latestSize(key string, mv *mvcc.MultiValue) int64 {
+	vs := mv.Values()
+	if latest := vs[len(vs)-1]; !latest.IsDeleted() {
+		data := latest.Data()
+		return entrySize(key, &data)
+	}
+	return 0
+}
+
+// recountSize recomputes the SizeBytes from the key-value pairs. It is only
+// used when the database is populated without the transactions.
+func (d *Database) recountSize() {
+	var size int64
+	for key, mv := range d.kvs.Range {
+		size += latestSize(key, mv)
+	}
+	d.size.Store(size)
+	d.resetEvictions()
+}
+
+// evictionEntry is a write of a live key in the eviction queue.
+type evictionEntry struct {
+	key     string
+	version int64
+}
+
+// evictionHeap is a min-heap of the eviction entries by their versions.
+type evictionHeap []evictionEntry
+
+func (h evictionHeap) Len() int           { return len(h) }
+func (h evictionHeap) Less(i, j int) bool { return h[i].version < h[j].version }
+func (h evictionHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *evictionHeap) Push(x any)        { *h = append(*h, x.(evictionEntry)) }
+
+func (h *evictionHeap) Pop() any {
+	old := *h
+	e := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return e
+}
+
+// evictionQueue orders the writes of the live keys by their commit versions,
+// so that the least recently written keys are found without scanning the
+// database. Every write adds an entry and the entries are removed lazily: an
+// entry is stale once its key is written again or deleted.
+type evictionQueue struct {
+	mu      sync.Mutex
+	entries evictionHeap
+
+	// compactAt is the number of entries at which the stale entries are
+	// dropped. Stale entries accumulate at most one per write, so dropping
+	// them when the queue doubles keeps the amortized cost per write
+	// logarithmic.
+	compactAt int
+
+	// invalid is set when the database is populated without the
+	// transactions. Queue is rebuilt from the database before its next use.
+	invalid bool
+}
+
+// minEvictionCompaction is the min number of entries in the eviction queue
+// before its stale entries are dropped.
+const minEvictionCompaction = 1024
+
+// noteWrite adds the latest write of a key to the eviction queue, if the
+// memory limit is enabled and the key is live. It must be called after the
+// multi-value is stored, so that the entry is never seen as stale.
+func (d *Database) noteWrite(key string, mv *mvcc.MultiValue) {
+	if d.memoryLimit <= 0 || d.hiddenKey(key) {
+		return
+	}
+	vs := mv.Values()
+	latest := vs[len(vs)-1]
+	if latest.IsDeleted() {
+		return
+	}
+	q := &d.evictions
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.invalid {
+		return
+	}
+	heap.Push(&q.entries, evictionEntry{key, latest.Version()})
+	if len(q.entries) >= max(q.compactAt, minEvictionCompaction) {
+		q.entries = slices.DeleteFunc(q.entries, func(e evictionEntry) bool { return !d.liveEntry(e) })
+		heap.Init(&q.entries)
+		q.compactAt = 2 * len(q.entries)
+	}
+}
+
+// resetEvictions requires a rebuild of the eviction queue before its next use.
+func (d *Database) resetEvictions() {
+	q := &d.evictions
+	q.mu.Lock()
+	q.entries, q.invalid = nil, true
+	q.mu.Unlock()
+}
+
+// liveEntry returns true if the entry is the latest write of a live key.
+func (d *Database) liveEntry(e evictionEntry) bool {
+	mv, ok := d.kvs.Load(e.key)
+	if !ok {
+		return false
+	}
+	vs := mv.Values()
+	latest := vs[len(vs)-1]
+	return !latest.IsDeleted() && latest.Version() == e.version
+}
+
+// popEvictionsLocked removes the oldest live entries from the eviction queue
+// until their sizes add up to the input bytes. Caller must hold the queue
+// mutex.
+func (d *Database) popEvictionsLocked(bytes int64) []evictionEntry {
+	q := &d.evictions
+	if q.invalid {
+		for key, mv := range d.kvs.Range {
+			if d.hiddenKey(key) {
+				// Chunks are deleted along with their keys.
+				continue
+			}
+			vs := mv.Values()
+			if latest := vs[len(vs)-1]; !latest.IsDeleted() {
+				q.entries = append(q.entries, evictionEntry{key, latest.Version()})
+			}
+		}
+		heap.Init(&q.entries)
+		q.compactAt, q.invalid = 2*len(q.entries), false
+	}
+
+	var popped []evictionEntry
+	for bytes > 0 && len(q.entries) > 0 {
+		e := heap.Pop(&q.entries).(evictionEntry)
+		if !d.liveEntry(e) {
+			continue
+		}
+		mv, _ := d.kvs.Load(e.key)
+		bytes -= latestSize(e.key, mv)
+		popped = append(popped, e)
+	}
+	return popped
+}
+
+// maybeEvict deletes the least recently written keys when the database size
+// is larger than the memory limit, until it fits within the limit. Keys are
+// taken from the eviction queue, so the cost does not grow with the database
+// size. Evictions are committed through a transaction, so keys updated
+// concurrently are not evicted; they are retried after the next commit.
+// Caller must not hold any locks.
+func (d *Database) maybeEvict(ctx context.Context) {
+	if d.memoryLimit <= 0 || d.size.Load() <= d.memoryLimit {
+		return
+	}
+	if !d.evicting.CompareAndSwap(false, true) {
+		return
+	}
+	defer d.evicting.Store(false)
+
+	// Eviction is not canceled along with the commit that triggered it.
+	ctx = context.WithoutCancel(ctx)
+	tx, err := d.NewTransaction(ctx)
+	if err != nil {
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	q := &d.evictions
+	q.mu.Lock()
+	popped := d.popEvictionsLocked(d.size.Load() - d.memoryLimit)
+	q.mu.Unlock()
+
+	// Entries that are not evicted are returned to the queue.
+	var evicted, retained []evictionEntry
+	defer func() {
+		q.mu.Lock()
+		for _, e := range retained {
+			heap.Push(&q.entries, e)
+		}
+		q.mu.Unlock()
+	}()
+
+	for _, e := range popped {
+		// Keys are read, so that the eviction conflicts with the concurrent
+		// updates to them. Writes not yet visible to the transaction are
+		// retried later.
+		if v := tx.fetch(e.key); v == nil || v.IsDeleted() || v.Version() != e.version {
+			retained = append(retained, e)
+			continue
+		}
+		if err := tx.Delete(ctx, e.key); err != nil {
+			retained = append(retained, e)
+			continue
+		}
+		evicted = append(evicted, e)
+	}
+	if len(evicted) == 0 {
+		return
+	}
+	if err := tx.Commit(ctx); err != nil {
+		retained = append(retained, evicted...)
+		return
+	}
+	if d.evictionCallback != nil {
+		for _, e := range evicted {
+			d.evictionCallback(e.key)
+		}
+	}
+}
+
+// droppedEntry holds the last live value of a deleted key, which is removed
+// from the database by the compaction.
+type droppedEntry struct {
+	key     string
+	value   []byte
+	version int64
+}
+
+// droppedValue returns the last live value of the key in the multi-value
+// before the compaction and true, if the compaction removed it and the key is
+// deleted afterwards. Compacted multi-value is nil when the key is removed
+// entirely.
+func droppedValue(key string, before, after *mvcc.MultiValue) (droppedEntry, bool) {
+	if after != nil {
+		for _, v := range after.Values() {
+			if !v.IsDeleted() {
+				return droppedEntry{}, false
+			}
+		}
+	}
+	vs := before.Values()
+	for i := len(vs) - 1; i >= 0; i-- {
+		if !vs[i].IsDeleted() {
+			return droppedEntry{key: key, value: []byte(vs[i].Data()), version: vs[i].Version()}, true
+		}
+	}
+	return droppedEntry{}, false
+}
+
+// OnEvict registers a function that is called when the value of a deleted key
+// is physically removed from the database, i.e., when the compaction drops the
+// last live value of the key after no snapshot or transaction can read it.
+// Value and version are the removed value and its commit version. Keys evicted
+// because of the memory limit are reported the same way.
+//
+// Compaction only runs on the keys updated by a commit, so the value of a
+// deleted key is removed by a later update to the key, e.g., another
+// deletion. Deletion markers are retained for DeletedSince. Expiring keys are
+// not supported by the database.
+//
+// Function is called without holding any database locks, after the commit
+// that removed the value is published, so it can use the database. Panics
+// from the function are recovered and logged. A nil function removes the
+// registered function.
+func (d *Database) OnEvict(fn func(key string, value []byte, version int64)) {
+	if fn == nil {
+		d.onEvict.Store(nil)
+		return
+	}
+	d.onEvict.Store(&fn)
+}
+
+// notifyDropped reports the values dropped while applying the transaction's
+// updates to the OnEvict function. Caller must not hold any locks.
+func (d *Database) notifyDropped(tx *Transaction) {
+	fn := d.onEvict.Load()
+	if fn == nil {
+		return
+	}
+	for _, e := range tx.dropped {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					d.logger.Error("kvmemdb: OnEvict function panicked", "key", e.key, "panic", r)
+				}
+			}()
+			(*fn)(e.key, e.value, e.version)
+		}()
+	}
+}
@@ -0,0 +1,216 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestMemoryLimit(t *testing.T) {
+	ctx := context.Background()
+
+	const limit = 1024
+	var evicted []string
+	db := New(WithMemoryLimit(limit), WithEvictionCallback(func(key string) {
+		evicted = append(evicted, key)
+	}))
+
+	// Insert 10KB of data in 100 byte key-value pairs.
+	value := strings.Repeat("x", 94)
+	for i := 0; i < 100; i++ {
+		mustSet(ctx, t, db, fmt.Sprintf("key%03d", i), value)
+		if size := db.SizeBytes(); size > limit {
+			t.Fatalf("SizeBytes = %d after commit %d, want at most %d", size, i, limit)
+		}
+	}
+
+	// Only the most recently written keys are retained.
+	snap, _ := db.NewSnapshot(ctx)
+	defer snap.Discard(ctx)
+	var live []string
+	var err error
+	for k := range snap.Ascend(ctx, "", "", &err) {
+		live = append(live, k)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(live) != 10 || live[0] != "key090" || live[9] != "key099" {
+		t.Errorf("live keys = %q, want key090 to key099", live)
+	}
+	if len(evicted) != 90 || evicted[0] != "key000" || evicted[89] != "key089" {
+		t.Errorf("evicted keys = %q, want key000 to key089 in order", evicted)
+	}
+	if size := db.SizeBytes(); size != 1000 {
+		t.Errorf("SizeBytes = %d, want 1000", size)
+	}
+
+	// Updating a key makes it the most recently written.
+	mustSet(ctx, t, db, "key090", value)
+	mustSet(ctx, t, db, "key100", value)
+	if got := evicted[len(evicted)-1]; got != "key091" {
+		t.Errorf("evicted key = %q, want key091", got)
+	}
+}
+
+func TestEvictionQueue(t *testing.T) {
+	ctx := context.Background()
+
+	// Databases populated without the transactions are evicted as well.
+	m := make(map[string][]byte)
+	for i := 0; i < 100; i++ {
+		m[fmt.Sprintf("key%03d", i)] = []byte(strings.Repeat("x", 94))
+	}
+	db, err := FromMap(ctx, m, WithMemoryLimit(1024))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustSet(ctx, t, db, "new", "value")
+	if size := db.SizeBytes(); size > 1024 {
+		t.Errorf("SizeBytes = %d after the first commit, want at most 1024", size)
+	}
+	snap, _ := db.NewSnapshot(ctx)
+	if got := mustGet(ctx, t, snap, "new"); got != "value" {
+		t.Errorf("new = %q, want value", got)
+	}
+	snap.Discard(ctx)
+
+	// Stale entries are dropped without any evictions.
+	db = New(WithMemoryLimit(1 << 20))
+	for i := 0; i < 10*minEvictionCompaction; i++ {
+		mustSet(ctx, t, db, fmt.Sprintf("key%d", i%10), "value")
+	}
+	if n := len(db.evictions.entries); n >= minEvictionCompaction {
+		t.Errorf("eviction queue has %d entries for 10 keys, want less than %d", n, minEvictionCompaction)
+	}
+}
+
+func BenchmarkMemoryLimit(b *testing.B) {
+	ctx := context.Background()
+
+	// Cost of a commit that evicts a key must not grow with the database.
+	for _, n := range []int{1000, 100000} {
+		b.Run(fmt.Sprintf("keys=%d", n), func(b *testing.B) {
+			db := New(WithMemoryLimit(int64(n) * 15))
+			for i := 0; i < n; i++ {
+				mustSet(ctx, b, db, fmt.Sprintf("key%07d", i), "value")
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				mustSet(ctx, b, db, fmt.Sprintf("new%07d", i), "value")
+			}
+		})
+	}
+}
+
+func TestSizeBytes(t *testing.T) {
+	ctx := context.Background()
+
+	db, err := FromMap(ctx, map[string][]byte{"a": []byte("12345"), "bb": nil})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := db.SizeBytes(); size != 8 {
+		t.Errorf("SizeBytes = %d after FromMap, want 8", size)
+	}
+
+	mustSet(ctx, t, db, "a", "1")
+	tx, _ := db.NewTransaction(ctx)
+	tx.Delete(ctx, "bb")
+	tx.Delete(ctx, "missing")
+	tx.Set(ctx, "ccc", strings.NewReader("12"))
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if size := db.SizeBytes(); size != 7 {
+		t.Errorf("SizeBytes = %d, want 7", size)
+	}
+}
+
+func TestOnEvict(t *testing.T) {
+	ctx := context.Background()
+
+	type entry struct {
+		key, value string
+		version    int64
+	}
+	var dropped []entry
+	h := new(recordHandler)
+	db := New(WithLogger(slog.New(h)))
+	db.OnEvict(func(key string, value []byte, version int64) {
+		dropped = append(dropped, entry{key, string(value), version})
+	})
+
+	setKey := func(key, value string) int64 {
+		tx, _ := db.NewTransaction(ctx)
+		tx.Set(ctx, key, strings.NewReader(value))
+		if err := tx.Commit(ctx); err != nil {
+			t.Fatal(err)
+		}
+		return tx.CommitVersion()
+	}
+	deleteKey := func(key string) {
+		tx, _ := db.NewTransaction(ctx)
+		tx.Delete(ctx, key)
+		if err := tx.Commit(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Deleted value is removed by the next update to the key.
+	version := setKey("a", "1")
+	deleteKey("a")
+	if len(dropped) != 0 {
+		t.Errorf("deleted value is readable by the older versions: want no evictions, got %v", dropped)
+	}
+	deleteKey("a")
+	if want := []entry{{"a", "1", version}}; fmt.Sprint(dropped) != fmt.Sprint(want) {
+		t.Errorf("want %v, got %v", want, dropped)
+	}
+
+	// Overwritten values are not reported.
+	dropped = nil
+	setKey("b", "1")
+	version = setKey("b", "2")
+	setKey("b", "3")
+	if len(dropped) != 0 {
+		t.Errorf("overwritten values: want no evictions, got %v", dropped)
+	}
+
+	// Deleted values readable by a snapshot are retained.
+	snap, _ := db.NewSnapshot(ctx)
+	deleteKey("b")
+	deleteKey("b")
+	if len(dropped) != 0 {
+		t.Errorf("value readable by a snapshot: want no evictions, got %v", dropped)
+	}
+	snap.Discard(ctx)
+	deleteKey("b")
+	if want := []entry{{"b", "3", version + 1}}; fmt.Sprint(dropped) != fmt.Sprint(want) {
+		t.Errorf("want %v, got %v", want, dropped)
+	}
+
+	// Panics are recovered and the database remains usable.
+	db.OnEvict(func(key string, value []byte, version int64) {
+		panic("evict")
+	})
+	setKey("c", "1")
+	deleteKey("c")
+	deleteKey("c")
+	setKey("c", "2")
+	rs := h.take()
+	if len(rs) == 0 || rs[0].Message != "kvmemdb: OnEvict function panicked" {
+		t.Fatalf("got records %v, want an OnEvict panic record", rs)
+	}
+	if attrs := attrsOf(rs[0]); attrs["key"].String() != "c" || attrs["panic"].String() != "evict" {
+		t.Errorf("OnEvict panic record has attributes %v", attrs)
+	}
+
+	db.OnEvict(nil)
+	deleteKey("c")
+	deleteKey("c")
+}
@@ -0,0 +1,236 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+// FS returns a read-only file system view of the keys visible to the snapshot.
+// Keys are split into path elements at the slashes, so every key is a file
+// holding its value and every key prefix ending at a slash is a directory.
+// Keys that are not valid file system paths, e.g., keys with a leading or
+// trailing slash or with empty elements, are not visible. When a key is also
+// a prefix of other keys, e.g., "a" and "a/b", the name is reported as a
+// directory and the value of the key is not visible.
+//
+// Returned file system implements fs.ReadDirFS, fs.ReadFileFS and fs.StatFS.
+// It must not be used after the snapshot is discarded.
+func (s *Snapshot) FS() fs.FS {
+	return &snapshotFS{snap: s}
+}
+
+type snapshotFS struct {
+	snap *Snapshot
+}
+
+var (
+	_ fs.ReadDirFS   = &snapshotFS{}
+	_ fs.ReadFileFS  = &snapshotFS{}
+	_ fs.StatFS      = &snapshotFS{}
+	_ fs.ReadDirFile = &snapshotDir{}
+	_ io.ReaderAt    = &snapshotFile{}
+)
+
+// dirPrefix returns the key prefix for the entries of the named directory.
+func dirPrefix(name string) string {
+	if name == "." {
+		return ""
+	}
+	return name + "/"
+}
+
+// isDir returns true if any visible key is under the named directory.
+func (f *snapshotFS) isDir(name string) (bool, error) {
+	if name == "." {
+		return true, nil
+	}
+	var err error
+	for key := range f.snap.ScanPrefix(context.Background(), dirPrefix(name), &err) {
+		if fs.ValidPath(key) {
+			return true, nil
+		}
+	}
+	return false, err
+}
+
+// entries returns the sorted entries of the named directory.
+func (f *snapshotFS) entries(name string) ([]fs.DirEntry, error) {
+	dirs := make(map[string]bool)
+	files := make(map[string]int64)
+
+	var err error
+	prefix := dirPrefix(name)
+	for key, value := range f.snap.ScanPrefix(context.Background(), prefix, &err) {
+		if !fs.ValidPath(key) {
+			continue
+		}
+		rest := key[len(prefix):]
+		if i := strings.IndexByte(rest, '/'); i >= 0 {
+			dirs[rest[:i]] = true
+			continue
+		}
+		files[rest] = value.(interface{ Size() int64 }).Size()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]fs.DirEntry, 0, len(dirs)+len(files))
+	for name := range dirs {
+		entries = append(entries, fs.FileInfoToDirEntry(f.dirInfo(name)))
+	}
+	for name, size := range files {
+		if !dirs[name] {
+			entries = append(entries, fs.FileInfoToDirEntry(f.fileInfo(name, size)))
+		}
+	}
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+	return entries, nil
+}
+
+func (f *snapshotFS) dirInfo(name string) *snapshotFileInfo {
+	return &snapshotFileInfo{name: path.Base(name), mode: fs.ModeDir | 0555, modTime: f.snap.created}
+}
+
+func (f *snapshotFS) fileInfo(name string, size int64) *snapshotFileInfo {
+	return &snapshotFileInfo{name: path.Base(name), size: size, mode: 0444, modTime: f.snap.created}
+}
+
+// Open implements the fs.FS interface.
+func (f *snapshotFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if dir, err := f.isDir(name); err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	} else if dir {
+		return &snapshotDir{fsys: f, name: name}, nil
+	}
+	data, err := f.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return &snapshotFile{
+		Reader: bytes.NewReader(data),
+		info:   f.fileInfo(name, int64(len(data))),
+	}, nil
+}
+
+// ReadDir implements the fs.ReadDirFS interface.
+func (f *snapshotFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	entries, err := f.entries(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+	if len(entries) == 0 && name != "." {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	return entries, nil
+}
+
+// ReadFile implements the fs.ReadFileFS interface.
+func (f *snapshotFS) ReadFile(name string) ([]byte, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrInvalid}
+	}
+	if dir, err := f.isDir(name); err != nil {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: err}
+	} else if dir {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrInvalid}
+	}
+	var data []byte
+	if err := f.snap.GetInto(context.Background(), name, &data); err != nil {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: err}
+	}
+	return data, nil
+}
+
+// Stat implements the fs.StatFS interface.
+func (f *snapshotFS) Stat(name string) (fs.FileInfo, error) {
+	file, err := f.Open(name)
+	if err != nil {
+		var perr *fs.PathError
+		if errors.As(err, &perr) {
+			perr.Op = "stat"
+		}
+		return nil, err
+	}
+	defer file.Close()
+	return file.Stat()
+}
+
+// snapshotFile is an open file holding a copy of the key's value.
+type snapshotFile struct {
+	*bytes.Reader
+	info *snapshotFileInfo
+}
+
+func (f *snapshotFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *snapshotFile) Close() error               { return nil }
+
+// snapshotDir is an open directory, whose entries are read on the first
+// ReadDir call.
+type snapshotDir struct {
+	fsys    *snapshotFS
+	name    string
+	entries []fs.DirEntry
+	read    bool
+}
+
+func (d *snapshotDir) Stat() (fs.FileInfo, error) { return d.fsys.dirInfo(d.name), nil }
+func (d *snapshotDir) Close() error               { return nil }
+
+func (d *snapshotDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: fs.ErrInvalid}
+}
+
+// ReadDir implements the fs.ReadDirFile interface.
+func (d *snapshotDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !d.read {
+		entries, err := d.fsys.entries(d.name)
+		if err != nil {
+			return nil, &fs.PathError{Op: "readdir", Path: d.name, Err: err}
+		}
+		d.entries, d.read = entries, true
+	}
+	if n <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	n = min(n, len(d.entries))
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
+
+type snapshotFileInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (i *snapshotFileInfo) Name() string       { return i.name }
+func (i *snapshotFileInfo) Size() int64        { return i.size }
+func (i *snapshotFileInfo) Mode() fs.FileMode  { return i.mode }
+func (i *snapshotFileInfo) ModTime() time.Time { return i.modTime }
+func (i *snapshotFileInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i *snapshotFileInfo) Sys() any           { return nil }
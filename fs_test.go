@@ -0,0 +1,70 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestSnapshotFS(t *testing.T) {
+	ctx := context.Background()
+
+	db, err := FromMap(ctx, map[string][]byte{
+		"README":          []byte("readme"),
+		"a/b.txt":         []byte("b"),
+		"a/b/c":           []byte("c"),
+		"a/b/d":           []byte(""),
+		"a/b0":            []byte("b0"),
+		"x":               []byte("shadowed by the directory"),
+		"x/y":             []byte("y"),
+		"/leading/slash":  []byte("invisible"),
+		"trailing/slash/": []byte("invisible"),
+		"empty//element":  []byte("invisible"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	snap, _ := db.NewSnapshot(ctx)
+	defer snap.Discard(ctx)
+
+	// Later updates are not visible through the snapshot.
+	tx, _ := db.NewTransaction(ctx)
+	tx.Set(ctx, "a/new", strings.NewReader("new"))
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	fsys := snap.FS()
+	if err := fstest.TestFS(fsys, "README", "a/b.txt", "a/b/c", "a/b/d", "a/b0", "x/y"); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := fs.ReadDir(fsys, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if got, want := strings.Join(names, " "), "b b.txt b0"; got != want {
+		t.Errorf("ReadDir(a) = %q, want %q", got, want)
+	}
+
+	if data, err := fs.ReadFile(fsys, "a/b/c"); err != nil || string(data) != "c" {
+		t.Errorf("ReadFile(a/b/c) = %q, %v, want c", data, err)
+	}
+	for _, name := range []string{"a/new", "leading", "trailing/slash", "empty", "missing"} {
+		if _, err := fs.Stat(fsys, name); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("Stat(%s) = %v, want fs.ErrNotExist", name, err)
+		}
+	}
+	if info, err := fs.Stat(fsys, "x"); err != nil || !info.IsDir() {
+		t.Errorf("Stat(x) = %v, %v, want a directory", info, err)
+	}
+}
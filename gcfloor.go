@@ -0,0 +1,71 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+)
+
+// GCWatermark returns the version below which the compaction can remove the
+// older versions of the keys, i.e., the smallest version readable by any live
+// transaction, snapshot or transaction group, or by the GC floor. It is the
+// max commit version when nothing holds back the compaction.
+func (d *Database) GCWatermark() int64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return min(d.minVersionLocked(), d.maxCommitVersion.Load())
+}
+
+// SetGCFloor pins the GC watermark at the input version, as if a snapshot at
+// the version was live, until the floor is cleared with ClearGCFloor. Setting
+// a new floor replaces the previous floor; the previous floor is retained if
+// the new floor cannot be set. Floor is reported among the live
+// snapshots, but it doesn't expire with the WithMaxSnapshotAge option.
+//
+// Floor cannot be lower than the current GC watermark, because the versions
+// below the watermark may already be compacted, in which case an
+// *ErrCompacted error is returned. Returns os.ErrInvalid if the version is
+// larger than the max commit version.
+func (d *Database) SetGCFloor(ctx context.Context, version int64) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if maxVersion := d.maxCommitVersion.Load(); version > maxVersion {
+		return fmt.Errorf("gc floor %d is larger than the max commit version %d: %w", version, maxVersion, os.ErrInvalid)
+	}
+	if watermark := min(d.minVersionLocked(), d.maxCommitVersion.Load()); version < watermark {
+		return &ErrCompacted{Version: version, Retained: watermark}
+	}
+
+	d.clearGCFloorLocked()
+	d.lastSnapID++
+	d.gcFloor = &Snapshot{
+		db:              d,
+		id:              d.lastSnapID,
+		snapshotVersion: version,
+		created:         d.now(),
+	}
+	d.liveSnaps = append(d.liveSnaps, d.gcFloor)
+	return nil
+}
+
+// ClearGCFloor removes the GC floor, if any.
+func (d *Database) ClearGCFloor(ctx context.Context) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.clearGCFloorLocked()
+}
+
+// clearGCFloorLocked removes the GC floor, if any. Caller must hold the
+// database mutex.
+func (d *Database) clearGCFloorLocked() {
+	if d.gcFloor != nil {
+		d.liveSnaps = slices.DeleteFunc(d.liveSnaps, func(v *Snapshot) bool { return v == d.gcFloor })
+		d.gcFloor = nil
+	}
+}
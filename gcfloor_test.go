@@ -0,0 +1,76 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestGCFloor(t *testing.T) {
+	ctx := context.Background()
+
+	db := New()
+	mustSet(ctx, t, db, "a", "1") // 1
+	mustSet(ctx, t, db, "a", "2") // 2
+	if got := db.GCWatermark(); got != 2 {
+		t.Errorf("GCWatermark() = %d, want 2", got)
+	}
+
+	if err := db.SetGCFloor(ctx, 3); !errors.Is(err, os.ErrInvalid) {
+		t.Errorf("SetGCFloor(3) = %v, want os.ErrInvalid", err)
+	}
+	if err := db.SetGCFloor(ctx, 1); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("SetGCFloor(1) = %v, want an ErrCompacted", err)
+	}
+	if err := db.SetGCFloor(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	// Watermark and the history stay at the floor across compactions.
+	mustSet(ctx, t, db, "a", "3") // 3
+	mustSet(ctx, t, db, "a", "4") // 4
+	if got := db.GCWatermark(); got != 2 {
+		t.Errorf("GCWatermark() with the floor = %d, want 2", got)
+	}
+	var err error
+	for range db.DeletedSince(ctx, 2, &err) {
+	}
+	if err != nil {
+		t.Errorf("DeletedSince(2) with the floor = %v, want nil", err)
+	}
+	if infos := db.LiveSnapshots(); len(infos) != 1 {
+		t.Errorf("LiveSnapshots() with the floor = %d, want 1", len(infos))
+	}
+
+	// Floor can be raised, but not lowered below the watermark.
+	if err := db.SetGCFloor(ctx, 3); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SetGCFloor(ctx, 2); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("SetGCFloor(2) after raising = %v, want an ErrCompacted", err)
+	}
+	if got := db.GCWatermark(); got != 3 {
+		t.Errorf("GCWatermark() after the failed update = %d, want 3", got)
+	}
+
+	// Snapshots below the floor still hold back the watermark.
+	snap, _ := db.NewSnapshot(ctx)
+	defer snap.Discard(ctx)
+	db.ClearGCFloor(ctx)
+	if got := db.GCWatermark(); got != 4 {
+		t.Errorf("GCWatermark() after ClearGCFloor = %d, want 4", got)
+	}
+	snap.Discard(ctx)
+	mustSet(ctx, t, db, "a", "5") // 5
+	if got := db.GCWatermark(); got != 5 {
+		t.Errorf("GCWatermark() without the floor = %d, want 5", got)
+	}
+	for range db.DeletedSince(ctx, 2, &err) {
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("DeletedSince(2) after ClearGCFloor = %v, want an ErrCompacted", err)
+	}
+}
@@ -5,4 +5,5 @@ go 1.23.2
 require (
 	github.com/visvasity/kv v0.0.0-20250508033112-397c38338d68
 	github.com/visvasity/syncmap v0.0.0-20241218025521-5599e6c230a7
+	golang.org/x/time v0.9.0
 )
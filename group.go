@@ -0,0 +1,101 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+)
+
+// TransactionGroup creates transactions that all read the database state at
+// the same version, even when they are created at different times.
+//
+// Transactions in a group conflict with each other and with other
+// transactions as usual. In addition, a group transaction fails to commit if
+// any key it has read is updated after the group version, including updates
+// that were committed before the transaction was created.
+//
+// A group stays open, so that transactions can be created in it one after
+// the other, until it is discarded and all of its transactions are committed
+// or rolled back. Versions visible to the group are retained until it is
+// closed, so groups should be short-lived and must always be discarded.
+type TransactionGroup struct {
+	db *Database
+
+	// snapshotVersion is the max version readable by all transactions of the
+	// group. This is also the maxCommitVersion of the database at the creation
+	// of the group.
+	snapshotVersion int64
+
+	// live holds the number of live transactions in the group.
+	live int
+
+	// closed is true when no more transactions can be created in the group,
+	// which happens when the group is discarded.
+	closed bool
+}
+
+// NewTransactionGroup creates a transaction group at the current database
+// state.
+func (d *Database) NewTransactionGroup(ctx context.Context) (*TransactionGroup, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	g := &TransactionGroup{
+		db:              d,
+		snapshotVersion: d.maxCommitVersion.Load(),
+	}
+	d.liveGroups = append(d.liveGroups, g)
+	return g, nil
+}
+
+// NewTransaction creates a read-write transaction at the group version.
+// Returns an error wrapping os.ErrClosed if the group is already closed.
+func (g *TransactionGroup) NewTransaction(ctx context.Context) (*Transaction, error) {
+	d := g.db
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if g.closed {
+		return nil, fmt.Errorf("transaction group is already closed: %w", os.ErrClosed)
+	}
+
+	d.hook(hookNewTransaction)
+	t := d.newTransactionLocked(g.snapshotVersion, TxOptions{})
+	t.group = g
+	t.pinned = true
+	g.live++
+	return t, nil
+}
+
+// Discard closes the group immediately if it has no live transactions, or
+// after all of its live transactions are committed or rolled back. No new
+// transactions can be created in the group after a Discard.
+func (g *TransactionGroup) Discard(ctx context.Context) {
+	d := g.db
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	g.closed = true
+	if g.live == 0 {
+		g.closeLocked()
+	}
+}
+
+// closeTransactionLocked is invoked when a transaction in the group is
+// committed or rolled back. Caller must hold the database mutex.
+func (g *TransactionGroup) closeTransactionLocked() {
+	g.live--
+	if g.live == 0 && g.closed {
+		g.closeLocked()
+	}
+}
+
+// closeLocked closes the group and releases the versions retained for it.
+// Caller must hold the database mutex.
+func (g *TransactionGroup) closeLocked() {
+	g.closed = true
+	g.db.liveGroups = slices.DeleteFunc(g.db.liveGroups, func(v *TransactionGroup) bool { return v == g })
+}
@@ -0,0 +1,117 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestTransactionGroup(t *testing.T) {
+	ctx := context.Background()
+
+	db := New()
+	mustSet(ctx, t, db, "key", "v1")
+
+	g, err := db.NewTransactionGroup(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tx1, _ := g.NewTransaction(ctx)
+	defer tx1.Rollback(ctx)
+
+	// External commits after the group is created are invisible to all group
+	// transactions, including the ones created later. Versions visible to the
+	// group must survive the compaction.
+	mustSet(ctx, t, db, "key", "v2")
+	mustSet(ctx, t, db, "key", "v3")
+
+	tx2, _ := g.NewTransaction(ctx)
+	defer tx2.Rollback(ctx)
+
+	if got := mustGet(ctx, t, tx1, "key"); got != "v1" {
+		t.Errorf("first group tx read %q, want v1", got)
+	}
+	if got := mustGet(ctx, t, tx2, "key"); got != "v1" {
+		t.Errorf("second group tx read %q, want v1", got)
+	}
+	snap, _ := db.NewSnapshot(ctx)
+	if got := mustGet(ctx, t, snap, "key"); got != "v3" {
+		t.Errorf("snapshot read %q, want v3", got)
+	}
+	snap.Discard(ctx)
+
+	// Reads of keys updated after the group version are stale, even though
+	// the update was committed before the transaction was created.
+	tx2.Set(ctx, "other", strings.NewReader("value"))
+	if err := tx2.Commit(ctx); err == nil {
+		t.Errorf("group tx with a stale read must fail to commit")
+	}
+
+	// Group transactions conflict with each other as usual.
+	tx3, _ := g.NewTransaction(ctx)
+	defer tx3.Rollback(ctx)
+	tx4, _ := g.NewTransaction(ctx)
+	defer tx4.Rollback(ctx)
+	tx3.Get(ctx, "new")
+	tx3.Set(ctx, "new", strings.NewReader("tx3"))
+	tx4.Set(ctx, "new", strings.NewReader("tx4"))
+	if err := tx4.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx3.Commit(ctx); err == nil {
+		t.Errorf("conflicting group tx must fail to commit")
+	}
+
+	tx5, err := g.NewTransaction(ctx)
+	if err != nil {
+		t.Fatalf("group with a live transaction must be open: %v", err)
+	}
+	tx5.Rollback(ctx)
+	tx1.Rollback(ctx)
+
+	// Group stays open without live transactions until it is discarded, so
+	// transactions can be created one after the other.
+	tx6, err := g.NewTransaction(ctx)
+	if err != nil {
+		t.Fatalf("group without live transactions must be open: %v", err)
+	}
+	if got := mustGet(ctx, t, tx6, "key"); got != "v1" {
+		t.Errorf("later group tx read %q, want v1", got)
+	}
+	if err := tx6.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	tx7, err := g.NewTransaction(ctx)
+	if err != nil {
+		t.Fatalf("group must be open after a commit: %v", err)
+	}
+
+	// Discarded group is closed after its last transaction is closed.
+	g.Discard(ctx)
+	if _, err := g.NewTransaction(ctx); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("NewTransaction on a discarded group = %v, want os.ErrClosed", err)
+	}
+	if h := db.Health(); h.RetainedVersionsEstimate == 0 {
+		t.Errorf("discarded group with a live transaction must retain versions: %+v", h)
+	}
+	tx7.Rollback(ctx)
+	if h := db.Health(); h.RetainedVersionsEstimate != 0 {
+		t.Errorf("closed group still retains versions: %+v", h)
+	}
+
+	// Discarded groups release their versions without any transactions.
+	g, _ = db.NewTransactionGroup(ctx)
+	mustSet(ctx, t, db, "key", "v4")
+	if h := db.Health(); h.RetainedVersionsEstimate != 1 {
+		t.Errorf("Health = %+v, want one version retained by the group", h)
+	}
+	g.Discard(ctx)
+	if h := db.Health(); h.RetainedVersionsEstimate != 0 {
+		t.Errorf("discarded group still retains versions: %+v", h)
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// groupCommitter batches the commits that arrive within a time window, so
+// that the shard locks and the database mutex are acquired once per batch.
+type groupCommitter struct {
+	window time.Duration
+
+	mu      sync.Mutex
+	pending []*commitRequest
+}
+
+// commitRequest is a transaction waiting in a batch. A request is taken
+// either by the batch leader to commit it or by the waiting committer when
+// its context is done, whichever happens first.
+type commitRequest struct {
+	ctx   context.Context
+	tx    *Transaction
+	taken atomic.Bool
+	done  chan error
+}
+
+// groupCommit commits the transaction in a batch with the other transactions
+// that are committed within the group commit window. First transaction of a
+// batch commits the whole batch after the window.
+//
+// Context cancellation is honored until the batch is committed, unless the
+// transaction is already validated.
+func (d *Database) groupCommit(ctx context.Context, tx *Transaction) error {
+	g := d.groupCommitter
+	req := &commitRequest{ctx: ctx, tx: tx, done: make(chan error, 1)}
+
+	g.mu.Lock()
+	g.pending = append(g.pending, req)
+	leader := len(g.pending) == 1
+	g.mu.Unlock()
+
+	if leader {
+		time.Sleep(g.window)
+
+		g.mu.Lock()
+		batch := g.pending
+		g.pending = nil
+		g.mu.Unlock()
+
+		d.commitBatch(batch)
+		return <-req.done
+	}
+
+	select {
+	case err := <-req.done:
+		return err
+	case <-ctx.Done():
+		if req.taken.CompareAndSwap(false, true) {
+			return fmt.Errorf("could not commit the batch: %w", ctx.Err())
+		}
+		return <-req.done
+	}
+}
+
+// commitBatch validates and applies the transactions in the batch one after
+// the other, under a single acquisition of the locks, so that every
+// transaction is validated against the updates of the transactions before it
+// as with the sequential commits. Updates are published after the locks are
+// released.
+func (d *Database) commitBatch(batch []*commitRequest) {
+	var reqs []*commitRequest
+	writes := make(map[string]*string)
+	for _, req := range batch {
+		if !req.taken.CompareAndSwap(false, true) {
+			continue
+		}
+		if err := req.ctx.Err(); err != nil {
+			req.done <- fmt.Errorf("could not commit the batch: %w", err)
+			continue
+		}
+		reqs = append(reqs, req)
+		maps.Copy(writes, req.tx.writes)
+	}
+	if len(reqs) == 0 {
+		return
+	}
+
+	// Batch has taken the requests, so the locks are acquired without a
+	// deadline.
+	shards, _ := d.lockShards(context.Background(), writes)
+	d.mu.Lock()
+
+	versions := make([]int64, len(reqs))
+	errs := make([]error, len(reqs))
+	for i, req := range reqs {
+		tx := req.tx
+		if errs[i] = checkCommit(d, tx); errs[i] != nil {
+			continue
+		}
+		version, minVersion := assignVersion(d, tx)
+		if version == 0 {
+			continue
+		}
+		versions[i] = version
+		_, errs[i] = apply(req.ctx, d, tx, version, minVersion)
+	}
+
+	d.mu.Unlock()
+	d.unlockShards(shards)
+
+	// All versions of the batch are published before the post-commit
+	// maintenance, which can start new commits that wait for them.
+	for _, version := range versions {
+		if version != 0 {
+			d.publish(version)
+		}
+	}
+	for i, req := range reqs {
+		if versions[i] != 0 && errs[i] == nil {
+			d.afterCommit(req.ctx, req.tx)
+		}
+		req.done <- errs[i]
+	}
+}
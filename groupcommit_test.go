@@ -0,0 +1,168 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGroupCommit(t *testing.T) {
+	ctx := context.Background()
+
+	db := New(WithGroupCommit(20 * time.Millisecond))
+
+	// Transactions in a batch are assigned distinct versions.
+	const n = 8
+	txes := make([]*Transaction, n)
+	for i := range txes {
+		txes[i], _ = db.NewTransaction(ctx)
+		txes[i].Set(ctx, fmt.Sprintf("key%d", i), strings.NewReader("value"))
+	}
+	var wg sync.WaitGroup
+	errs := make([]error, n)
+	for i, tx := range txes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = tx.Commit(ctx)
+		}()
+	}
+	wg.Wait()
+	versions := make(map[int64]bool)
+	for i, tx := range txes {
+		if errs[i] != nil {
+			t.Fatalf("tx %d: %v", i, errs[i])
+		}
+		versions[tx.CommitVersion()] = true
+	}
+	if len(versions) != n {
+		t.Errorf("want %d distinct commit versions, got %v", n, versions)
+	}
+
+	// Writers to the same key without read tracking conflict within a batch.
+	opts := TxOptions{DisableReadTracking: true}
+	tx1, _ := db.NewTransactionWithOptions(ctx, opts)
+	tx2, _ := db.NewTransactionWithOptions(ctx, opts)
+	tx1.Set(ctx, "key0", strings.NewReader("tx1"))
+	tx2.Set(ctx, "key0", strings.NewReader("tx2"))
+	errc := make(chan error, 2)
+	go func() { errc <- tx1.Commit(ctx) }()
+	go func() { errc <- tx2.Commit(ctx) }()
+	if err1, err2 := <-errc, <-errc; (err1 == nil) == (err2 == nil) {
+		t.Errorf("want exactly one commit to succeed, got %v and %v", err1, err2)
+	}
+
+	// Commits waiting for the batch give up when their context is done.
+	leader, _ := db.NewTransaction(ctx)
+	leader.Set(ctx, "leader", strings.NewReader("value"))
+	follower, _ := db.NewTransaction(ctx)
+	follower.Set(ctx, "follower", strings.NewReader("value"))
+	go func() { errc <- leader.Commit(ctx) }()
+	time.Sleep(time.Millisecond)
+	if err := follower.CommitWithDeadline(ctx, time.Millisecond); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("canceled commit: want context.DeadlineExceeded, got %v", err)
+	}
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	snap, _ := db.NewSnapshot(ctx)
+	defer snap.Discard(ctx)
+	if _, err := snap.Get(ctx, "follower"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("canceled commit must not be applied, got %v", err)
+	}
+	if got := mustGet(ctx, t, snap, "leader"); got != "value" {
+		t.Errorf("leader: want value, got %q", got)
+	}
+}
+
+func TestGroupCommitEvictions(t *testing.T) {
+	ctx := context.Background()
+
+	db := New(WithGroupCommit(5*time.Millisecond), WithMemoryLimit(200))
+
+	// Evictions and the commits from the OnEvict function start new batches
+	// while the later versions of the evicting batch may not yet be published.
+	var evictions atomic.Int64
+	db.OnEvict(func(key string, value []byte, version int64) {
+		if strings.HasPrefix(key, "evicted/") {
+			return
+		}
+		evictions.Add(1)
+		if err := setKey(ctx, db, "evicted/"+key, ""); err != nil {
+			t.Error(err)
+		}
+	})
+
+	const writers, n = 8, 20
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		var wg sync.WaitGroup
+		for i := range writers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := range n {
+					// Repeated deletions drop the deleted values once no
+					// concurrent transaction can read them.
+					key := fmt.Sprintf("key%d/%d", i, j)
+					if err := setKey(ctx, db, key, "0123456789"); err != nil {
+						t.Error(err)
+						return
+					}
+					for range 4 {
+						tx, _ := db.NewTransaction(ctx)
+						tx.Delete(ctx, key)
+						if err := tx.Commit(ctx); err != nil {
+							t.Error(err)
+							return
+						}
+					}
+				}
+			}()
+		}
+		wg.Wait()
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("commits with the memory limit and the OnEvict function are stuck")
+	}
+	if evictions.Load() == 0 {
+		t.Errorf("want OnEvict calls for the dropped values")
+	}
+}
+
+func BenchmarkGroupCommit(b *testing.B) {
+	ctx := context.Background()
+
+	const nkeys = 1000000
+
+	for _, window := range []time.Duration{0, 50 * time.Microsecond, 200 * time.Microsecond} {
+		b.Run(fmt.Sprintf("window=%v", window), func(b *testing.B) {
+			db := New(WithGroupCommit(window))
+
+			b.SetParallelism(16)
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					key := fmt.Sprintf("key%07d", rand.IntN(nkeys))
+					if err := setKey(ctx, db, key, "value"); err != nil {
+						b.Error(err)
+						return
+					}
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"math"
+	"time"
+)
+
+// Health holds a point-in-time summary of the database load, meant for
+// admission control decisions.
+type Health struct {
+	// LiveTransactions is the number of transactions that are not yet
+	// committed or rolled back.
+	LiveTransactions int
+
+	// LiveSnapshots is the number of snapshots that are not yet discarded.
+	LiveSnapshots int
+
+	// OldestTransactionAge is the age of the oldest live transaction. It is
+	// zero when there are no live transactions.
+	OldestTransactionAge time.Duration
+
+	// RetainedVersionsEstimate is the number of commit versions that cannot
+	// be compacted because they are still visible to a live transaction,
+	// snapshot or transaction group.
+	RetainedVersionsEstimate int64
+
+	// QueueDepth is the number of commits that have passed validation but are
+	// not yet visible to new transactions and snapshots.
+	QueueDepth int64
+}
+
+// HealthThresholds holds the limits for an overloaded database. Zero values
+// disable the corresponding limit.
+type HealthThresholds struct {
+	MaxLiveTransactions         int
+	MaxLiveSnapshots            int
+	MaxOldestTransactionAge     time.Duration
+	MaxRetainedVersionsEstimate int64
+	MaxQueueDepth               int64
+}
+
+// Health returns the current load summary of the database. It only takes the
+// database mutex for a scan over the live transactions and snapshots, so it
+// is cheap enough to call for every request.
+func (d *Database) Health() Health {
+	now := d.now()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	maxVersion := d.maxCommitVersion.Load()
+	h := Health{
+		LiveTransactions: len(d.liveTxes),
+		LiveSnapshots:    len(d.liveSnaps),
+		QueueDepth:       d.commitVersion - maxVersion,
+	}
+	for _, tx := range d.liveTxes {
+		h.OldestTransactionAge = max(h.OldestTransactionAge, now.Sub(tx.created))
+	}
+	if v := d.minVersionLocked(); v != math.MaxInt64 {
+		h.RetainedVersionsEstimate = max(maxVersion-v, 0)
+	}
+	return h
+}
+
+// Overloaded returns true if the current database health exceeds any of the
+// input thresholds.
+func (d *Database) Overloaded(th HealthThresholds) bool {
+	return d.Health().Exceeds(th)
+}
+
+// Exceeds returns true if the health summary exceeds any of the input
+// thresholds.
+func (h Health) Exceeds(th HealthThresholds) bool {
+	switch {
+	case th.MaxLiveTransactions > 0 && h.LiveTransactions > th.MaxLiveTransactions:
+		return true
+	case th.MaxLiveSnapshots > 0 && h.LiveSnapshots > th.MaxLiveSnapshots:
+		return true
+	case th.MaxOldestTransactionAge > 0 && h.OldestTransactionAge > th.MaxOldestTransactionAge:
+		return true
+	case th.MaxRetainedVersionsEstimate > 0 && h.RetainedVersionsEstimate > th.MaxRetainedVersionsEstimate:
+		return true
+	case th.MaxQueueDepth > 0 && h.QueueDepth > th.MaxQueueDepth:
+		return true
+	}
+	return false
+}
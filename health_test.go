@@ -0,0 +1,86 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHealth(t *testing.T) {
+	ctx := context.Background()
+
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	db := New(WithClock(clock.Now))
+
+	if h := db.Health(); h != (Health{}) {
+		t.Fatalf("Health = %+v on an idle database, want zero", h)
+	}
+
+	// Live transactions and their age.
+	old, _ := db.NewTransaction(ctx)
+	clock.Advance(time.Minute)
+	tx, _ := db.NewTransaction(ctx)
+
+	h := db.Health()
+	if h.LiveTransactions != 2 || h.OldestTransactionAge != time.Minute {
+		t.Errorf("Health = %+v, want two live transactions with the oldest aged a minute", h)
+	}
+	if !db.Overloaded(HealthThresholds{MaxLiveTransactions: 1}) {
+		t.Errorf("Overloaded must be true with more live transactions than the limit")
+	}
+	if !db.Overloaded(HealthThresholds{MaxOldestTransactionAge: time.Second}) {
+		t.Errorf("Overloaded must be true with a transaction older than the limit")
+	}
+	if db.Overloaded(HealthThresholds{MaxLiveTransactions: 2, MaxOldestTransactionAge: time.Hour}) {
+		t.Errorf("Overloaded must be false within the limits")
+	}
+	tx.Rollback(ctx)
+
+	// Retained versions due to a long-lived transaction and snapshot.
+	snap, _ := db.NewSnapshot(ctx)
+	for i := 0; i < 3; i++ {
+		mustSet(ctx, t, db, "key", "value")
+	}
+	h = db.Health()
+	if h.LiveSnapshots != 1 || h.RetainedVersionsEstimate != 3 {
+		t.Errorf("Health = %+v, want one live snapshot and three retained versions", h)
+	}
+	if !db.Overloaded(HealthThresholds{MaxRetainedVersionsEstimate: 2}) {
+		t.Errorf("Overloaded must be true with more retained versions than the limit")
+	}
+	old.Rollback(ctx)
+	snap.Discard(ctx)
+	if h := db.Health(); h.LiveTransactions != 0 || h.LiveSnapshots != 0 || h.RetainedVersionsEstimate != 0 {
+		t.Errorf("Health = %+v after closing all readers, want zero", h)
+	}
+
+	// Commits that are validated, but not yet applied.
+	p := pauseAt(db, hookCommitApply)[hookCommitApply]
+	defer p.resume()
+
+	tx, _ = db.NewTransaction(ctx)
+	tx.Set(ctx, "key", strings.NewReader("value"))
+	errc := make(chan error, 1)
+	go func() { errc <- tx.Commit(ctx) }()
+
+	<-p.reached
+	if h := db.Health(); h.QueueDepth != 1 {
+		t.Errorf("Health = %+v during a commit, want queue depth one", h)
+	}
+	if db.Overloaded(HealthThresholds{}) {
+		t.Errorf("Overloaded must be false without any limits")
+	}
+	if !(Health{QueueDepth: 2}).Exceeds(HealthThresholds{MaxQueueDepth: 1}) {
+		t.Errorf("Exceeds must be true with a queue deeper than the limit")
+	}
+	p.resume()
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	if h := db.Health(); h.QueueDepth != 0 {
+		t.Errorf("Health = %+v after the commit, want queue depth zero", h)
+	}
+}
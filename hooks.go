@@ -0,0 +1,25 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+// Names of the hook points that tests can use to pause or observe the
+// database at interesting moments.
+const (
+	hookNewTransaction   = "NewTransaction"
+	hookCommitValidate   = "commit.validate"
+	hookCommitApply      = "commit.apply"
+	hookCommitPrepare    = "commit.prepare"
+	hookCompact          = "commit.compact"
+	hookCloseTransaction = "closeTransaction"
+)
+
+// hook invokes the test hook function, if any, with the name of the hook
+// point. Hook points are not compiled out: every build, with or without the
+// kvmemdb_debug tag, pays a nil check at each point, because the tests run on
+// the normal build. Test hooks are only installed by tests, so the check
+// always fails in normal use. See BenchmarkCommitHooks for its cost.
+func (d *Database) hook(point string) {
+	if d.hooks != nil {
+		d.hooks(point)
+	}
+}
@@ -0,0 +1,233 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// pause blocks the first goroutine reaching a hook point while it is armed,
+// until it is resumed.
+type pause struct {
+	armed   atomic.Bool
+	reached chan struct{}
+	resume  func()
+	resumed chan struct{}
+}
+
+// pauseAt installs a test hook that pauses the first goroutine reaching each of
+// the input hook points. Pauses are armed initially. Hooks must be installed
+// before the database is used concurrently.
+func pauseAt(db *Database, points ...string) map[string]*pause {
+	pauses := make(map[string]*pause)
+	for _, point := range points {
+		p := &pause{
+			reached: make(chan struct{}),
+			resumed: make(chan struct{}),
+		}
+		p.armed.Store(true)
+		p.resume = sync.OnceFunc(func() { close(p.resumed) })
+		pauses[point] = p
+	}
+	db.hooks = func(point string) {
+		if p, ok := pauses[point]; ok && p.armed.CompareAndSwap(true, false) {
+			close(p.reached)
+			<-p.resumed
+		}
+	}
+	return pauses
+}
+
+// setKey commits a single key-value pair in a new transaction.
+func setKey(ctx context.Context, db *Database, key, value string) error {
+	tx, err := db.NewTransaction(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := tx.Set(ctx, key, strings.NewReader(value)); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func mustSet(ctx context.Context, t testing.TB, db *Database, key, value string) {
+	t.Helper()
+
+	if err := setKey(ctx, db, key, value); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func mustGet(ctx context.Context, t testing.TB, r interface {
+	Get(context.Context, string) (io.Reader, error)
+}, key string) string {
+	t.Helper()
+
+	v, err := r.Get(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestSnapshotReadDuringCompaction(t *testing.T) {
+	ctx := context.Background()
+
+	db := New()
+	mustSet(ctx, t, db, "key", "v1")
+
+	snap, err := db.NewSnapshot(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer snap.Discard(ctx)
+
+	// Committing transactions hold back the compaction, so the v1 version is
+	// only eligible for compaction from the second update.
+	mustSet(ctx, t, db, "key", "v2")
+
+	p := pauseAt(db, hookCompact)[hookCompact]
+	defer p.resume()
+
+	errc := make(chan error, 1)
+	go func() { errc <- setKey(ctx, db, "key", "v3") }()
+
+	<-p.reached
+	if got := mustGet(ctx, t, snap, "key"); got != "v1" {
+		t.Errorf("snapshot read %q during compaction, want v1", got)
+	}
+	p.resume()
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+
+	if got := mustGet(ctx, t, snap, "key"); got != "v1" {
+		t.Errorf("snapshot read %q after compaction, want v1", got)
+	}
+}
+
+func TestCommitVersionRace(t *testing.T) {
+	ctx := context.Background()
+
+	db := New()
+
+	tx1, _ := db.NewTransaction(ctx)
+	defer tx1.Rollback(ctx)
+	tx2, _ := db.NewTransaction(ctx)
+	defer tx2.Rollback(ctx)
+
+	tx1.Set(ctx, "key1", strings.NewReader("value1"))
+	tx2.Set(ctx, "key2", strings.NewReader("value2"))
+
+	p := pauseAt(db, hookCommitApply)[hookCommitApply]
+	defer p.resume()
+
+	errs := make(chan error, 2)
+	go func() { errs <- tx1.Commit(ctx) }()
+	<-p.reached
+	go func() { errs <- tx2.Commit(ctx) }()
+	p.resume()
+
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if db.maxCommitVersion.Load() != 2 {
+		t.Errorf("maxCommitVersion = %d, want 2", db.maxCommitVersion.Load())
+	}
+	snap, _ := db.NewSnapshot(ctx)
+	defer snap.Discard(ctx)
+	if got := mustGet(ctx, t, snap, "key1"); got != "value1" {
+		t.Errorf("key1 = %q, want value1", got)
+	}
+	if got := mustGet(ctx, t, snap, "key2"); got != "value2" {
+		t.Errorf("key2 = %q, want value2", got)
+	}
+}
+
+func TestCloseTransactionDuringValidation(t *testing.T) {
+	ctx := context.Background()
+
+	db := New()
+	mustSet(ctx, t, db, "key1", "initial1")
+	mustSet(ctx, t, db, "key2", "initial2")
+
+	tx1, _ := db.NewTransaction(ctx)
+	defer tx1.Rollback(ctx)
+	tx2, _ := db.NewTransaction(ctx)
+	defer tx2.Rollback(ctx)
+
+	// tx1 reads key1 and writes key2; tx2 reads key2 and writes key1.
+	mustGet(ctx, t, tx1, "key1")
+	tx1.Set(ctx, "key2", strings.NewReader("value2"))
+	mustGet(ctx, t, tx2, "key2")
+	tx2.Set(ctx, "key1", strings.NewReader("value1"))
+
+	pauses := pauseAt(db, hookCloseTransaction, hookCommitValidate)
+	closing, validating := pauses[hookCloseTransaction], pauses[hookCommitValidate]
+	defer closing.resume()
+	defer validating.resume()
+
+	// Let tx1 validate and pause it after a successful commit, just before it
+	// is closed.
+	validating.armed.Store(false)
+	errs1 := make(chan error, 1)
+	go func() { errs1 <- tx1.Commit(ctx) }()
+	<-closing.reached
+
+	// Pause tx2 in the middle of validation and let tx1 close concurrently.
+	validating.armed.Store(true)
+	errs2 := make(chan error, 1)
+	go func() { errs2 <- tx2.Commit(ctx) }()
+	<-validating.reached
+	closing.resume()
+	validating.resume()
+
+	if err := <-errs1; err != nil {
+		t.Fatalf("tx1 commit failed: %v", err)
+	}
+	if err := <-errs2; err == nil {
+		t.Fatalf("tx2 commit must fail with a conflict against tx1")
+	}
+}
+
+// BenchmarkCommitHooks measures the cost of the hook points, which are
+// present in all builds. The "nil" case is a single hook point without a hook
+// function, which is the overhead paid at every point in normal use. The
+// "commit" cases compare commits without a hook function and with a no-op
+// hook function; neither is a build without the hook points.
+func BenchmarkCommitHooks(b *testing.B) {
+	ctx := context.Background()
+
+	b.Run("nil", func(b *testing.B) {
+		db := New()
+		for i := 0; i < b.N; i++ {
+			db.hook(hookCommitApply)
+		}
+	})
+	for _, name := range []string{"commit/nil", "commit/noop"} {
+		b.Run(name, func(b *testing.B) {
+			db := New()
+			if name == "commit/noop" {
+				db.hooks = func(string) {}
+			}
+			for i := 0; i < b.N; i++ {
+				mustSet(ctx, b, db, fmt.Sprintf("key%d", i%1024), "value")
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"time"
+)
+
+// defaultIdempotencyWindow is the default duration for which the idempotency
+// keys of the commits are retained.
+const defaultIdempotencyWindow = 10 * time.Minute
+
+// idempotencyRecord is a commit with an idempotency key.
+type idempotencyRecord struct {
+	key     string
+	version int64
+	expires time.Time
+}
+
+// idempotencyTable holds the commit versions of the recent commits with
+// idempotency keys. It is protected by the database mutex.
+type idempotencyTable struct {
+	// versions maps the retained idempotency keys to their commit versions.
+	versions map[string]int64
+
+	// records holds the retained commits in the order of their expiry, which
+	// is the order of their commits.
+	records []idempotencyRecord
+}
+
+// idempotentVersionLocked returns the commit version of the earlier commit
+// with the same idempotency key as the transaction, if the key is still
+// retained. Caller must hold the database mutex.
+func (d *Database) idempotentVersionLocked(tx *Transaction) (int64, bool) {
+	if tx.idempotencyKey == "" {
+		return 0, false
+	}
+	d.pruneIdempotencyKeysLocked()
+	version, ok := d.idempotency.versions[tx.idempotencyKey]
+	return version, ok
+}
+
+// recordIdempotencyKeyLocked retains the idempotency key of a transaction
+// committed at the input version. Caller must hold the database mutex.
+func (d *Database) recordIdempotencyKeyLocked(tx *Transaction, version int64) {
+	if tx.idempotencyKey == "" {
+		return
+	}
+	if d.idempotency.versions == nil {
+		d.idempotency.versions = make(map[string]int64)
+	}
+	d.idempotency.versions[tx.idempotencyKey] = version
+	d.idempotency.records = append(d.idempotency.records, idempotencyRecord{
+		key:     tx.idempotencyKey,
+		version: version,
+		expires: d.now().Add(d.idempotencyWindow),
+	})
+}
+
+// pruneIdempotencyKeysLocked removes the idempotency keys that are older than
+// the retention window. Caller must hold the database mutex.
+func (d *Database) pruneIdempotencyKeysLocked() {
+	now := d.now()
+	n := 0
+	for _, r := range d.idempotency.records {
+		if now.Before(r.expires) {
+			break
+		}
+		if d.idempotency.versions[r.key] == r.version {
+			delete(d.idempotency.versions, r.key)
+		}
+		n++
+	}
+	if n > 0 {
+		d.idempotency.records = d.idempotency.records[n:]
+	}
+}
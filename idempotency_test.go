@@ -0,0 +1,86 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIdempotencyKey(t *testing.T) {
+	ctx := context.Background()
+
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	db := New(WithClock(clock.Now), WithIdempotencyWindow(time.Minute))
+	mustSet(ctx, t, db, "counter", "0") // 1
+
+	increment := func(key, value string) (int64, error) {
+		tx, _ := db.NewTransactionWithOptions(ctx, TxOptions{IdempotencyKey: key})
+		mustGet(ctx, t, tx, "counter")
+		tx.Set(ctx, "counter", strings.NewReader(value))
+		if err := tx.Commit(ctx); err != nil {
+			return 0, err
+		}
+		return tx.CommitVersion(), nil
+	}
+	counter := func() string {
+		snap, _ := db.NewSnapshot(ctx)
+		defer snap.Discard(ctx)
+		return mustGet(ctx, t, snap, "counter")
+	}
+
+	// Retry of a committed update, which would conflict otherwise, is a no-op.
+	stale, _ := db.NewTransactionWithOptions(ctx, TxOptions{IdempotencyKey: "req-1"})
+	mustGet(ctx, t, stale, "counter")
+	stale.Set(ctx, "counter", strings.NewReader("1"))
+
+	if v, err := increment("req-1", "1"); err != nil || v != 2 {
+		t.Fatalf("first commit = %d, %v, want version 2", v, err)
+	}
+	if err := stale.Commit(ctx); err != nil {
+		t.Fatalf("retry commit = %v, want nil", err)
+	}
+	if v := stale.CommitVersion(); v != 2 {
+		t.Errorf("retry CommitVersion = %d, want the original version 2", v)
+	}
+	if v, err := increment("req-1", "2"); err != nil || v != 2 {
+		t.Errorf("second retry = %d, %v, want the original version 2", v, err)
+	}
+	if got := counter(); got != "1" {
+		t.Errorf("counter = %q after retries, want 1", got)
+	}
+
+	// Other keys and transactions without keys are committed as usual.
+	if v, err := increment("req-2", "2"); err != nil || v != 3 {
+		t.Errorf("commit with another key = %d, %v, want version 3", v, err)
+	}
+	if v, err := increment("", "3"); err != nil || v != 4 {
+		t.Errorf("commit without a key = %d, %v, want version 4", v, err)
+	}
+
+	// Failed commits do not record their keys.
+	failed, _ := db.NewTransactionWithOptions(ctx, TxOptions{IdempotencyKey: "req-3"})
+	mustGet(ctx, t, failed, "counter")
+	failed.Set(ctx, "counter", strings.NewReader("x"))
+	mustSet(ctx, t, db, "counter", "4") // 5
+	if err := failed.Commit(ctx); err == nil {
+		t.Fatal("conflicting commit succeeded")
+	}
+	if v, err := increment("req-3", "5"); err != nil || v != 6 {
+		t.Errorf("retry of a failed commit = %d, %v, want version 6", v, err)
+	}
+
+	// Keys are forgotten after the window.
+	clock.Advance(time.Minute)
+	if v, err := increment("req-1", "6"); err != nil || v != 7 {
+		t.Errorf("retry after the window = %d, %v, want version 7", v, err)
+	}
+	if got := counter(); got != "6" {
+		t.Errorf("counter = %q after the window, want 6", got)
+	}
+	if n := len(db.idempotency.records); n != 1 {
+		t.Errorf("retained idempotency records = %d, want 1", n)
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+
+	"github.com/visvasity/kv"
+)
+
+// InitOnce runs the init function in a new transaction and sets the marker key
+// to an empty value in the same transaction, only if the marker key doesn't
+// exist. Returns true if the initialization is committed by this call.
+//
+// Concurrent callers with the same marker key conflict on the marker key, so
+// the updates from exactly one init function are committed. Callers that
+// lose the race report false without an error, but their init functions may
+// have run without effect, so they must not have side effects outside the
+// transaction.
+//
+// Returns the error from the init function as is, in which case the
+// transaction is rolled back and the marker key is not set.
+func (d *Database) InitOnce(ctx context.Context, markerKey string, init func(rw kv.ReadWriter) error) (bool, error) {
+	tx, err := d.NewTransaction(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Get(ctx, markerKey); err == nil {
+		return false, nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return false, err
+	}
+
+	if err := init(tx); err != nil {
+		return false, err
+	}
+	if err := tx.Set(ctx, markerKey, strings.NewReader("")); err != nil {
+		return false, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		// Commit fails with a conflict when another caller has initialized
+		// the database concurrently.
+		if d.hasKey(ctx, markerKey) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// hasKey returns true if the key exists in the latest database state.
+func (d *Database) hasKey(ctx context.Context, key string) bool {
+	snap, err := d.NewSnapshot(ctx)
+	if err != nil {
+		return false
+	}
+	defer snap.Discard(ctx)
+
+	_, err = snap.GetVersion(ctx, key)
+	return err == nil
+}
@@ -0,0 +1,69 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/visvasity/kv"
+)
+
+func TestInitOnce(t *testing.T) {
+	ctx := context.Background()
+
+	db := New()
+
+	init := func(rw kv.ReadWriter) error {
+		// Count of the initializations is recorded in a key, to verify that
+		// only one of them is committed.
+		n := "1"
+		if v, err := rw.Get(ctx, "inits"); err == nil {
+			data, _ := io.ReadAll(v)
+			n = string(data) + "1"
+		}
+		return rw.Set(ctx, "inits", strings.NewReader(n))
+	}
+
+	const ncallers = 20
+	var wg sync.WaitGroup
+	var initialized atomic.Int32
+	for i := 0; i < ncallers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ok, err := db.InitOnce(ctx, "marker", init)
+			if err != nil {
+				t.Error(err)
+			}
+			if ok {
+				initialized.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := initialized.Load(); n != 1 {
+		t.Errorf("want exactly one initialization, got %d", n)
+	}
+	snap, _ := db.NewSnapshot(ctx)
+	defer snap.Discard(ctx)
+	if v := mustGet(ctx, t, snap, "inits"); v != "1" {
+		t.Errorf("want the updates of a single init, got %q", v)
+	}
+
+	// Errors from the init function are returned and the marker is not set.
+	errInit := errors.New("init failed")
+	ok, err := db.InitOnce(ctx, "other", func(kv.ReadWriter) error { return errInit })
+	if ok || !errors.Is(err, errInit) {
+		t.Errorf("InitOnce with a failing init = %v, %v, want the init error", ok, err)
+	}
+	if db.hasKey(ctx, "other") {
+		t.Errorf("marker key is set after a failed init")
+	}
+}
@@ -0,0 +1,299 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// TestTaskQueue uses the database as a work queue with one producer and
+// multiple competing consumers, where every task must be claimed exactly once.
+func TestTaskQueue(t *testing.T) {
+	ctx := context.Background()
+
+	ntasks := 10000
+	if testing.Short() {
+		ntasks = 1000
+	}
+	const nconsumers = 4
+	const begin, end = "task/", "task0"
+
+	db := New()
+
+	var produced atomic.Bool
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer produced.Store(true)
+
+		for i := 1; i <= ntasks; i++ {
+			key := fmt.Sprintf("task/%010d", i)
+			if err := setKey(ctx, db, key, strconv.Itoa(i)); err != nil {
+				t.Errorf("could not produce task %d: %v", i, err)
+				return
+			}
+		}
+	}()
+
+	// first returns the first key in the [begin, end) range.
+	first := func(tx *Transaction, begin, end string) (string, error) {
+		var err error
+		for k := range tx.Ascend(ctx, begin, end, &err) {
+			return k, nil
+		}
+		return "", err
+	}
+
+	// pop claims the first task in the queue after the last task claimed by
+	// the consumer. Returns os.ErrNotExist if the queue is empty.
+	pop := func(last int) (key string, task int, err error) {
+		tx, err := db.NewTransaction(ctx)
+		if err != nil {
+			return "", 0, err
+		}
+		defer tx.Rollback(ctx)
+
+		// Tasks are produced in the key order, so all tasks before the last
+		// claimed task are already claimed by some consumer. Scans are
+		// limited to a small window after the last task when possible, because
+		// scans sort all keys in their range.
+		from := fmt.Sprintf("task/%010d", last+1)
+		if key, err = first(tx, from, fmt.Sprintf("task/%010d", last+1+nconsumers*4)); err == nil && key == "" {
+			key, err = first(tx, from, end)
+		}
+		if err != nil {
+			return "", 0, err
+		}
+		if key == "" {
+			return "", 0, os.ErrNotExist
+		}
+
+		value, err := tx.GetAndDelete(ctx, key)
+		if err != nil {
+			return "", 0, err
+		}
+		data, err := io.ReadAll(value)
+		if err != nil {
+			return "", 0, err
+		}
+		if task, err = strconv.Atoi(string(data)); err != nil {
+			return "", 0, err
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return "", 0, err
+		}
+		return key, task, nil
+	}
+
+	claims := make([][]int, nconsumers)
+	var conflicts atomic.Int64
+	for c := 0; c < nconsumers; c++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			last := 0
+			for {
+				done := produced.Load()
+				_, task, err := pop(last)
+				if errors.Is(err, os.ErrNotExist) {
+					if done {
+						return
+					}
+					runtime.Gosched()
+					continue
+				}
+				if err != nil {
+					// Another consumer has claimed the same task; retry.
+					conflicts.Add(1)
+					continue
+				}
+				last = task
+				claims[c] = append(claims[c], task)
+			}
+		}()
+	}
+	wg.Wait()
+
+	seen := make([]int, ntasks+1)
+	for _, tasks := range claims {
+		for _, task := range tasks {
+			seen[task]++
+		}
+	}
+	for task := 1; task <= ntasks; task++ {
+		if seen[task] != 1 {
+			t.Errorf("task %d is claimed %d times, want exactly once", task, seen[task])
+		}
+	}
+	t.Logf("%d tasks claimed by %d consumers with %d conflicts", ntasks, nconsumers, conflicts.Load())
+
+	snap, _ := db.NewSnapshot(ctx)
+	defer snap.Discard(ctx)
+	var err error
+	for k := range snap.Ascend(ctx, begin, end, &err) {
+		t.Errorf("task %s is left in the queue", k)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGetAndDelete(t *testing.T) {
+	ctx := context.Background()
+
+	db := New()
+	mustSet(ctx, t, db, "key", "value")
+
+	tx, _ := db.NewTransaction(ctx)
+	defer tx.Rollback(ctx)
+	if got := mustGet(ctx, t, getAndDeleter{tx}, "key"); got != "value" {
+		t.Errorf("GetAndDelete = %q, want value", got)
+	}
+	if _, err := tx.Get(ctx, "key"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Get after GetAndDelete = %v, want os.ErrNotExist", err)
+	}
+	if _, err := tx.GetAndDelete(ctx, "missing"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("GetAndDelete on a missing key = %v, want os.ErrNotExist", err)
+	}
+	if _, ok := tx.writes["missing"]; ok {
+		t.Errorf("GetAndDelete on a missing key staged a deletion")
+	}
+
+	// Only one of the concurrent deletions can commit.
+	other, _ := db.NewTransaction(ctx)
+	defer other.Rollback(ctx)
+	if _, err := other.GetAndDelete(ctx, "key"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := other.Commit(ctx); err == nil {
+		t.Errorf("concurrent GetAndDelete of the same key must fail to commit")
+	}
+}
+
+// getAndDeleter adapts GetAndDelete to the Get method signature.
+type getAndDeleter struct {
+	tx *Transaction
+}
+
+func (g getAndDeleter) Get(ctx context.Context, key string) (io.Reader, error) {
+	return g.tx.GetAndDelete(ctx, key)
+}
+
+// TestHighConcurrencyReads runs many concurrent snapshots against a large
+// database while a writer keeps committing updates, and checks that every
+// snapshot keeps observing the same values.
+func TestHighConcurrencyReads(t *testing.T) {
+	ctx := context.Background()
+
+	nkeys, nreaders := 100000, 1000
+	if testing.Short() {
+		nkeys, nreaders = 10000, 100
+	}
+	const nreads = 100
+
+	key := func(i int) string { return fmt.Sprintf("key/%08d", i) }
+
+	m := make(map[string][]byte, nkeys)
+	for i := 0; i < nkeys; i++ {
+		m[key(i)] = []byte("0")
+	}
+	db, err := FromMap(ctx, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stop := make(chan struct{})
+	writerDone := make(chan struct{})
+	var commits int
+	go func() {
+		defer close(writerDone)
+
+		r := rand.New(rand.NewPCG(1, 2))
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			tx, _ := db.NewTransaction(ctx)
+			for j := 0; j < 10; j++ {
+				tx.Set(ctx, key(r.IntN(nkeys)), strings.NewReader(strconv.Itoa(commits+1)))
+			}
+			if err := tx.Commit(ctx); err != nil {
+				t.Errorf("blind writes must not conflict: %v", err)
+				return
+			}
+			commits++
+		}
+	}()
+
+	read := func(snap *Snapshot, key string) (string, error) {
+		value, err := snap.Get(ctx, key)
+		if err != nil {
+			return "", err
+		}
+		data, err := io.ReadAll(value)
+		return string(data), err
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < nreaders; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			snap, err := db.NewSnapshot(ctx)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer snap.Discard(ctx)
+
+			r := rand.New(rand.NewPCG(uint64(i), 0))
+			values := make(map[string]string, nreads)
+			for j := 0; j < nreads; j++ {
+				k := key(r.IntN(nkeys))
+				v, err := read(snap, k)
+				if err != nil {
+					t.Errorf("could not read key %s: %v", k, err)
+					return
+				}
+				values[k] = v
+				runtime.Gosched()
+			}
+			// Values visible to the snapshot must not be compacted by the
+			// concurrent commits.
+			for k, want := range values {
+				if got, err := read(snap, k); err != nil || got != want {
+					t.Errorf("key %s changed from %q to %q (%v) in the same snapshot", k, want, got, err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(stop)
+	<-writerDone
+	t.Logf("%d snapshots read %d keys each during %d commits", nreaders, nreads, commits)
+
+	if h := db.Health(); h.LiveSnapshots != 0 || h.LiveTransactions != 0 {
+		t.Errorf("Health = %+v, want no live snapshots or transactions", h)
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"cmp"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// TxInfo holds the metadata of a live transaction.
+type TxInfo struct {
+	ID              uint64
+	SnapshotVersion int64
+	Age             time.Duration
+
+	// Reads and Writes are the number of keys in the read and write sets.
+	Reads  int
+	Writes int
+}
+
+// SnapInfo holds the metadata of a live snapshot.
+type SnapInfo struct {
+	ID              uint64
+	SnapshotVersion int64
+	Age             time.Duration
+}
+
+// LiveTransactions returns the metadata of all live transactions in the
+// increasing order of their ids.
+func (d *Database) LiveTransactions() []TxInfo {
+	now := d.now()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	infos := make([]TxInfo, 0, len(d.liveTxes))
+	for _, tx := range d.liveTxes {
+		infos = append(infos, TxInfo{
+			ID:              tx.id,
+			SnapshotVersion: tx.snapshotVersion,
+			Age:             now.Sub(tx.created),
+			Reads:           int(tx.numReads.Load()),
+			Writes:          int(tx.numWrites.Load()),
+		})
+	}
+	slices.SortFunc(infos, func(a, b TxInfo) int { return cmp.Compare(a.ID, b.ID) })
+	return infos
+}
+
+// LiveSnapshots returns the metadata of all live snapshots in the increasing
+// order of their ids. Layered snapshots are not included.
+func (d *Database) LiveSnapshots() []SnapInfo {
+	now := d.now()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	infos := make([]SnapInfo, 0, len(d.liveSnaps))
+	for _, s := range d.liveSnaps {
+		infos = append(infos, SnapInfo{
+			ID:              s.id,
+			SnapshotVersion: s.snapshotVersion,
+			Age:             now.Sub(s.created),
+		})
+	}
+	slices.SortFunc(infos, func(a, b SnapInfo) int { return cmp.Compare(a.ID, b.ID) })
+	return infos
+}
+
+// debugStats is the response of the /stats debug endpoint.
+type debugStats struct {
+	Health       Health
+	Transactions []TxInfo
+	Snapshots    []SnapInfo
+}
+
+// DebugHandler returns an HTTP handler for inspecting the database. It serves
+// the database health and the live transactions and snapshots as a JSON
+// object at the /stats path. Use http.StripPrefix to mount it under a
+// different path.
+func (d *Database) DebugHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /stats", func(w http.ResponseWriter, r *http.Request) {
+		stats := debugStats{
+			Health:       d.Health(),
+			Transactions: d.LiveTransactions(),
+			Snapshots:    d.LiveSnapshots(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(&stats); err != nil {
+			d.logger.Warn("kvmemdb: could not write debug stats", "err", err)
+		}
+	})
+	return mux
+}
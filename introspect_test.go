@@ -0,0 +1,85 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLiveTransactionsAndSnapshots(t *testing.T) {
+	ctx := context.Background()
+
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	db := New(WithClock(clock.Now))
+	mustSet(ctx, t, db, "a", "1")
+	mustSet(ctx, t, db, "b", "2")
+
+	snap1, _ := db.NewSnapshot(ctx)
+	tx1, _ := db.NewTransaction(ctx)
+	mustGet(ctx, t, tx1, "a")
+	mustGet(ctx, t, tx1, "b")
+	tx1.Set(ctx, "c", strings.NewReader("3"))
+	tx1.Set(ctx, "c", strings.NewReader("4"))
+
+	clock.Advance(time.Second)
+	mustSet(ctx, t, db, "a", "5")
+	tx2, _ := db.NewTransaction(ctx)
+	snap2, _ := db.NewSnapshot(ctx)
+	clock.Advance(time.Second)
+
+	wantTxes := []TxInfo{
+		{ID: tx1.id, SnapshotVersion: 2, Age: 2 * time.Second, Reads: 2, Writes: 1},
+		{ID: tx2.id, SnapshotVersion: 3, Age: time.Second},
+	}
+	if got := db.LiveTransactions(); !reflect.DeepEqual(got, wantTxes) {
+		t.Errorf("LiveTransactions = %+v, want %+v", got, wantTxes)
+	}
+	wantSnaps := []SnapInfo{
+		{ID: snap1.id, SnapshotVersion: 2, Age: 2 * time.Second},
+		{ID: snap2.id, SnapshotVersion: 3, Age: time.Second},
+	}
+	if got := db.LiveSnapshots(); !reflect.DeepEqual(got, wantSnaps) {
+		t.Errorf("LiveSnapshots = %+v, want %+v", got, wantSnaps)
+	}
+
+	// Debug handler reports the same listings.
+	srv := httptest.NewServer(db.DebugHandler())
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "/stats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var stats debugStats
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(stats.Transactions, wantTxes) || !reflect.DeepEqual(stats.Snapshots, wantSnaps) {
+		t.Errorf("/stats = %+v, want transactions %+v and snapshots %+v", stats, wantTxes, wantSnaps)
+	}
+	if stats.Health.LiveTransactions != 2 || stats.Health.LiveSnapshots != 2 {
+		t.Errorf("/stats health = %+v, want two live transactions and snapshots", stats.Health)
+	}
+
+	// Closed transactions and snapshots disappear from the listings.
+	tx1.Rollback(ctx)
+	snap2.Discard(ctx)
+	if got := db.LiveTransactions(); len(got) != 1 || got[0].ID != tx2.id {
+		t.Errorf("LiveTransactions = %+v, want only tx %d", got, tx2.id)
+	}
+	if got := db.LiveSnapshots(); len(got) != 1 || got[0].ID != snap1.id {
+		t.Errorf("LiveSnapshots = %+v, want only snapshot %d", got, snap1.id)
+	}
+	tx2.Rollback(ctx)
+	snap1.Discard(ctx)
+	if txes, snaps := db.LiveTransactions(), db.LiveSnapshots(); len(txes) != 0 || len(snaps) != 0 {
+		t.Errorf("listings = %+v, %+v after closing all, want empty", txes, snaps)
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+// invalidationBufferSize is the capacity of the transaction invalidation
+// channels. Notifications beyond this capacity are dropped.
+const invalidationBufferSize = 64
+
+// InvalidationCh returns a channel that receives the keys in the
+// transaction's read set as concurrent transactions commit updates to
+// them. Such a transaction is doomed to fail its commit, so long-running
+// transactions can use the notifications to abort and retry early.
+//
+// Notifications are best-effort and never block the commits. Keys are dropped
+// when the channel buffer is full, which is reported by InvalidationsDropped.
+// Keys read before the first call are also reported if they were already
+// updated by a committed transaction. Scanned ranges are not covered and
+// transactions with read tracking disabled are never notified.
+//
+// Returned channel is closed when the transaction is committed, rolled back or
+// aborted. Multiple calls return the same channel.
+func (t *Transaction) InvalidationCh() <-chan string {
+	if t.invalidations != nil {
+		return t.invalidations
+	}
+	ch := make(chan string, invalidationBufferSize)
+	if t.check() != nil {
+		close(ch)
+		return ch
+	}
+
+	t.watchMu.Lock()
+	t.watched = make(map[string]struct{}, len(t.reads))
+	for key := range t.reads {
+		t.watched[key] = struct{}{}
+	}
+	t.watchMu.Unlock()
+
+	d := t.db
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	t.invalidations = ch
+	if t.aborted.Load() {
+		// Aborted transaction is already removed from the live transactions.
+		close(ch)
+		t.invalidationsClosed = true
+		return ch
+	}
+	for _, v := range d.concurrentMap[t] {
+		if v.committed {
+			for _, key := range overlappingKeys(t.reads, v.writes) {
+				t.invalidate(key)
+			}
+		}
+	}
+	return ch
+}
+
+// InvalidationsDropped returns true if any notification was dropped because
+// the invalidation channel was full.
+func (t *Transaction) InvalidationsDropped() bool {
+	return t.invalidationsDropped.Load()
+}
+
+// watch adds the key to the watched keys of the transaction if it is
+// subscribed for invalidations.
+func (t *Transaction) watch(key string) {
+	if t.invalidations == nil {
+		return
+	}
+	t.watchMu.Lock()
+	t.watched[key] = struct{}{}
+	t.watchMu.Unlock()
+}
+
+// unwatch removes the key from the watched keys of the transaction.
+func (t *Transaction) unwatch(key string) {
+	if t.invalidations == nil {
+		return
+	}
+	t.watchMu.Lock()
+	delete(t.watched, key)
+	t.watchMu.Unlock()
+}
+
+// invalidate sends the key to the invalidation channel without blocking.
+// Caller must hold the database mutex.
+func (t *Transaction) invalidate(key string) {
+	select {
+	case t.invalidations <- key:
+	default:
+		t.invalidationsDropped.Store(true)
+	}
+}
+
+// notifyInvalidations notifies the live concurrent transactions subscribed
+// for invalidations about the keys in their read sets updated by the
+// committed transaction.
+func (d *Database) notifyInvalidations(tx *Transaction) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, v := range d.concurrentMap[tx] {
+		if v.committed || v.invalidations == nil || v.invalidationsClosed {
+			continue
+		}
+		v.watchMu.Lock()
+		for key := range tx.writes {
+			if _, ok := v.watched[key]; ok {
+				v.invalidate(key)
+			}
+		}
+		v.watchMu.Unlock()
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestInvalidationCh(t *testing.T) {
+	ctx := context.Background()
+
+	db := New()
+	mustSet(ctx, t, db, "key1", "initial1")
+	mustSet(ctx, t, db, "key2", "initial2")
+
+	reader, _ := db.NewTransaction(ctx)
+	defer reader.Rollback(ctx)
+	mustGet(ctx, t, reader, "key1")
+
+	// Keys already updated before the subscription are also reported.
+	mustSet(ctx, t, db, "key1", "updated1")
+	ch := reader.InvalidationCh()
+	if got := <-ch; got != "key1" {
+		t.Errorf("invalidated key = %q, want key1", got)
+	}
+
+	mustGet(ctx, t, reader, "key2")
+	reader.Get(ctx, "missing")
+	mustSet(ctx, t, db, "other", "value")
+	mustSet(ctx, t, db, "key2", "updated2")
+	mustSet(ctx, t, db, "missing", "created")
+	for _, want := range []string{"key2", "missing"} {
+		if got := <-ch; got != want {
+			t.Errorf("invalidated key = %q, want %q", got, want)
+		}
+	}
+	select {
+	case key := <-ch:
+		t.Errorf("unexpected invalidation for key %q", key)
+	default:
+	}
+	if reader.InvalidationsDropped() {
+		t.Errorf("invalidations are dropped unexpectedly")
+	}
+
+	// Reader is doomed after the notifications.
+	reader.Set(ctx, "result", strings.NewReader("value"))
+	if err := reader.Commit(ctx); err == nil {
+		t.Errorf("commit after an invalidation must fail")
+	}
+	if _, ok := <-ch; ok {
+		t.Errorf("invalidation channel is not closed after the commit")
+	}
+}
+
+func TestInvalidationsDropped(t *testing.T) {
+	ctx := context.Background()
+
+	db := New()
+	reader, _ := db.NewTransaction(ctx)
+	defer reader.Rollback(ctx)
+
+	ch := reader.InvalidationCh()
+	n := invalidationBufferSize + 10
+	for i := 0; i < n; i++ {
+		reader.Get(ctx, fmt.Sprintf("key%d", i))
+	}
+
+	// Commits are never blocked by a full invalidation channel.
+	tx, _ := db.NewTransaction(ctx)
+	for i := 0; i < n; i++ {
+		tx.Set(ctx, fmt.Sprintf("key%d", i), strings.NewReader("value"))
+	}
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(ch) != invalidationBufferSize {
+		t.Errorf("invalidation channel has %d keys, want %d", len(ch), invalidationBufferSize)
+	}
+	if !reader.InvalidationsDropped() {
+		t.Errorf("InvalidationsDropped = false, want true")
+	}
+
+	reader.Rollback(ctx)
+	for range ch {
+	}
+}
+
+func TestInvalidationChAfterRollback(t *testing.T) {
+	ctx := context.Background()
+
+	db := New()
+	reader, _ := db.NewTransaction(ctx)
+	writer, _ := db.NewTransaction(ctx)
+
+	ch := reader.InvalidationCh()
+	reader.Get(ctx, "key")
+	writer.Set(ctx, "key", strings.NewReader("value"))
+	reader.Rollback(ctx)
+
+	// Commits of the concurrent transactions skip the closed channel.
+	if err := writer.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := <-ch; ok {
+		t.Errorf("invalidation channel is not closed after the rollback")
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// KeyValidator checks if a key is acceptable to the database. Returns a
+// non-nil error wrapping os.ErrInvalid for unacceptable keys.
+type KeyValidator func(key string) error
+
+// KeySchema is a composition of key validators. A key is acceptable only when
+// all validators accept it.
+type KeySchema []KeyValidator
+
+// KeyError is the error returned for keys rejected by a key schema.
+type KeyError struct {
+	Key string
+	Err error
+}
+
+func (e *KeyError) Error() string {
+	return fmt.Sprintf("invalid key %q: %v", e.Key, e.Err)
+}
+
+func (e *KeyError) Unwrap() error {
+	return e.Err
+}
+
+// ErrInvalidRange is the error returned for key ranges with the begin key
+// larger than the end key. It wraps os.ErrInvalid.
+type ErrInvalidRange struct {
+	Begin, End string
+}
+
+func (e *ErrInvalidRange) Error() string {
+	return fmt.Sprintf("invalid range: begin key %q is larger than end key %q", e.Begin, e.End)
+}
+
+func (e *ErrInvalidRange) Unwrap() error {
+	return os.ErrInvalid
+}
+
+// checkRange returns an *ErrInvalidRange if the begin key is larger than the
+// end key. Empty keys stand for unbounded ends and are always valid.
+func checkRange(begin, end string) error {
+	if begin != "" && end != "" && begin > end {
+		return &ErrInvalidRange{Begin: begin, End: end}
+	}
+	return nil
+}
+
+// Validate returns a *KeyError if any of the validators rejects the input key.
+func (ks KeySchema) Validate(key string) error {
+	for _, validate := range ks {
+		if err := validate(key); err != nil {
+			return &KeyError{Key: key, Err: err}
+		}
+	}
+	return nil
+}
+
+// MaxDepth returns a validator that rejects keys with more than n components
+// separated by the sep string.
+func MaxDepth(sep string, n int) KeyValidator {
+	return func(key string) error {
+		if depth := strings.Count(key, sep) + 1; depth > n {
+			return fmt.Errorf("key depth %d is larger than %d: %w", depth, n, os.ErrInvalid)
+		}
+		return nil
+	}
+}
+
+// AllowedCharset returns a validator that rejects keys with any character
+// that is not in the chars string.
+func AllowedCharset(chars string) KeyValidator {
+	return func(key string) error {
+		if i := strings.IndexFunc(key, func(r rune) bool { return !strings.ContainsRune(chars, r) }); i >= 0 {
+			return fmt.Errorf("character at offset %d is not allowed: %w", i, os.ErrInvalid)
+		}
+		return nil
+	}
+}
+
+// RequiredPrefix returns a validator that rejects keys that don't begin with
+// any of the input prefixes.
+func RequiredPrefix(prefixes ...string) KeyValidator {
+	return func(key string) error {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(key, prefix) {
+				return nil
+			}
+		}
+		return fmt.Errorf("key doesn't have any of the prefixes %q: %w", prefixes, os.ErrInvalid)
+	}
+}
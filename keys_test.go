@@ -0,0 +1,158 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestKeySchema(t *testing.T) {
+	tests := []struct {
+		name    string
+		schema  KeySchema
+		valid   []string
+		invalid []string
+	}{
+		{
+			name:    "MaxDepth",
+			schema:  KeySchema{MaxDepth("/", 2)},
+			valid:   []string{"a", "a/b", "a/"},
+			invalid: []string{"a/b/c", "//"},
+		},
+		{
+			name:    "AllowedCharset",
+			schema:  KeySchema{AllowedCharset("abc/")},
+			valid:   []string{"a", "abc/cba"},
+			invalid: []string{"abd", "a b", "ä"},
+		},
+		{
+			name:    "RequiredPrefix",
+			schema:  KeySchema{RequiredPrefix("users/", "groups/")},
+			valid:   []string{"users/a", "groups/"},
+			invalid: []string{"user/a", "other"},
+		},
+		{
+			name:    "Composed",
+			schema:  KeySchema{RequiredPrefix("users/"), MaxDepth("/", 2), AllowedCharset("abcdefghijklmnopqrstuvwxyz/")},
+			valid:   []string{"users/abc"},
+			invalid: []string{"groups/abc", "users/abc/def", "users/ABC"},
+		},
+	}
+
+	ctx := context.Background()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for _, key := range test.valid {
+				if err := test.schema.Validate(key); err != nil {
+					t.Errorf("Validate(%q) failed: %v", key, err)
+				}
+			}
+			for _, key := range test.invalid {
+				err := test.schema.Validate(key)
+				var kerr *KeyError
+				if !errors.As(err, &kerr) || kerr.Key != key || !errors.Is(err, os.ErrInvalid) {
+					t.Errorf("Validate(%q) = %v, want a *KeyError wrapping os.ErrInvalid", key, err)
+				}
+			}
+
+			db := New(WithKeySchema(test.schema))
+			tx, _ := db.NewTransaction(ctx)
+			defer tx.Rollback(ctx)
+
+			for _, key := range test.valid {
+				if err := tx.Set(ctx, key, strings.NewReader("value")); err != nil {
+					t.Errorf("Set(%q) failed: %v", key, err)
+				}
+			}
+			for _, key := range test.invalid {
+				var kerr *KeyError
+				if err := tx.Set(ctx, key, strings.NewReader("value")); !errors.As(err, &kerr) {
+					t.Errorf("Set(%q) = %v, want a *KeyError", key, err)
+				}
+				if err := tx.Delete(ctx, key); !errors.As(err, &kerr) {
+					t.Errorf("Delete(%q) = %v, want a *KeyError", key, err)
+				}
+			}
+		})
+	}
+}
+
+func TestKeySchemaRestore(t *testing.T) {
+	ctx := context.Background()
+
+	db := New()
+	mustSet(ctx, t, db, "users/a", "a")
+	mustSet(ctx, t, db, "groups/b", "b")
+
+	snap, _ := db.NewSnapshot(ctx)
+	defer snap.Discard(ctx)
+
+	var buf bytes.Buffer
+	if err := snap.Dump(ctx, &buf, DumpOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := Restore(ctx, &buf, WithKeyValidator(RequiredPrefix("users/")))
+	var kerr *KeyError
+	if !errors.As(err, &kerr) || kerr.Key != "groups/b" {
+		t.Fatalf("Restore = %v, want a *KeyError for groups/b", err)
+	}
+}
+
+func TestMaxKeySize(t *testing.T) {
+	ctx := context.Background()
+
+	const limit = 8
+	db := New(WithMaxKeySize(limit))
+
+	ok, long := strings.Repeat("k", limit), strings.Repeat("k", limit+1)
+	mustSet(ctx, t, db, ok, "value")
+
+	snap, _ := db.NewSnapshot(ctx)
+	defer snap.Discard(ctx)
+
+	var buf []byte
+	ops := map[string]func(tx *Transaction, key string) error{
+		"Set":    func(tx *Transaction, key string) error { return tx.Set(ctx, key, strings.NewReader("value")) },
+		"Delete": func(tx *Transaction, key string) error { return tx.Delete(ctx, key) },
+		"Get": func(tx *Transaction, key string) error {
+			_, err := tx.Get(ctx, key)
+			return err
+		},
+		"GetAndDelete": func(tx *Transaction, key string) error {
+			_, err := tx.GetAndDelete(ctx, key)
+			return err
+		},
+		"Update": func(tx *Transaction, key string) error {
+			return tx.Update(ctx, key, func(old []byte) ([]byte, error) { return old, nil })
+		},
+		"Forget": func(tx *Transaction, key string) error { return tx.Forget(ctx, key) },
+		"Snapshot.Get": func(tx *Transaction, key string) error {
+			_, err := snap.Get(ctx, key)
+			return err
+		},
+		"Snapshot.GetInto": func(tx *Transaction, key string) error { return snap.GetInto(ctx, key, &buf) },
+	}
+	for name, op := range ops {
+		tx, _ := db.NewTransaction(ctx)
+		if err := op(tx, long); !errors.Is(err, os.ErrInvalid) {
+			t.Errorf("%s with a long key = %v, want os.ErrInvalid", name, err)
+		}
+		if err := op(tx, ok); errors.Is(err, os.ErrInvalid) {
+			t.Errorf("%s with a key at the limit = %v, want success", name, err)
+		}
+		tx.Rollback(ctx)
+	}
+
+	if _, err := FromMap(ctx, map[string][]byte{long: nil}, WithMaxKeySize(limit)); !errors.Is(err, os.ErrInvalid) {
+		t.Errorf("FromMap with a long key = %v, want os.ErrInvalid", err)
+	}
+
+	// Keys are unlimited by default.
+	mustSet(ctx, t, New(), strings.Repeat("k", 1<<16), "value")
+}
@@ -0,0 +1,15 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"github.com/visvasity/kv"
+)
+
+// KV returns the database as a kv.Database, whose transactions and snapshots
+// are the *Transaction and *Snapshot values of this database, so that it can
+// be used with the helpers of the kv package, e.g., kvutil.WithReader and
+// kvutil.WithReadWriter. Use the snapshots for the read-only workloads.
+func (d *Database) KV() kv.Database {
+	return kv.DatabaseFrom(d.NewTransaction, d.NewSnapshot)
+}
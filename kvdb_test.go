@@ -0,0 +1,51 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/visvasity/kv"
+	"github.com/visvasity/kv/kvutil"
+)
+
+func TestKV(t *testing.T) {
+	ctx := context.Background()
+
+	db := New()
+	err := kvutil.WithReadWriter(ctx, db.KV(), func(ctx context.Context, rw kv.ReadWriter) error {
+		if _, ok := rw.(*Transaction); !ok {
+			t.Errorf("read-writer is a %T, want a *Transaction", rw)
+		}
+		return rw.Set(ctx, "key", strings.NewReader("value"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got string
+	err = kvutil.WithReader(ctx, db.KV(), func(ctx context.Context, r kv.Reader) error {
+		if _, ok := r.(*Snapshot); !ok {
+			t.Errorf("reader is a %T, want a *Snapshot", r)
+		}
+		v, err := r.Get(ctx, "key")
+		if err != nil {
+			return err
+		}
+		data, err := io.ReadAll(v)
+		got = string(data)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "value" {
+		t.Errorf("key = %q, want value", got)
+	}
+	if n := len(db.LiveSnapshots()) + len(db.LiveTransactions()); n != 0 {
+		t.Errorf("%d snapshots and transactions are live after the helpers, want none", n)
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright (c) 2025 Visvasity LLC
+
+// Package kvgob implements helpers to store and retrieve Go values as gob
+// encoded bytes in a key-value database.
+//
+// Values are encoded with encoding/gob, so complex Go types round-trip without
+// any field tags. Concrete types stored behind interface values must be
+// registered with gob.Register before they are encoded or decoded, as required
+// by the encoding/gob package.
+//
+// This package is kept separate from the kvmemdb package so that users who do
+// not need it do not pull in the encoding/gob dependency.
+package kvgob
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"fmt"
+
+	"github.com/visvasity/kv"
+)
+
+// Get decodes the gob encoded value at the key into a new object of type T.
+// Returns os.ErrNotExist if the key doesn't exist.
+func Get[T any](ctx context.Context, g kv.Getter, key string) (*T, error) {
+	value, err := g.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	v := new(T)
+	if err := gob.NewDecoder(value).Decode(v); err != nil {
+		return nil, fmt.Errorf("could not gob-decode value at key %q: %w", key, err)
+	}
+	return v, nil
+}
+
+// Set creates or updates the value at the key with gob encoded bytes of the
+// input object.
+func Set[T any](ctx context.Context, s kv.Setter, key string, value *T) error {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(value); err != nil {
+		return fmt.Errorf("could not gob-encode value for key %q: %w", key, err)
+	}
+	return s.Set(ctx, key, &buf)
+}
@@ -0,0 +1,80 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvgob
+
+import (
+	"context"
+	"encoding/gob"
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/visvasity/kv"
+	"github.com/visvasity/kv/kvutil"
+	"github.com/visvasity/kvmemdb"
+)
+
+type shape interface {
+	Area() float64
+}
+
+type square struct {
+	Side float64
+}
+
+func (s square) Area() float64 { return s.Side * s.Side }
+
+type record struct {
+	Name   string
+	Tags   map[string][]int
+	Shapes []shape
+	next   int // unexported fields are not encoded.
+}
+
+func init() {
+	gob.Register(square{})
+}
+
+func TestSetGet(t *testing.T) {
+	ctx := context.Background()
+
+	mdb := kvmemdb.New()
+	db := kv.DatabaseFrom(mdb.NewTransaction, mdb.NewSnapshot)
+
+	want := &record{
+		Name:   "first",
+		Tags:   map[string][]int{"a": {1, 2}, "b": {3}},
+		Shapes: []shape{square{Side: 2}},
+		next:   10,
+	}
+	err := kvutil.WithReadWriter(ctx, db, func(ctx context.Context, rw kv.ReadWriter) error {
+		return Set(ctx, rw, "record", want)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got *record
+	err = kvutil.WithReader(ctx, db, func(ctx context.Context, r kv.Reader) error {
+		v, err := Get[record](ctx, r, "record")
+		got = v
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want.next = 0
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+
+	err = kvutil.WithReader(ctx, db, func(ctx context.Context, r kv.Reader) error {
+		_, err := Get[record](ctx, r, "missing")
+		return err
+	})
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("want os.ErrNotExist, got %v", err)
+	}
+}
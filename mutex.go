@@ -0,0 +1,50 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import "context"
+
+// ctxMutex is a mutual exclusion lock whose acquisition can be canceled
+// through a context. It must be created with newCtxMutex.
+type ctxMutex chan struct{}
+
+func newCtxMutex() ctxMutex {
+	return make(ctxMutex, 1)
+}
+
+// newCtxMutexes returns n new mutexes.
+func newCtxMutexes(n int) []ctxMutex {
+	ms := make([]ctxMutex, n)
+	for i := range ms {
+		ms[i] = newCtxMutex()
+	}
+	return ms
+}
+
+func (m ctxMutex) Lock() {
+	m <- struct{}{}
+}
+
+// LockContext acquires the lock or returns the context error if the context
+// is canceled before the lock is available.
+func (m ctxMutex) LockContext(ctx context.Context) error {
+	select {
+	case m <- struct{}{}:
+		return nil
+	default:
+	}
+	select {
+	case m <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (m ctxMutex) Unlock() {
+	select {
+	case <-m:
+	default:
+		panic("kvmemdb: unlock of unlocked mutex")
+	}
+}
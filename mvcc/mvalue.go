@@ -3,6 +3,8 @@
 package mvcc
 
 import (
+	"context"
+	"fmt"
 	"slices"
 	"strings"
 )
@@ -30,6 +32,44 @@ func (mv *MultiValue) String() string {
 	return sb.String()
 }
 
+// Values returns all values of the multi-value in the increasing version
+// order. Returned values must not be modified.
+func (mv *MultiValue) Values() []*Value {
+	return slices.Clone(mv.values)
+}
+
+// Validate checks the internal invariants of the multi-value. Returns a
+// non-nil error if the multi-value is empty, holds a nil value or a value
+// without a positive version, has duplicate or out-of-order versions, or has
+// a deleted value with data.
+func (mv *MultiValue) Validate() error {
+	if mv == nil || len(mv.values) == 0 {
+		return fmt.Errorf("multi-value has no versions")
+	}
+	for i, v := range mv.values {
+		if v == nil {
+			return fmt.Errorf("multi-value has a nil value at index %d", i)
+		}
+		if v.version <= 0 {
+			return fmt.Errorf("multi-value has a zero or negative version value at index %d", i)
+		}
+		if v.IsDeleted() && v.data != "" {
+			return fmt.Errorf("multi-value has a deleted value with data at version %d", v.Version())
+		}
+		if i == 0 {
+			continue
+		}
+		prev := mv.values[i-1].Version()
+		if prev == v.Version() {
+			return fmt.Errorf("multi-value has duplicate values at version %d", prev)
+		}
+		if prev > v.Version() {
+			return fmt.Errorf("multi-value version %d is out of order after version %d", v.Version(), prev)
+		}
+	}
+	return nil
+}
+
 // Fetch returns the value found at the given version or the closest lower
 // version to the given version. Returned value can be a deleted value.
 func (mv *MultiValue) Fetch(version int64) (v *Value, found bool) {
@@ -96,9 +136,19 @@ func Append(mv *MultiValue, v *Value) *MultiValue {
 // version and is not deleted. Returns the same input multi-value if no
 // compaction can be performed; otherwise, returns a clone of the input
 // multi-value.
-func Compact(mv *MultiValue, minVersion int64) *MultiValue {
+//
+// Compaction never changes the live value visible at the minVersion or any
+// later version, so Fetch returns the same live value, or a deleted or no
+// value, for those versions before and after the compaction. A deleted value
+// visible at the minVersion is retained when newer values follow it, so that
+// the versions between a deletion and a later recreation keep observing the
+// deletion; it is only removed when it is the only value.
+//
+// Compaction stops early if the context is canceled, in which case the input
+// multi-value is returned unmodified along with the context error.
+func Compact(ctx context.Context, mv *MultiValue, minVersion int64) (*MultiValue, error) {
 	if mv == nil || len(mv.values) == 0 {
-		return nil
+		return nil, nil
 	}
 
 	// If there is only one version and it is not a deleted version, then we
@@ -106,9 +156,9 @@ func Compact(mv *MultiValue, minVersion int64) *MultiValue {
 	if len(mv.values) == 1 {
 		v := mv.values[0]
 		if v.IsDeleted() && v.Version() < minVersion {
-			return nil
+			return nil, nil
 		}
-		return mv
+		return mv, nil
 	}
 
 	index, ok := slices.BinarySearchFunc(mv.values, minVersion, findValue)
@@ -116,15 +166,22 @@ func Compact(mv *MultiValue, minVersion int64) *MultiValue {
 		index = index - 1
 	}
 	if index < 0 {
-		return mv
+		return mv, nil
 	}
 
+	var err error
 	newvs := slices.DeleteFunc(slices.Clone(mv.values), func(v *Value) bool {
-		return v.Version() < mv.values[index].Version()
+		if err == nil {
+			err = ctx.Err()
+		}
+		return err == nil && v.Version() < mv.values[index].Version()
 	})
+	if err != nil {
+		return mv, err
+	}
 
 	if len(newvs) == len(mv.values) {
-		return mv
+		return mv, nil
 	}
-	return &MultiValue{values: newvs}
+	return &MultiValue{values: newvs}, nil
 }
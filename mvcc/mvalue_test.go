@@ -0,0 +1,286 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package mvcc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+	"testing/quick"
+	"time"
+)
+
+func TestMultiValueValidate(t *testing.T) {
+	live := func(ver int64, data string) *Value {
+		v := NewValue(ver)
+		v.SetData(data)
+		return v
+	}
+	deleted := func(ver int64) *Value {
+		v := NewValue(ver)
+		v.Delete()
+		return v
+	}
+
+	valid := NewMultiValue(live(1, "a"))
+	valid = Append(valid, deleted(3))
+	valid = Append(valid, live(5, ""))
+	if err := valid.Validate(); err != nil {
+		t.Errorf("Validate(%v) = %v, want nil", valid, err)
+	}
+	cmv, err := Compact(context.Background(), valid, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmv.Validate(); err != nil {
+		t.Errorf("Validate on the compacted multi-value = %v, want nil", err)
+	}
+
+	tests := []struct {
+		name string
+		mv   *MultiValue
+	}{
+		{"nil", nil},
+		{"empty", &MultiValue{}},
+		{"nil value", &MultiValue{values: []*Value{live(1, "a"), nil}}},
+		{"zero version", &MultiValue{values: []*Value{{data: "a"}}}},
+		{"duplicate versions", &MultiValue{values: []*Value{live(1, "a"), live(2, "b"), live(2, "c")}}},
+		{"duplicate deleted version", &MultiValue{values: []*Value{live(2, "a"), deleted(2)}}},
+		{"out of order", &MultiValue{values: []*Value{live(3, "a"), live(2, "b")}}},
+		{"negative version", &MultiValue{values: []*Value{{version: -1, data: "a"}}}},
+		{"deleted with data", &MultiValue{values: []*Value{{version: 1, deleted: true, data: "a"}}}},
+	}
+	for _, test := range tests {
+		if err := test.mv.Validate(); err == nil {
+			t.Errorf("%s: Validate(%v) = nil, want an error", test.name, test.mv)
+		}
+	}
+}
+
+func TestCompactCanceled(t *testing.T) {
+	mv := NewMultiValue(NewValue(1))
+	for ver := int64(2); ver <= 100; ver++ {
+		v := NewValue(ver)
+		v.SetData("value")
+		mv = Append(mv, v)
+	}
+	want := mv.Values()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-ctx.Done()
+
+	cmv, err := Compact(ctx, mv, 50)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Compact with an expired context = %v, want context.DeadlineExceeded", err)
+	}
+	if cmv != mv {
+		t.Errorf("Compact with an expired context must return the input multi-value")
+	}
+	if err := mv.Validate(); err != nil {
+		t.Errorf("input multi-value is corrupted: %v", err)
+	}
+	if got := mv.Values(); len(got) != len(want) {
+		t.Errorf("input multi-value has %d versions after a canceled compaction, want %d", len(got), len(want))
+	}
+
+	cmv, err = Compact(context.Background(), mv, 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cmv.Values(); len(got) != 51 || got[0].Version() != 50 {
+		t.Errorf("compacted multi-value = %v, want versions from 50", cmv)
+	}
+}
+
+func TestCompactSingleVersion(t *testing.T) {
+	ctx := context.Background()
+
+	// Version 1 value below the minVersion 2 watermark.
+	v := NewValue(1)
+	v.SetData("value")
+	mv := NewMultiValue(v)
+	if cmv, err := Compact(ctx, mv, 2); err != nil || cmv != mv {
+		t.Errorf("Compact(%v, 2) = %v, %v, want the input multi-value", mv, cmv, err)
+	}
+	if got := mv.Values(); len(got) != 1 || got[0] != v || got[0].Data() != "value" {
+		t.Errorf("input multi-value is modified to %v", mv)
+	}
+
+	d := NewValue(1)
+	d.Delete()
+	if cmv, err := Compact(ctx, NewMultiValue(d), 2); err != nil || cmv != nil {
+		t.Errorf("Compact on a single deleted version = %v, %v, want nil", cmv, err)
+	}
+
+	// Random versions and watermarks.
+	check := func(version, minVersion uint32, deleted bool) bool {
+		v := NewValue(int64(version) + 1)
+		if deleted {
+			v.Delete()
+		} else {
+			v.SetData("value")
+		}
+		mv := NewMultiValue(v)
+		cmv, err := Compact(ctx, mv, int64(minVersion))
+		if err != nil {
+			return false
+		}
+		if deleted && v.Version() < int64(minVersion) {
+			return cmv == nil
+		}
+		return cmv == mv && len(mv.Values()) == 1 && mv.Values()[0] == v
+	}
+	if err := quick.Check(check, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+// visible returns the value visible at the version as a string, which is
+// empty for the deleted and absent values.
+func visible(mv *MultiValue, version int64) string {
+	if mv == nil {
+		return ""
+	}
+	v, ok := mv.Fetch(version)
+	if !ok || v.IsDeleted() {
+		return ""
+	}
+	return fmt.Sprintf("%s@%d", v.Data(), v.Version())
+}
+
+// checkCompact returns a non-nil error if compaction at the minVersion changes
+// the value visible at any version at or after the minVersion.
+func checkCompact(mv *MultiValue, minVersion int64) error {
+	before := mv.Values()
+	cmv, err := Compact(context.Background(), mv, minVersion)
+	if err != nil {
+		return err
+	}
+	if cmv != nil {
+		if err := cmv.Validate(); err != nil {
+			return fmt.Errorf("compacted %v at %d is invalid: %w", mv, minVersion, err)
+		}
+	}
+	if got := mv.Values(); len(got) != len(before) {
+		return fmt.Errorf("input %v is modified by the compaction at %d", mv, minVersion)
+	}
+
+	versions := []int64{minVersion, math.MaxInt64}
+	for _, v := range before {
+		versions = append(versions, v.Version()-1, v.Version(), v.Version()+1)
+	}
+	for _, version := range versions {
+		if version < minVersion {
+			continue
+		}
+		if want, got := visible(mv, version), visible(cmv, version); got != want {
+			return fmt.Errorf("Compact(%v, %d) = %v: value at %d is %q, want %q", mv, minVersion, cmv, version, got, want)
+		}
+	}
+	return nil
+}
+
+func TestCompactMatrix(t *testing.T) {
+	// All multi-values with one to four versions at 1, 3, 5 and 7, with all
+	// combinations of the deleted versions, including the leading and trailing
+	// tombstones.
+	for n := 1; n <= 4; n++ {
+		for mask := 0; mask < 1<<n; mask++ {
+			var mv *MultiValue
+			for i := 0; i < n; i++ {
+				v := NewValue(int64(2*i + 1))
+				if mask&(1<<i) != 0 {
+					v.Delete()
+				} else {
+					v.SetData(fmt.Sprintf("v%d", i))
+				}
+				mv = Append(mv, v)
+			}
+
+			oldest, newest := int64(1), int64(2*n-1)
+			for _, minVersion := range []int64{0, 1, oldest, oldest + 1, newest, newest + 1, math.MaxInt64} {
+				if err := checkCompact(mv, minVersion); err != nil {
+					t.Error(err)
+				}
+			}
+		}
+	}
+
+	// Latest live version is never dropped, even at the MaxInt64 watermark
+	// used when there are no readers.
+	v := NewValue(1)
+	v.SetData("value")
+	if cmv, _ := Compact(context.Background(), NewMultiValue(v), math.MaxInt64); visible(cmv, math.MaxInt64) != "value@1" {
+		t.Errorf("Compact at MaxInt64 dropped the only live version")
+	}
+}
+
+func TestCompactPreservesFetch(t *testing.T) {
+	// Fetch(v) for any v >= minVersion returns the same value before and after
+	// Compact.
+	check := func(gaps []uint8, deleted []bool, minVersion uint16) bool {
+		var mv *MultiValue
+		version := int64(0)
+		for i, gap := range gaps {
+			version += int64(gap) + 1
+			v := NewValue(version)
+			if i < len(deleted) && deleted[i] {
+				v.Delete()
+			} else {
+				v.SetData(fmt.Sprint(i))
+			}
+			mv = Append(mv, v)
+		}
+		if mv == nil {
+			return true
+		}
+		if err := checkCompact(mv, int64(minVersion)); err != nil {
+			t.Log(err)
+			return false
+		}
+		return true
+	}
+	if err := quick.Check(check, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCompactStraddlingTombstone(t *testing.T) {
+	ctx := context.Background()
+
+	// Key is created at 1, deleted at 3 and recreated at 6.
+	v1, d3, v6 := NewValue(1), NewValue(3), NewValue(6)
+	v1.SetData("v1")
+	d3.Delete()
+	v6.SetData("v6")
+	mv := Append(Append(NewMultiValue(v1), d3), v6)
+
+	// Deletion stays observable, as a deleted value rather than no value, at
+	// the versions between the deletion and the recreation.
+	for minVersion := int64(3); minVersion < 6; minVersion++ {
+		cmv, err := Compact(ctx, mv, minVersion)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for version := minVersion; version < 6; version++ {
+			if v, ok := cmv.Fetch(version); !ok || v != d3 {
+				t.Errorf("Compact(%v, %d) = %v: value at %d is %v, want the deletion at 3", mv, minVersion, cmv, version, v)
+			}
+		}
+		if v, ok := cmv.Fetch(6); !ok || v != v6 {
+			t.Errorf("Compact(%v, %d) = %v: value at 6 is %v, want v6", mv, minVersion, cmv, v)
+		}
+	}
+
+	// Deletion is removed once the recreation is visible at the minVersion.
+	cmv, err := Compact(ctx, mv, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cmv.Values(); len(got) != 1 || got[0] != v6 {
+		t.Errorf("Compact(%v, 6) = %v, want only v6", mv, cmv)
+	}
+}
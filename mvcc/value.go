@@ -8,6 +8,7 @@ import (
 
 type Value struct {
 	version int64
+	deleted bool
 	data    string
 }
 
@@ -22,15 +23,19 @@ func NewValue(ver int64) *Value {
 	}
 }
 
+// Clone returns a copy of the value at a newer version. Deleted values are
+// cloned as deleted values and live values, including empty values, are
+// cloned as live values.
 func (v *Value) Clone(ver int64) *Value {
 	if ver <= 0 {
 		panic("version value cannot be -ve")
 	}
-	if ver <= v.version {
+	if ver <= v.Version() {
 		panic(fmt.Sprintf("new version %d cannot be smaller than data version %d", ver, v.Version()))
 	}
 	return &Value{
 		version: ver,
+		deleted: v.deleted,
 		data:    v.data,
 	}
 }
@@ -46,27 +51,24 @@ func (v *Value) Data() string {
 	return v.data
 }
 
+// SetData updates the value data. An empty data string is a valid, live value
+// and is never treated as a deleted value. Setting data on a deleted value
+// makes it live again.
 func (v *Value) SetData(data string) {
-	if v.IsDeleted() {
-		v.version = -v.version
-	}
+	v.deleted = false
 	v.data = data
 }
 
+// Delete marks the value as deleted and drops its data.
 func (v *Value) Delete() {
-	if v.version > 0 {
-		v.data = ""
-		v.version = -v.version
-	}
+	v.deleted = true
+	v.data = ""
 }
 
 func (v *Value) Version() int64 {
-	if v.IsDeleted() {
-		return -v.version
-	}
 	return v.version
 }
 
 func (v *Value) IsDeleted() bool {
-	return v.version < 0
+	return v.deleted
 }
@@ -0,0 +1,34 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package mvcc
+
+import "testing"
+
+func TestValueEmptyData(t *testing.T) {
+	v := NewValue(1)
+	v.SetData("")
+	if v.IsDeleted() {
+		t.Fatalf("empty value %v is reported as deleted", v)
+	}
+
+	v.Delete()
+	if !v.IsDeleted() || v.Version() != 1 {
+		t.Fatalf("deleted value %v is not reported as deleted at version 1", v)
+	}
+
+	v.SetData("")
+	if v.IsDeleted() || v.Version() != 1 || v.Data() != "" {
+		t.Fatalf("value %v must be live and empty at version 1", v)
+	}
+
+	c := v.Clone(2)
+	if c.IsDeleted() || c.Version() != 2 || c.Data() != "" {
+		t.Fatalf("clone %v of an empty value must be live and empty", c)
+	}
+
+	v.Delete()
+	d := v.Clone(3)
+	if !d.IsDeleted() || d.Version() != 3 {
+		t.Fatalf("clone %v of a deleted value must be deleted at version 3", d)
+	}
+}
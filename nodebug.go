@@ -0,0 +1,9 @@
+// Copyright (c) 2025 Visvasity LLC
+
+//go:build !kvmemdb_debug
+
+package kvmemdb
+
+// debugChecks enables expensive consistency checks on the internal data
+// structures. It is only enabled in builds with the kvmemdb_debug tag.
+const debugChecks = false
@@ -0,0 +1,358 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/visvasity/syncmap"
+	"golang.org/x/time/rate"
+)
+
+// Option configures optional features of a Database. Invalid option values
+// are rejected by NewWithOptions and adjusted to the nearest valid values, as
+// documented by each option, by New.
+type Option func(*Database)
+
+// invalidOption records an error wrapping os.ErrInvalid for the named option,
+// which is reported by NewWithOptions.
+func (d *Database) invalidOption(name, format string, args ...any) {
+	err := fmt.Errorf("%s: %s: %w", name, fmt.Sprintf(format, args...), os.ErrInvalid)
+	d.optionErrs = append(d.optionErrs, err)
+}
+
+// WithMutexShards partitions the key space into n shards, each protected by a
+// separate mutex, so that commits updating keys in different shards can apply
+// their writes concurrently. Values less than one are treated as one, which
+// serializes all commits that update any key.
+func WithMutexShards(n int) Option {
+	return func(d *Database) {
+		if n < 1 {
+			d.invalidOption("WithMutexShards", "number of shards %d is not positive", n)
+		}
+		d.shards = newCtxMutexes(max(n, 1))
+	}
+}
+
+// WithMaxKeySize limits the size of keys to n bytes. Operations on larger keys
+// fail with an error wrapping os.ErrInvalid. Zero or negative values mean
+// unlimited, which is the default.
+func WithMaxKeySize(n int) Option {
+	return func(d *Database) {
+		if n < 0 {
+			d.invalidOption("WithMaxKeySize", "key size %d is negative", n)
+		}
+		d.maxKeySize = max(n, 0)
+	}
+}
+
+// WithKeyBloomFilter maintains a bloom filter of all keys in the database, so
+// that lookups for keys that do not exist can skip the key-value map. It is
+// meant for read-heavy workloads where most lookups miss.
+//
+// Filter is sized for the estimated number of keys, with a one percent false
+// positive rate unless WithBloomFilter is also used. It is rebuilt, in the
+// committing goroutine with all commits paused, when it holds more keys than
+// it is sized for, or after half as many keys are deleted, because bloom
+// filters cannot remove keys.
+func WithKeyBloomFilter(estimatedKeys int) Option {
+	return func(d *Database) {
+		if estimatedKeys < 1 {
+			d.invalidOption("WithKeyBloomFilter", "estimated number of keys %d is not positive", estimatedKeys)
+		}
+		d.bloomKeys = max(estimatedKeys, 1)
+	}
+}
+
+// WithBloomFilter is similar to WithKeyBloomFilter, but sizes the filter for
+// the input false positive rate, which must be between zero and one. Filter
+// grows from a small size as keys are added, unless WithKeyBloomFilter is
+// also used with an estimated number of keys.
+func WithBloomFilter(falsePositiveRate float64) Option {
+	return func(d *Database) {
+		if falsePositiveRate <= 0 || falsePositiveRate >= 1 {
+			d.invalidOption("WithBloomFilter", "false positive rate %g is not between zero and one", falsePositiveRate)
+			falsePositiveRate = defaultBloomFalsePositiveRate
+		}
+		d.bloomFPR = falsePositiveRate
+	}
+}
+
+// WithPrefixIndex maintains a radix tree of all keys in the database, so that
+// ScanPrefix and the range scans with a common prefix in their begin and end
+// keys only visit the matching keys, instead of all keys in the database.
+//
+// Index costs roughly the size of the keys in memory and adds a mutex
+// acquisition to the commits creating or removing keys.
+func WithPrefixIndex() Option {
+	return func(d *Database) {
+		d.prefixIndex = new(prefixIndex)
+	}
+}
+
+// WithReverseIndex maintains an index from the SHA-256 hashes of the values to
+// the keys holding them, so that Database.FindByValue can find the keys with
+// a value without visiting all keys.
+//
+// Index holds a hash and a key reference for every live key and hashes every
+// value written by the commits.
+func WithReverseIndex() Option {
+	return func(d *Database) {
+		d.reverseIndex = newReverseIndex()
+	}
+}
+
+// WithMemoryLimit limits the total size of the live keys and their latest
+// values, as reported by SizeBytes, to maxBytes. When a commit makes the
+// database larger, the least recently written keys are deleted after the
+// commit until the database fits within the limit. Zero or negative values
+// mean unlimited, which is the default.
+//
+// Limit is not enforced on the databases created from a map or a dump until
+// their first commit.
+func WithMemoryLimit(maxBytes int64) Option {
+	return func(d *Database) {
+		if maxBytes < 0 {
+			d.invalidOption("WithMemoryLimit", "memory limit %d is negative", maxBytes)
+		}
+		d.memoryLimit = max(maxBytes, 0)
+	}
+}
+
+// WithEvictionCallback sets a function that is called with every key evicted
+// because of the memory limit, after the eviction is committed.
+func WithEvictionCallback(fn func(evictedKey string)) Option {
+	return func(d *Database) {
+		d.evictionCallback = fn
+	}
+}
+
+// WithKeyValidator adds a validator to the key schema of the database. Nil
+// validators are ignored.
+func WithKeyValidator(v KeyValidator) Option {
+	return func(d *Database) {
+		if v == nil {
+			d.invalidOption("WithKeyValidator", "validator is nil")
+			return
+		}
+		d.keySchema = append(d.keySchema, v)
+	}
+}
+
+// WithKeySchema adds all validators of a key schema to the key schema of the
+// database. Keys are validated when they are updated by a transaction and
+// when the database is restored from a dump. Nil validators are ignored.
+func WithKeySchema(ks KeySchema) Option {
+	return func(d *Database) {
+		for i, v := range ks {
+			if v == nil {
+				d.invalidOption("WithKeySchema", "validator %d is nil", i)
+				continue
+			}
+			d.keySchema = append(d.keySchema, v)
+		}
+	}
+}
+
+// WithClock sets the function used by the database to read the current time.
+// Database uses time.Now by default. Nil functions are ignored.
+func WithClock(now func() time.Time) Option {
+	return func(d *Database) {
+		if now == nil {
+			d.invalidOption("WithClock", "clock function is nil")
+			return
+		}
+		d.now = now
+	}
+}
+
+// WithAccessTracking enables recording of the last read and write times for
+// all live keys, which are available through the Database.KeyInfo method.
+//
+// Access tracking keeps a separate entry for every live key, which costs
+// roughly a hundred bytes per key in addition to the key-value data, and adds
+// a clock read and an atomic store to every read and write.
+func WithAccessTracking() Option {
+	return func(d *Database) {
+		d.access = new(syncmap.Map[string, *keyAccess])
+	}
+}
+
+// WithDefaultTimeout bounds the transaction operations that can block, when
+// they are invoked with a context that has no deadline, by a timeout of d.
+// Such operations fail with an error wrapping context.DeadlineExceeded when
+// the timeout expires. It applies to reading the values in Set, waiting for
+// the locks in Commit, CommitInto and NextSequence.
+//
+// Timeout only bounds the work performed by this package. A stalled value
+// reader is abandoned to a goroutine, which finishes when the reader returns.
+// Zero or negative values disable the timeout, which is the default.
+func WithDefaultTimeout(d time.Duration) Option {
+	return func(db *Database) {
+		if d < 0 {
+			db.invalidOption("WithDefaultTimeout", "timeout %v is negative", d)
+		}
+		db.defaultTimeout = d
+	}
+}
+
+// WithLogger sets the logger for the diagnostic messages from the database.
+// Database uses slog.Default() by default. Nil loggers are ignored.
+func WithLogger(logger *slog.Logger) Option {
+	return func(d *Database) {
+		if logger == nil {
+			d.invalidOption("WithLogger", "logger is nil")
+			return
+		}
+		d.logger = logger
+	}
+}
+
+// WithSlowCommitThreshold enables logging of the commits that take longer than
+// d to validate and apply their updates, and of the transaction creations that
+// wait longer than d for the database lock. Log records are emitted at the
+// warning level through the database logger.
+//
+// Commit phases are only timed when the threshold is positive.
+func WithSlowCommitThreshold(d time.Duration) Option {
+	return func(db *Database) {
+		if d < 0 {
+			db.invalidOption("WithSlowCommitThreshold", "threshold %v is negative", d)
+		}
+		db.slowCommitThreshold = d
+	}
+}
+
+// WithStagingObserver registers a function that is invoked when a key is
+// staged for update more than n times in a single transaction, which usually
+// indicates a hot loop rewriting the same key. The function is invoked once
+// per key and transaction, with the transaction id and the staging count, on
+// the goroutine staging the update.
+func WithStagingObserver(n int, fn func(txID uint64, key string, count int)) Option {
+	return func(d *Database) {
+		if n < 0 {
+			d.invalidOption("WithStagingObserver", "staging limit %d is negative", n)
+		}
+		if fn == nil {
+			d.invalidOption("WithStagingObserver", "observer function is nil")
+		}
+		d.stagingLimit = max(n, 0)
+		d.stagingObserver = fn
+	}
+}
+
+// WithMaxSnapshotAge discards the snapshots automatically when they are older
+// than d, so that a leaked or stalled snapshot doesn't prevent the compaction
+// of the older versions indefinitely. Operations on an expired snapshot fail
+// with an error wrapping os.ErrInvalid, including the scans in progress, and
+// its iterators without an error pointer stop early. Expired snapshots should
+// still be discarded. Zero or negative values disable the expiry, which is
+// the default.
+//
+// Expiry uses the wall clock, not the WithClock function.
+func WithMaxSnapshotAge(d time.Duration) Option {
+	return func(db *Database) {
+		if d < 0 {
+			db.invalidOption("WithMaxSnapshotAge", "max age %v is negative", d)
+		}
+		db.maxSnapshotAge = d
+	}
+}
+
+// WithChunkSize stores the values larger than n bytes in chunks of at most n
+// bytes, so that large values are never copied into a single buffer. Chunks
+// are stored under the internal keys in a reserved namespace, which begins
+// with a zero byte, along with a manifest under the user key. Chunks are
+// written and deleted in the same transaction as their keys, so they are
+// always consistent with the manifests. Get returns a reader streaming the
+// chunks and the scans only report the user keys.
+//
+// Set and Delete read the key to delete the chunks of its previous value, so
+// they conflict with the concurrent updates to the key, unlike the blind
+// writes. Keys in the reserved namespace are rejected. Methods reporting the
+// stored values as is, i.e., GetVersionRange, PendingWrites, FindByValue and
+// Dump, report the manifests for the chunked values; dumps also include the
+// chunks, so that they can be restored into a database with chunking
+// enabled. Zero or negative values disable chunking, which is the default.
+func WithChunkSize(n int) Option {
+	return func(d *Database) {
+		if n < 0 {
+			d.invalidOption("WithChunkSize", "chunk size %d is negative", n)
+		}
+		d.chunkSize = max(n, 0)
+	}
+}
+
+// WithWriteRateLimit limits the rate of the writes to the keys with the input
+// prefix. Every update or deletion of a key with the prefix takes a token
+// from the limiter when the transaction commits. The option can be repeated
+// for multiple prefixes, in which case the writes are counted against every
+// matching prefix. Writes are not rate limited by default.
+//
+// Commits exceeding the limits are delayed or failed with an *ErrRateLimited
+// error according to the WithRateLimitPolicy option. Tokens are taken before
+// the commits are validated, so they are not returned for the commits that
+// fail with a conflict. Commits with more writes under a prefix than the burst
+// size of its limiter always fail. Nil limiters are ignored.
+func WithWriteRateLimit(prefix string, limiter *rate.Limiter) Option {
+	return func(d *Database) {
+		if limiter == nil {
+			d.invalidOption("WithWriteRateLimit", "limiter for prefix %q is nil", prefix)
+			return
+		}
+		d.writeLimits = append(d.writeLimits, writeLimit{prefix: prefix, limiter: limiter})
+	}
+}
+
+// WithRateLimitPolicy sets the behavior of the commits exceeding the write
+// rate limits. Default policy is RateLimitWait, which is also used for the
+// unknown policies.
+func WithRateLimitPolicy(p RateLimitPolicy) Option {
+	return func(d *Database) {
+		if p != RateLimitWait && p != RateLimitFail {
+			d.invalidOption("WithRateLimitPolicy", "policy %d is unknown", p)
+			p = RateLimitWait
+		}
+		d.rateLimitPolicy = p
+	}
+}
+
+// WithGroupCommit enables the group commit, which batches the commits that
+// arrive within the window after the first commit of a batch. Transactions in
+// a batch are validated and applied one after the other with the shard locks
+// and the database mutex acquired once for the whole batch, so they are
+// assigned distinct commit versions and conflict with each other as if they
+// were committed sequentially. Zero or negative windows disable the group
+// commit, which is the default.
+//
+// Group commit trades the latency of the individual commits, which wait for
+// the window, for the throughput under many concurrent writers. Grouped
+// commits are not reported by the slow commit logging.
+func WithGroupCommit(window time.Duration) Option {
+	return func(d *Database) {
+		if window < 0 {
+			d.invalidOption("WithGroupCommit", "window %v is negative", window)
+		}
+		d.groupCommitter = nil
+		if window > 0 {
+			d.groupCommitter = &groupCommitter{window: window}
+		}
+	}
+}
+
+// WithIdempotencyWindow sets the duration for which the idempotency keys of
+// the committed transactions are retained, so that retries of a commit with
+// the same key within the window are not applied again. Default window is ten
+// minutes. Zero or negative values are replaced by the default.
+func WithIdempotencyWindow(d time.Duration) Option {
+	return func(db *Database) {
+		if d <= 0 {
+			db.invalidOption("WithIdempotencyWindow", "window %v is not positive", d)
+			d = defaultIdempotencyWindow
+		}
+		db.idempotencyWindow = d
+	}
+}
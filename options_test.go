@@ -0,0 +1,80 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewWithOptions(t *testing.T) {
+	ctx := context.Background()
+
+	db, err := NewWithOptions(WithMutexShards(4), WithMaxKeySize(8), WithBloomFilter(0.01), WithGroupCommit(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustSet(ctx, t, db, "key", "value")
+	snap, _ := db.NewSnapshot(ctx)
+	if got := mustGet(ctx, t, snap, "key"); got != "value" {
+		t.Errorf("key = %q, want value", got)
+	}
+	snap.Discard(ctx)
+
+	invalid := map[string]Option{
+		"WithMutexShards":         WithMutexShards(0),
+		"WithMaxKeySize":          WithMaxKeySize(-1),
+		"WithKeyBloomFilter":      WithKeyBloomFilter(-1),
+		"WithBloomFilter":         WithBloomFilter(1),
+		"WithMemoryLimit":         WithMemoryLimit(-1),
+		"WithKeyValidator":        WithKeyValidator(nil),
+		"WithKeySchema":           WithKeySchema(KeySchema{MaxDepth("/", 2), nil}),
+		"WithClock":               WithClock(nil),
+		"WithDefaultTimeout":      WithDefaultTimeout(-time.Second),
+		"WithLogger":              WithLogger(nil),
+		"WithSlowCommitThreshold": WithSlowCommitThreshold(-time.Second),
+		"WithStagingObserver":     WithStagingObserver(1, nil),
+		"WithMaxSnapshotAge":      WithMaxSnapshotAge(-time.Second),
+		"WithChunkSize":           WithChunkSize(-1),
+		"WithWriteRateLimit":      WithWriteRateLimit("a/", nil),
+		"WithRateLimitPolicy":     WithRateLimitPolicy(RateLimitPolicy(7)),
+		"WithGroupCommit":         WithGroupCommit(-time.Second),
+		"WithIdempotencyWindow":   WithIdempotencyWindow(0),
+	}
+	for name, opt := range invalid {
+		db, err := NewWithOptions(opt)
+		if !errors.Is(err, os.ErrInvalid) || !strings.Contains(err.Error(), name) {
+			t.Errorf("NewWithOptions(%s) = %v, want an os.ErrInvalid naming the option", name, err)
+		}
+		if db != nil {
+			t.Errorf("NewWithOptions(%s) returned a database with an error", name)
+		}
+
+		// New adjusts the invalid values instead.
+		db = New(opt)
+		mustSet(ctx, t, db, "key", "value")
+	}
+
+	// All invalid options are reported together.
+	_, err = NewWithOptions(WithMaxKeySize(-1), WithMutexShards(4), WithChunkSize(-1), WithLogger(nil))
+	if err == nil {
+		t.Fatal("NewWithOptions with multiple invalid options succeeded")
+	}
+	for _, name := range []string{"WithMaxKeySize", "WithChunkSize", "WithLogger"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("NewWithOptions error %q doesn't name %s", err, name)
+		}
+	}
+	if strings.Contains(err.Error(), "WithMutexShards") {
+		t.Errorf("NewWithOptions error %q names the valid WithMutexShards", err)
+	}
+
+	// Other constructors validate the options too.
+	if _, err := FromMap(ctx, map[string][]byte{"a": []byte("1")}, WithChunkSize(-1)); !errors.Is(err, os.ErrInvalid) {
+		t.Errorf("FromMap with an invalid option = %v, want os.ErrInvalid", err)
+	}
+}
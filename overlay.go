@@ -0,0 +1,111 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+
+	"github.com/visvasity/kvmemdb/mvcc"
+)
+
+// Freeze makes the database read-only permanently. Commits of transactions
+// with updates fail with an error wrapping os.ErrPermission afterwards.
+// Freeze returns after the updates of all transactions committed before it are
+// applied. Frozen databases can be shared as the base of multiple overlay
+// databases.
+func (d *Database) Freeze(ctx context.Context) error {
+	d.mu.Lock()
+	d.frozen.Store(true)
+	version := d.commitVersion
+	d.mu.Unlock()
+
+	return d.WaitForVersion(ctx, version)
+}
+
+// Frozen returns true if the database is frozen.
+func (d *Database) Frozen() bool {
+	return d.frozen.Load()
+}
+
+// NewOverlay creates a database on top of a frozen base database, which is
+// shared without copying. Reads fall through to the base for keys that were
+// never updated in the overlay. Updates and deletions only change the
+// overlay, where deletions hide the base keys. Scans merge the keys from both
+// databases. Conflicts are detected only between the overlay transactions,
+// because the base never changes.
+//
+// Versions of the base values, as reported by GetVersion, are from the base
+// database. Full history dumps of the overlay snapshots only include the
+// overlay updates. Returns an error wrapping os.ErrInvalid if the base is not
+// frozen or if any option is invalid, as with NewWithOptions.
+func NewOverlay(base *Database, opts ...Option) (*Database, error) {
+	if base == nil || !base.Frozen() {
+		return nil, fmt.Errorf("overlay base must be a frozen database: %w", os.ErrInvalid)
+	}
+	d, err := NewWithOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+	d.base = base
+	return d, nil
+}
+
+// fetch returns the value of the key visible at the input version, which could
+// be a deleted value. Keys that do not exist at the version are looked up in
+// the base database, if any. Returns nil if key doesn't exist.
+func (d *Database) fetch(key string, version int64) *mvcc.Value {
+	if mv, ok := d.loadKey(key); ok {
+		if v, ok := mv.Fetch(version); ok {
+			return v
+		}
+	}
+	if d.base != nil {
+		return d.base.fetch(key, math.MaxInt64)
+	}
+	return nil
+}
+
+// lastLiveValue returns the newest retained value of the key that is not a
+// deletion, at or before the input version. Keys without such a value are
+// looked up in the base database, if any. Returns nil if no such value
+// exists.
+func (d *Database) lastLiveValue(key string, version int64) *mvcc.Value {
+	if mv, ok := d.loadKey(key); ok {
+		values := mv.Values()
+		for i := len(values) - 1; i >= 0; i-- {
+			if v := values[i]; v.Version() <= version && !v.IsDeleted() {
+				return v
+			}
+		}
+	}
+	if d.base != nil {
+		if v := d.base.fetch(key, math.MaxInt64); v != nil && !v.IsDeleted() {
+			return v
+		}
+	}
+	return nil
+}
+
+// rangeKeys yields all keys in the database, including the base keys that are
+// not updated in the database, in no-specific order.
+func (d *Database) rangeKeys(yield func(string) bool) {
+	for key := range d.kvs.Range {
+		if !yield(key) {
+			return
+		}
+	}
+	if d.base == nil {
+		return
+	}
+	for key := range d.base.rangeKeys {
+		if _, ok := d.kvs.Load(key); ok {
+			continue
+		}
+		if !yield(key) {
+			return
+		}
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOverlay(t *testing.T) {
+	ctx := context.Background()
+
+	base, err := FromMap(ctx, map[string][]byte{"a": []byte("1"), "b": []byte("2"), "c": []byte("3")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewOverlay(base); !errors.Is(err, os.ErrInvalid) {
+		t.Errorf("NewOverlay on a writable base = %v, want os.ErrInvalid", err)
+	}
+	if err := base.Freeze(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := setKey(ctx, base, "a", "updated"); !errors.Is(err, os.ErrPermission) {
+		t.Errorf("commit on a frozen database = %v, want os.ErrPermission", err)
+	}
+
+	overlay, err := NewOverlay(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := NewOverlay(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Reads fall through to the base for the keys not in the overlay.
+	before, _ := overlay.NewSnapshot(ctx)
+	defer before.Discard(ctx)
+
+	tx, _ := overlay.NewTransaction(ctx)
+	if got := mustGet(ctx, t, tx, "a"); got != "1" {
+		t.Errorf("a = %q, want the base value 1", got)
+	}
+	tx.Set(ctx, "a", strings.NewReader("10"))
+	tx.Delete(ctx, "b")
+	tx.Set(ctx, "d", strings.NewReader("4"))
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// Deletion of an already deleted key keeps hiding the base key.
+	tx, _ = overlay.NewTransaction(ctx)
+	tx.Delete(ctx, "b")
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(name string, db *Database, want []string) {
+		t.Helper()
+
+		snap, _ := db.NewSnapshot(ctx)
+		defer snap.Discard(ctx)
+		ascend, descend := readAll(ctx, t, snap, "", "")
+		if !reflect.DeepEqual(ascend, want) {
+			t.Errorf("%s: Ascend = %q, want %q", name, ascend, want)
+		}
+		if len(descend) != len(want) {
+			t.Errorf("%s: Descend = %q, want %d keys", name, descend, len(want))
+		}
+		tx, _ := db.NewTransaction(ctx)
+		defer tx.Rollback(ctx)
+		if got, _ := readAll(ctx, t, tx, "", ""); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: tx Ascend = %q, want %q", name, got, want)
+		}
+	}
+	check("overlay", overlay, []string{"a=10", "c=3", "d=4"})
+	check("other overlay", other, []string{"a=1", "b=2", "c=3"})
+	check("base", base, []string{"a=1", "b=2", "c=3"})
+	if got, _ := readAll(ctx, t, before, "", ""); !reflect.DeepEqual(got, []string{"a=1", "b=2", "c=3"}) {
+		t.Errorf("overlay snapshot before the updates = %q, want the base values", got)
+	}
+
+	// Concurrent updates to a base key conflict in the overlay.
+	tx1, _ := other.NewTransaction(ctx)
+	defer tx1.Rollback(ctx)
+	tx2, _ := other.NewTransaction(ctx)
+	defer tx2.Rollback(ctx)
+	for _, tx := range []*Transaction{tx1, tx2} {
+		mustGet(ctx, t, tx, "c")
+		tx.Set(ctx, "c", strings.NewReader("30"))
+	}
+	if err := tx1.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx2.Commit(ctx); err == nil {
+		t.Errorf("concurrent updates to a base key must conflict")
+	}
+}
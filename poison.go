@@ -0,0 +1,34 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrCorrupted is the error returned by all operations on a database after a
+// commit has panicked while applying its updates, which could have left the
+// database with only a part of the updates. Such databases no longer serve any
+// reads or commits, because their state could be inconsistent.
+var ErrCorrupted = errors.New("database is corrupted")
+
+// poison marks the database as corrupted with the value recovered from a
+// commit of the transaction, and returns the error that all operations fail
+// with from now on. Only the first cause is retained.
+func (d *Database) poison(tx *Transaction, r any) error {
+	err := fmt.Errorf("%w: commit of tx %d panicked: %v", ErrCorrupted, tx.id, r)
+	if d.poisoned.CompareAndSwap(nil, &err) {
+		d.logger.Error("kvmemdb: database is corrupted", "tx", tx.String(), "panic", r)
+	}
+	return *d.poisoned.Load()
+}
+
+// checkPoisoned returns a non-nil error wrapping ErrCorrupted if the database
+// is corrupted by a failed commit.
+func (d *Database) checkPoisoned() error {
+	if errp := d.poisoned.Load(); errp != nil {
+		return *errp
+	}
+	return nil
+}
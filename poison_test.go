@@ -0,0 +1,76 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestCommitPanic(t *testing.T) {
+	ctx := context.Background()
+
+	db := New()
+	if err := setKey(ctx, db, "a", "old"); err != nil {
+		t.Fatal(err)
+	}
+	snap, err := db.NewSnapshot(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer snap.Discard(ctx)
+	other, err := db.NewTransaction(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Rollback(ctx)
+
+	// Fail the commit after some of its updates are prepared.
+	prepared := 0
+	db.hooks = func(point string) {
+		if point == hookCommitPrepare {
+			if prepared++; prepared == 3 {
+				panic("injected failure")
+			}
+		}
+	}
+
+	tx, _ := db.NewTransaction(ctx)
+	for _, key := range []string{"a", "b", "c", "d"} {
+		tx.Set(ctx, key, strings.NewReader("new"))
+	}
+	if err := tx.Commit(ctx); !errors.Is(err, ErrCorrupted) {
+		t.Fatalf("commit with a panic: want ErrCorrupted, got %v", err)
+	}
+	db.hooks = nil
+
+	// None of the updates are applied.
+	if v := db.fetch("a", math.MaxInt64); v == nil || v.Data() != "old" {
+		t.Errorf("key a must retain its old value, got %v", v)
+	}
+	for _, key := range []string{"b", "c", "d"} {
+		if v := db.fetch(key, math.MaxInt64); v != nil {
+			t.Errorf("key %s must not be created, got %v", key, v)
+		}
+	}
+
+	// All further operations fail.
+	if _, err := db.NewTransaction(ctx); !errors.Is(err, ErrCorrupted) {
+		t.Errorf("NewTransaction: want ErrCorrupted, got %v", err)
+	}
+	if _, err := db.NewSnapshot(ctx); !errors.Is(err, ErrCorrupted) {
+		t.Errorf("NewSnapshot: want ErrCorrupted, got %v", err)
+	}
+	if _, err := snap.Get(ctx, "a"); !errors.Is(err, ErrCorrupted) {
+		t.Errorf("Snapshot.Get: want ErrCorrupted, got %v", err)
+	}
+	if _, err := other.Get(ctx, "a"); !errors.Is(err, ErrCorrupted) {
+		t.Errorf("Transaction.Get: want ErrCorrupted, got %v", err)
+	}
+	if err := other.Commit(ctx); !errors.Is(err, ErrCorrupted) {
+		t.Errorf("Commit: want ErrCorrupted, got %v", err)
+	}
+}
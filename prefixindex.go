@@ -0,0 +1,218 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"iter"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// prefixIndex is a radix tree of all keys in the database, which enumerates
+// the keys with a prefix in O(p+m) time, where p is the length of the prefix
+// and m is the number of matching keys.
+//
+// Keys are added before they are stored in the key-value map and removed
+// after they are deleted from it, so the index is always a superset of the
+// keys in the map. Index has its own mutex, because commits updating keys in
+// different shards apply their updates concurrently.
+type prefixIndex struct {
+	mu   sync.RWMutex
+	root trieNode
+}
+
+// trieNode is a radix tree node. Children are sorted by the first byte of
+// their edge labels, which are never empty.
+type trieNode struct {
+	label    string
+	terminal bool
+	children []*trieNode
+}
+
+// child returns the index of the child whose label begins with the input byte
+// and true if such a child exists; otherwise, returns the insert position.
+func (n *trieNode) child(b byte) (int, bool) {
+	return slices.BinarySearchFunc(n.children, b, func(c *trieNode, b byte) int {
+		return int(c.label[0]) - int(b)
+	})
+}
+
+// commonPrefixLen returns the length of the common prefix of the inputs.
+func commonPrefixLen(a, b string) int {
+	n := min(len(a), len(b))
+	for i := 0; i < n; i++ {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+	return n
+}
+
+func (x *prefixIndex) insert(key string) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	n := &x.root
+	for {
+		if key == "" {
+			n.terminal = true
+			return
+		}
+		i, ok := n.child(key[0])
+		if !ok {
+			leaf := &trieNode{label: key, terminal: true}
+			n.children = slices.Insert(n.children, i, leaf)
+			return
+		}
+		c := n.children[i]
+		l := commonPrefixLen(c.label, key)
+		if l == len(c.label) {
+			n, key = c, key[l:]
+			continue
+		}
+		// Split the edge at the common prefix.
+		mid := &trieNode{label: c.label[:l], children: []*trieNode{c}}
+		c.label = c.label[l:]
+		n.children[i] = mid
+		n, key = mid, key[l:]
+	}
+}
+
+func (x *prefixIndex) remove(key string) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	x.root.remove(key)
+}
+
+// remove clears the terminal flag for the key under the node and prunes or
+// merges the nodes that are no longer necessary.
+func (n *trieNode) remove(key string) {
+	if key == "" {
+		n.terminal = false
+		return
+	}
+	i, ok := n.child(key[0])
+	if !ok {
+		return
+	}
+	c := n.children[i]
+	if !strings.HasPrefix(key, c.label) {
+		return
+	}
+	c.remove(key[len(c.label):])
+
+	switch {
+	case c.terminal:
+	case len(c.children) == 0:
+		n.children = slices.Delete(n.children, i, i+1)
+	case len(c.children) == 1:
+		gc := c.children[0]
+		gc.label = c.label + gc.label
+		n.children[i] = gc
+	}
+}
+
+// keys returns all keys with the input prefix in ascending order. Keys are
+// collected under the read lock, so that the callers can update the database
+// while ranging over them.
+func (x *prefixIndex) keys(prefix string) []string {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	n, path := &x.root, ""
+	for rest := prefix; rest != ""; {
+		i, ok := n.child(rest[0])
+		if !ok {
+			return nil
+		}
+		c := n.children[i]
+		l := commonPrefixLen(c.label, rest)
+		if l < len(rest) && l < len(c.label) {
+			return nil
+		}
+		n, path, rest = c, path+c.label, rest[l:]
+	}
+
+	var keys []string
+	var walk func(n *trieNode, path string)
+	walk = func(n *trieNode, path string) {
+		if n.terminal {
+			keys = append(keys, path)
+		}
+		for _, c := range n.children {
+			walk(c, path+c.label)
+		}
+	}
+	walk(n, path)
+	return keys
+}
+
+// rangePrefix returns the longest prefix shared by all keys in the [begin,
+// end) range.
+func rangePrefix(begin, end string) string {
+	if begin == "" || end == "" {
+		return ""
+	}
+	return begin[:commonPrefixLen(begin, end)]
+}
+
+// prefixEnd returns the smallest key that is larger than all keys with the
+// input prefix. Returns the empty key, which stands for the unbounded end, if
+// no such key exists.
+func prefixEnd(prefix string) string {
+	for i := len(prefix) - 1; i >= 0; i-- {
+		if prefix[i] != 0xff {
+			return prefix[:i] + string([]byte{prefix[i] + 1})
+		}
+	}
+	return ""
+}
+
+// prefixKeys is similar to rangeKeys, but only yields the keys with the input
+// prefix, using the prefix index when it is enabled.
+func (d *Database) prefixKeys(prefix string) iter.Seq[string] {
+	return func(yield func(string) bool) {
+		if d.prefixIndex != nil {
+			for _, key := range d.prefixIndex.keys(prefix) {
+				if !yield(key) {
+					return
+				}
+			}
+		} else {
+			for key := range d.kvs.Range {
+				if strings.HasPrefix(key, prefix) && !yield(key) {
+					return
+				}
+			}
+		}
+		if d.base == nil {
+			return
+		}
+		for key := range d.base.prefixKeys(prefix) {
+			if _, ok := d.kvs.Load(key); ok {
+				continue
+			}
+			if !yield(key) {
+				return
+			}
+		}
+	}
+}
+
+// indexKey adds a new key to the prefix index, if it is enabled, before the
+// key is stored in the key-value map.
+func (d *Database) indexKey(key string) {
+	if d.prefixIndex != nil {
+		d.prefixIndex.insert(key)
+	}
+}
+
+// unindexKey removes a key from the prefix index, if it is enabled, after the
+// key is deleted from the key-value map.
+func (d *Database) unindexKey(key string) {
+	if d.prefixIndex != nil {
+		d.prefixIndex.remove(key)
+	}
+}
@@ -0,0 +1,163 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestPrefixIndexTree(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	alphabet := []byte{'a', 'b', 0xff}
+	randKey := func() string {
+		b := make([]byte, r.Intn(5))
+		for i := range b {
+			b[i] = alphabet[r.Intn(len(alphabet))]
+		}
+		return string(b)
+	}
+
+	var x prefixIndex
+	want := make(map[string]bool)
+	for i := 0; i < 5000; i++ {
+		key := randKey()
+		if r.Intn(3) == 0 {
+			x.remove(key)
+			delete(want, key)
+		} else {
+			x.insert(key)
+			want[key] = true
+		}
+
+		prefix := randKey()
+		var wkeys []string
+		for k := range want {
+			if strings.HasPrefix(k, prefix) {
+				wkeys = append(wkeys, k)
+			}
+		}
+		slices.Sort(wkeys)
+		if got := x.keys(prefix); !slices.Equal(got, wkeys) {
+			t.Fatalf("step %d: prefix %q: want %q, got %q", i, prefix, wkeys, got)
+		}
+	}
+}
+
+func TestPrefixEnd(t *testing.T) {
+	for prefix, want := range map[string]string{
+		"":          "",
+		"a":         "b",
+		"a/":        "a0",
+		"a\xff":     "b",
+		"\xff\xff":  "",
+		"ab\xffcd":  "ab\xffce",
+		"a\x7f\xff": "a\x80",
+	} {
+		if got := prefixEnd(prefix); got != want {
+			t.Errorf("prefixEnd(%q): want %q, got %q", prefix, want, got)
+		}
+	}
+}
+
+func TestScanPrefix(t *testing.T) {
+	ctx := context.Background()
+
+	for _, bench := range []struct {
+		name string
+		opts []Option
+	}{
+		{"default", nil},
+		{"index", []Option{WithPrefixIndex()}},
+	} {
+		t.Run(bench.name, func(t *testing.T) {
+			db := New(bench.opts...)
+			for _, k := range []string{"a", "a/1", "a/2", "a0", "ab", "b/1"} {
+				mustSet(ctx, t, db, k, k)
+			}
+
+			// Deleted keys are retained in the index until they are compacted.
+			tx, _ := db.NewTransaction(ctx)
+			tx.Delete(ctx, "a/2")
+			if err := tx.Commit(ctx); err != nil {
+				t.Fatal(err)
+			}
+
+			tx, _ = db.NewTransaction(ctx)
+			defer tx.Rollback(ctx)
+			tx.Set(ctx, "a/3", strings.NewReader("a/3"))
+
+			snap, _ := db.NewSnapshot(ctx)
+			defer snap.Discard(ctx)
+
+			var err error
+			var got []string
+			for k := range snap.ScanPrefix(ctx, "a/", &err) {
+				got = append(got, k)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := []string{"a/1"}; !slices.Equal(got, want) {
+				t.Errorf("snapshot: want %q, got %q", want, got)
+			}
+
+			got = nil
+			for k := range tx.ScanPrefix(ctx, "a", &err) {
+				got = append(got, k)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := []string{"a", "a/1", "a/3", "a0", "ab"}; !slices.Equal(got, want) {
+				t.Errorf("transaction: want %q, got %q", want, got)
+			}
+		})
+	}
+}
+
+func BenchmarkScanPrefix(b *testing.B) {
+	if testing.Short() {
+		b.Skip("skipping the 1M key database in short mode")
+	}
+	ctx := context.Background()
+
+	const nkeys = 1000000
+	m := make(map[string][]byte, nkeys)
+	for i := 0; i < nkeys; i++ {
+		m[fmt.Sprintf("key/%04d/%04d", i/1000, i%1000)] = []byte("value")
+	}
+
+	for _, bench := range []struct {
+		name string
+		opts []Option
+	}{
+		{"default", nil},
+		{"index", []Option{WithPrefixIndex()}},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			db, err := FromMap(ctx, m, bench.opts...)
+			if err != nil {
+				b.Fatal(err)
+			}
+			snap, _ := db.NewSnapshot(ctx)
+			defer snap.Discard(ctx)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				prefix := fmt.Sprintf("key/%04d/", i%(nkeys/1000))
+				n := 0
+				for range snap.ScanPrefix(ctx, prefix, &err) {
+					n++
+				}
+				if err != nil || n != 1000 {
+					b.Fatalf("want 1000 keys, got %d: %v", n, err)
+				}
+			}
+		})
+	}
+}
@@ -5,9 +5,13 @@ package kvmemdb
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
+	"iter"
 	"os"
 	"reflect"
+	"slices"
+	"strconv"
 	"strings"
 	"testing"
 
@@ -211,3 +215,202 @@ func TestAscendDescend(t *testing.T) {
 		})
 	}
 }
+
+func TestAscendFilter(t *testing.T) {
+	ctx := context.Background()
+
+	mdb := New()
+	tx, _ := mdb.NewTransaction(ctx)
+	for i := 0; i < 10; i++ {
+		tx.Set(ctx, fmt.Sprintf("key%d", i), strings.NewReader(strconv.Itoa(i)))
+	}
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	even := func(key string, value []byte) bool {
+		n, _ := strconv.Atoi(string(value))
+		return n%2 == 0
+	}
+	want := []string{"key2", "key4", "key6"}
+
+	snap, _ := mdb.NewSnapshot(ctx)
+	defer snap.Discard(ctx)
+
+	var snapKeys []string
+	var snapErr error
+	for k := range snap.AscendFilter(ctx, "key1", "key7", even, &snapErr) {
+		snapKeys = append(snapKeys, k)
+	}
+	if snapErr != nil {
+		t.Fatal(snapErr)
+	}
+	if !reflect.DeepEqual(snapKeys, want) {
+		t.Errorf("Snapshot.AscendFilter keys = %v, want %v", snapKeys, want)
+	}
+
+	tx, _ = mdb.NewTransaction(ctx)
+	defer tx.Rollback(ctx)
+
+	var txKeys []string
+	var txErr error
+	for k := range tx.AscendFilter(ctx, "key1", "key7", even, &txErr) {
+		txKeys = append(txKeys, k)
+	}
+	if txErr != nil {
+		t.Fatal(txErr)
+	}
+	if !reflect.DeepEqual(txKeys, want) {
+		t.Errorf("Transaction.AscendFilter keys = %v, want %v", txKeys, want)
+	}
+
+	// All visited keys, including the rejected ones, are read-tracked.
+	for i := 1; i < 7; i++ {
+		if _, ok := tx.reads[fmt.Sprintf("key%d", i)]; !ok {
+			t.Errorf("visited key%d is not recorded in the read set", i)
+		}
+	}
+}
+
+func TestRestartableIterators(t *testing.T) {
+	ctx := context.Background()
+
+	mdb := New()
+	for i := 0; i < 5; i++ {
+		mustSet(ctx, t, mdb, fmt.Sprintf("key%d", i), strconv.Itoa(i))
+	}
+
+	snap, _ := mdb.NewSnapshot(ctx)
+	defer snap.Discard(ctx)
+	tx, _ := mdb.NewTransaction(ctx)
+	defer tx.Rollback(ctx)
+
+	keep := func(string, []byte) bool { return true }
+	want := []string{"key0", "key1", "key2", "key3", "key4"}
+
+	var err error
+	for _, r := range []kv.Reader{snap, tx} {
+		seqs := map[string]iter.Seq2[string, io.Reader]{
+			"Scan":    r.Scan(ctx, &err),
+			"Ascend":  r.Ascend(ctx, "", "", &err),
+			"Descend": r.Descend(ctx, "", "", &err),
+		}
+		switch v := r.(type) {
+		case *Snapshot:
+			seqs["AscendFilter"] = v.AscendFilter(ctx, "", "", keep, &err)
+		case *Transaction:
+			seqs["AscendFilter"] = v.AscendFilter(ctx, "", "", keep, &err)
+		}
+
+		for name, seq := range seqs {
+			// Stop the first range early.
+			for range seq {
+				break
+			}
+
+			// A stale error must not survive into the next range.
+			err = errors.New("stale error")
+
+			var keys []string
+			for k := range seq {
+				keys = append(keys, k)
+			}
+			if err != nil {
+				t.Errorf("%T.%s: second range returned error %v", r, name, err)
+			}
+			slices.Sort(keys)
+			if !reflect.DeepEqual(keys, want) {
+				t.Errorf("%T.%s: second range keys = %v, want %v", r, name, keys, want)
+			}
+		}
+	}
+}
+
+func TestInvalidRangeError(t *testing.T) {
+	ctx := context.Background()
+
+	mdb := New()
+	mustSet(ctx, t, mdb, "key", "value")
+
+	snap, _ := mdb.NewSnapshot(ctx)
+	defer snap.Discard(ctx)
+	tx, _ := mdb.NewTransaction(ctx)
+	defer tx.Rollback(ctx)
+
+	const begin, end = "z", "a"
+	keep := func(string, []byte) bool { return true }
+	scans := map[string]func(errp *error) iter.Seq2[string, io.Reader]{
+		"Snapshot.Ascend":       func(errp *error) iter.Seq2[string, io.Reader] { return snap.Ascend(ctx, begin, end, errp) },
+		"Snapshot.Descend":      func(errp *error) iter.Seq2[string, io.Reader] { return snap.Descend(ctx, begin, end, errp) },
+		"Snapshot.AscendFilter": func(errp *error) iter.Seq2[string, io.Reader] { return snap.AscendFilter(ctx, begin, end, keep, errp) },
+		"Transaction.Ascend":    func(errp *error) iter.Seq2[string, io.Reader] { return tx.Ascend(ctx, begin, end, errp) },
+		"Transaction.Descend":   func(errp *error) iter.Seq2[string, io.Reader] { return tx.Descend(ctx, begin, end, errp) },
+		"Transaction.AscendFilter": func(errp *error) iter.Seq2[string, io.Reader] {
+			return tx.AscendFilter(ctx, begin, end, keep, errp)
+		},
+	}
+
+	check := func(name string, err error) {
+		var rerr *ErrInvalidRange
+		if !errors.As(err, &rerr) {
+			t.Errorf("%s error = %v, want *ErrInvalidRange", name, err)
+			return
+		}
+		if rerr.Begin != begin || rerr.End != end {
+			t.Errorf("%s error range = [%q, %q), want [%q, %q)", name, rerr.Begin, rerr.End, begin, end)
+		}
+		if !errors.Is(err, os.ErrInvalid) {
+			t.Errorf("%s error %v does not wrap os.ErrInvalid", name, err)
+		}
+	}
+	for name, scan := range scans {
+		var err error
+		for range scan(&err) {
+			t.Errorf("%s yielded a key for an invalid range", name)
+		}
+		check(name, err)
+	}
+	_, err := tx.CountPhantomSafe(ctx, begin, end)
+	check("Transaction.CountPhantomSafe", err)
+}
+
+// TestScanOrderIsUndefined checks that callers cannot depend on the order of
+// the keys from Scan, which must use Ascend or Descend for a defined order.
+func TestScanOrderIsUndefined(t *testing.T) {
+	ctx := context.Background()
+
+	db := New()
+	tx, _ := db.NewTransaction(ctx)
+	for i := 0; i < 50; i++ {
+		tx.Set(ctx, fmt.Sprintf("key%02d", i), strings.NewReader("value"))
+	}
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	snap, _ := db.NewSnapshot(ctx)
+	defer snap.Discard(ctx)
+	tx, _ = db.NewTransaction(ctx)
+	defer tx.Rollback(ctx)
+
+	for _, r := range []kv.Reader{snap, tx} {
+		orders := make(map[string]bool)
+		for i := 0; i < 100; i++ {
+			var keys []string
+			var err error
+			for k := range r.Scan(ctx, &err) {
+				keys = append(keys, k)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(keys) != 50 {
+				t.Fatalf("%T: Scan yielded %d keys, want 50", r, len(keys))
+			}
+			orders[strings.Join(keys, ",")] = true
+		}
+		if len(orders) < 2 {
+			t.Errorf("%T: Scan yielded the keys in the same order 100 times", r)
+		}
+	}
+}
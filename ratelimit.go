@@ -0,0 +1,119 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// RateLimitPolicy selects the behavior of the commits exceeding the write
+// rate limits.
+type RateLimitPolicy int
+
+const (
+	// RateLimitWait delays the commits until the limiters allow their writes,
+	// or fails them with an *ErrRateLimited error if the context is done
+	// before that. This is the default.
+	RateLimitWait RateLimitPolicy = iota
+
+	// RateLimitFail fails the commits with an *ErrRateLimited error when the
+	// limiters do not allow their writes immediately.
+	RateLimitFail
+)
+
+// ErrRateLimited is the error returned by the commits whose writes exceed the
+// write rate limit of a key prefix.
+type ErrRateLimited struct {
+	// Prefix is the rate limited key prefix.
+	Prefix string
+
+	// Writes is the number of writes of the transaction under the prefix.
+	Writes int
+
+	// Err is the context error, if the commit gave up waiting for the
+	// limiter, or nil.
+	Err error
+}
+
+func (e *ErrRateLimited) Error() string {
+	msg := fmt.Sprintf("%d writes to prefix %q exceed the write rate limit", e.Writes, e.Prefix)
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *ErrRateLimited) Unwrap() error {
+	return e.Err
+}
+
+// writeLimit is a rate limiter for the writes to the keys with a prefix.
+type writeLimit struct {
+	prefix  string
+	limiter *rate.Limiter
+}
+
+// limitWrites waits for, or reserves, the tokens for the transaction's writes
+// under every rate limited prefix, according to the rate limit policy. Tokens
+// are reserved from all limiters together, so a commit that is rate limited
+// by any prefix doesn't consume the tokens of the others. It must be called
+// before any locks are taken.
+func (d *Database) limitWrites(ctx context.Context, tx *Transaction) error {
+	if len(d.writeLimits) == 0 || len(tx.writes) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	var delay time.Duration
+	var slowest *ErrRateLimited
+	var reservations []*rate.Reservation
+	cancel := func() {
+		for _, r := range reservations {
+			r.CancelAt(now)
+		}
+	}
+	for _, wl := range d.writeLimits {
+		n := 0
+		for key := range tx.writes {
+			if strings.HasPrefix(key, wl.prefix) {
+				n++
+			}
+		}
+		if n == 0 {
+			continue
+		}
+		r := wl.limiter.ReserveN(now, n)
+		if !r.OK() {
+			cancel()
+			return &ErrRateLimited{Prefix: wl.prefix, Writes: n}
+		}
+		reservations = append(reservations, r)
+		if wait := r.DelayFrom(now); wait > delay {
+			delay = wait
+			slowest = &ErrRateLimited{Prefix: wl.prefix, Writes: n}
+		}
+	}
+	if delay == 0 {
+		return nil
+	}
+	if d.rateLimitPolicy == RateLimitFail {
+		cancel()
+		return slowest
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		cancel()
+		slowest.Err = ctx.Err()
+		return slowest
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+func TestWriteRateLimit(t *testing.T) {
+	ctx := context.Background()
+
+	const interval = 50 * time.Millisecond
+	newDB := func(policy RateLimitPolicy) *Database {
+		return New(
+			WithWriteRateLimit("tenant1/", rate.NewLimiter(rate.Every(interval), 2)),
+			WithRateLimitPolicy(policy))
+	}
+	commit := func(ctx context.Context, db *Database, keys ...string) error {
+		tx, _ := db.NewTransaction(ctx)
+		defer tx.Rollback(ctx)
+		for _, k := range keys {
+			tx.Set(ctx, k, strings.NewReader("value"))
+		}
+		return tx.Commit(ctx)
+	}
+
+	t.Run("fail", func(t *testing.T) {
+		db := newDB(RateLimitFail)
+		if err := commit(ctx, db, "tenant1/a", "tenant1/b", "tenant2/a"); err != nil {
+			t.Fatal(err)
+		}
+		var rerr *ErrRateLimited
+		if err := commit(ctx, db, "tenant1/c"); !errors.As(err, &rerr) || rerr.Prefix != "tenant1/" || rerr.Writes != 1 {
+			t.Errorf("commit over the limit: want ErrRateLimited, got %v", err)
+		}
+		// Other prefixes are not limited.
+		for i := 0; i < 10; i++ {
+			if err := commit(ctx, db, fmt.Sprintf("tenant2/%d", i)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		// Commits larger than the burst never succeed.
+		if err := commit(ctx, db, "tenant1/x", "tenant1/y", "tenant1/z"); !errors.As(err, &rerr) || rerr.Writes != 3 {
+			t.Errorf("commit over the burst: want ErrRateLimited, got %v", err)
+		}
+	})
+
+	t.Run("wait", func(t *testing.T) {
+		db := newDB(RateLimitWait)
+		start := time.Now()
+		for i := 0; i < 4; i++ {
+			if err := commit(ctx, db, fmt.Sprintf("tenant1/%d", i)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if elapsed := time.Since(start); elapsed < 2*interval-interval/5 {
+			t.Errorf("4 writes with a burst of 2: want at least %v, took %v", 2*interval, elapsed)
+		}
+
+		tctx, cancel := context.WithTimeout(ctx, interval/10)
+		defer cancel()
+		var rerr *ErrRateLimited
+		err := commit(tctx, db, "tenant1/late")
+		if !errors.As(err, &rerr) || !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("commit past the deadline: want ErrRateLimited with the context error, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,91 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+)
+
+// registry holds the databases registered by name for the process.
+var registry struct {
+	mu  sync.RWMutex
+	dbs map[string]*Database
+}
+
+// Register adds the database to the process-wide registry under the input
+// name. Returns an error wrapping os.ErrExist if the name is already
+// registered and os.ErrInvalid if the name is empty or the database is nil.
+func Register(name string, db *Database) error {
+	if name == "" || db == nil {
+		return fmt.Errorf("database name and the database cannot be empty: %w", os.ErrInvalid)
+	}
+
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	if _, ok := registry.dbs[name]; ok {
+		return fmt.Errorf("database %q is already registered: %w", name, os.ErrExist)
+	}
+	if registry.dbs == nil {
+		registry.dbs = make(map[string]*Database)
+	}
+	registry.dbs[name] = db
+	return nil
+}
+
+// Lookup returns the database registered under the input name.
+func Lookup(name string) (*Database, bool) {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	db, ok := registry.dbs[name]
+	return db, ok
+}
+
+// Unregister removes the database registered under the input name, if any.
+func Unregister(name string) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	delete(registry.dbs, name)
+}
+
+// Names returns the names of all registered databases in ascending order.
+func Names() []string {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	return slices.Sorted(maps.Keys(registry.dbs))
+}
+
+// RegistryHandler returns an HTTP handler that serves the debug handlers of
+// all registered databases under the /db/{name}/ paths, e.g., the stats of a
+// database at /db/{name}/stats, and the names of the registered databases as
+// a JSON array at the /db/ path. Databases are looked up for every request,
+// so the databases registered later are also served.
+func RegistryHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /db/{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(Names()); err != nil {
+			slog.Warn("kvmemdb: could not write database names", "err", err)
+		}
+	})
+	mux.HandleFunc("/db/{name}/{path...}", func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+		db, ok := Lookup(name)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		http.StripPrefix("/db/"+name, db.DebugHandler()).ServeHTTP(w, r)
+	})
+	return mux
+}
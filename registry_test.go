@@ -0,0 +1,107 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	const n = 20
+	name := func(i int) string { return fmt.Sprintf("registry-test-%02d", i) }
+
+	dbs := make([]*Database, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		dbs[i] = New()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := Register(name(i), dbs[i]); err != nil {
+				t.Error(err)
+			}
+			if db, ok := Lookup(name(i)); !ok || db != dbs[i] {
+				t.Errorf("Lookup(%s) = %p, %v, want the registered database", name(i), db, ok)
+			}
+			if i%2 == 1 {
+				Unregister(name(i))
+			}
+		}()
+	}
+	wg.Wait()
+	defer func() {
+		for i := 0; i < n; i++ {
+			Unregister(name(i))
+		}
+	}()
+
+	var want []string
+	for i := 0; i < n; i += 2 {
+		want = append(want, name(i))
+	}
+	// Other tests may register databases concurrently.
+	got := slices.DeleteFunc(Names(), func(s string) bool { return !strings.HasPrefix(s, "registry-test-") })
+	if !slices.Equal(got, want) {
+		t.Errorf("Names() = %q, want %q", got, want)
+	}
+	if _, ok := Lookup(name(1)); ok {
+		t.Errorf("unregistered database is found")
+	}
+	if err := Register(name(0), New()); !errors.Is(err, os.ErrExist) {
+		t.Errorf("duplicate Register = %v, want os.ErrExist", err)
+	}
+	if err := Register("", New()); !errors.Is(err, os.ErrInvalid) {
+		t.Errorf("Register with an empty name = %v, want os.ErrInvalid", err)
+	}
+
+	srv := httptest.NewServer(RegistryHandler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/db/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	err = json.NewDecoder(resp.Body).Decode(&names)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(names, name(0)) {
+		t.Errorf("/db/ = %q, want the registered databases", names)
+	}
+
+	for path, status := range map[string]int{
+		"/db/" + name(0) + "/stats": http.StatusOK,
+		"/db/" + name(1) + "/stats": http.StatusNotFound,
+		"/db/" + name(0) + "/other": http.StatusNotFound,
+	} {
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != status {
+			t.Errorf("GET %s = %d, want %d", path, resp.StatusCode, status)
+		}
+	}
+
+	resp, err = http.Get(srv.URL + "/db/" + name(2) + "/stats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var stats debugStats
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		t.Errorf("/db/%s/stats is not the database stats: %v", name(2), err)
+	}
+}
@@ -0,0 +1,172 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sync"
+
+	"github.com/visvasity/syncmap"
+)
+
+// reverseIndex maps the SHA-256 hashes of the values to the sorted lists of
+// keys that may hold them. Lists are replaced, never modified, so they can be
+// read without the lock.
+//
+// Keys are added to the bucket of their new value before the value is stored
+// in the key-value map. They are removed from the buckets of their older
+// values only after a newer value is visible at the maxCommitVersion, so a
+// key holding the same value across a lookup is always found. Buckets can
+// hold stale keys briefly, so lookups verify the values.
+type reverseIndex struct {
+	buckets syncmap.Map[string, []string]
+
+	// mu serializes the updates to the buckets and the hashes map, which
+	// holds the hashes of the buckets that list each key.
+	mu     sync.Mutex
+	hashes map[string][]string
+}
+
+func newReverseIndex() *reverseIndex {
+	return &reverseIndex{hashes: make(map[string][]string)}
+}
+
+func valueHash(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return string(sum[:])
+}
+
+func (x *reverseIndex) add(key, hash string) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	if !slices.Contains(x.hashes[key], hash) {
+		x.hashes[key] = append(x.hashes[key], hash)
+	}
+	keys, _ := x.buckets.Load(hash)
+	if i, ok := slices.BinarySearch(keys, key); !ok {
+		x.buckets.Store(hash, slices.Insert(slices.Clone(keys), i, key))
+	}
+}
+
+// retain removes the key from the buckets for which keep returns false.
+func (x *reverseIndex) retain(key string, keep func(hash string) bool) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	hashes := x.hashes[key]
+	for _, hash := range hashes {
+		if keep(hash) {
+			continue
+		}
+		keys, _ := x.buckets.Load(hash)
+		if i, ok := slices.BinarySearch(keys, key); ok {
+			if len(keys) == 1 {
+				x.buckets.Delete(hash)
+			} else {
+				x.buckets.Store(hash, slices.Delete(slices.Clone(keys), i, i+1))
+			}
+		}
+	}
+	if hashes = slices.DeleteFunc(hashes, func(h string) bool { return !keep(h) }); len(hashes) == 0 {
+		delete(x.hashes, key)
+	} else {
+		x.hashes[key] = hashes
+	}
+}
+
+// indexValue adds the key to the reverse index bucket of its new value, if the
+// reverse index is enabled, before the value is stored in the key-value map.
+func (d *Database) indexValue(key string, value *string) {
+	if d.reverseIndex != nil && value != nil {
+		d.reverseIndex.add(key, valueHash(*value))
+	}
+}
+
+// pruneValues removes the keys updated by the transaction from the reverse
+// index buckets of the values that are no longer visible at the
+// maxCommitVersion. It must be called after the transaction is published.
+func (d *Database) pruneValues(tx *Transaction) {
+	if d.reverseIndex == nil {
+		return
+	}
+	for key := range tx.writes {
+		// Values of the key visible at or after the maxCommitVersion are
+		// retained in the index.
+		live := make(map[string]bool)
+		if mv, ok := d.kvs.Load(key); ok {
+			visible, _ := mv.Fetch(d.maxCommitVersion.Load())
+			for _, v := range mv.Values() {
+				if visible != nil && v.Version() < visible.Version() {
+					continue
+				}
+				if !v.IsDeleted() {
+					live[valueHash(v.Data())] = true
+				}
+			}
+		}
+		d.reverseIndex.retain(key, func(hash string) bool { return live[hash] })
+	}
+}
+
+// rebuildReverseIndex adds all keys to the reverse index buckets of their
+// latest values, for databases created without transactions.
+func (d *Database) rebuildReverseIndex() {
+	if d.reverseIndex == nil {
+		return
+	}
+	for key, mv := range d.kvs.Range {
+		if v, ok := mv.Fetch(math.MaxInt64); ok && !v.IsDeleted() {
+			d.reverseIndex.add(key, valueHash(v.Data()))
+		}
+	}
+}
+
+// valueKeys returns the candidate keys for the value hash, including the keys
+// from the base database when it also has a reverse index.
+func (d *Database) valueKeys(hash string) []string {
+	keys, _ := d.reverseIndex.buckets.Load(hash)
+	if d.base != nil && d.base.reverseIndex != nil {
+		keys = append(slices.Clone(keys), d.base.valueKeys(hash)...)
+	}
+	return keys
+}
+
+// FindByValue returns the keys holding the input value at the
+// maxCommitVersion, in ascending order. Candidate keys are looked up in the
+// reverse index and verified against a new snapshot, so keys updated by the
+// concurrent commits may or may not be included.
+//
+// Returns an error wrapping errors.ErrUnsupported if the reverse index is not
+// enabled with WithReverseIndex. Keys in the base of an overlay database are
+// only found if the base also has a reverse index.
+func (d *Database) FindByValue(ctx context.Context, value []byte) ([]string, error) {
+	if d.reverseIndex == nil {
+		return nil, fmt.Errorf("reverse index is not enabled: %w", errors.ErrUnsupported)
+	}
+
+	candidates := d.valueKeys(valueHash(string(value)))
+	snap, err := d.NewSnapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer snap.Discard(ctx)
+
+	var keys []string
+	for _, key := range candidates {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		// Hash collisions and stale keys are filtered by comparing the values.
+		if v := snap.fetch(key); v != nil && !v.IsDeleted() && v.Data() == string(value) {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	return slices.Compact(keys), nil
+}
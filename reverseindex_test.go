@@ -0,0 +1,81 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestFindByValue(t *testing.T) {
+	ctx := context.Background()
+
+	db := New(WithReverseIndex())
+
+	const nkeys, nvalues = 100, 5
+	key := func(i int) string { return fmt.Sprintf("key%03d", i) }
+	value := func(i int) string { return fmt.Sprintf("value%d", i%nvalues) }
+
+	tx, _ := db.NewTransaction(ctx)
+	for i := 0; i < nkeys; i++ {
+		tx.Set(ctx, key(i), strings.NewReader(value(i)))
+	}
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(want map[string][]string) {
+		t.Helper()
+		for v, wkeys := range want {
+			got, err := db.FindByValue(ctx, []byte(v))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, wkeys) {
+				t.Errorf("FindByValue(%q) = %q, want %q", v, got, wkeys)
+			}
+		}
+	}
+
+	want := make(map[string][]string)
+	for i := 0; i < nkeys; i++ {
+		want[value(i)] = append(want[value(i)], key(i))
+	}
+	want["missing"] = nil
+	check(want)
+
+	// Updated keys move to the bucket of their new value and deleted keys are
+	// removed.
+	tx, _ = db.NewTransaction(ctx)
+	tx.Set(ctx, key(0), strings.NewReader(value(1)))
+	tx.Delete(ctx, key(5))
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	want[value(0)] = slices.DeleteFunc(want[value(0)], func(k string) bool { return k == key(0) || k == key(5) })
+	want[value(1)] = append([]string{key(0)}, want[value(1)]...)
+	check(want)
+
+	// Stale keys are pruned from the buckets once the older values are not
+	// visible.
+	if keys, _ := db.reverseIndex.buckets.Load(valueHash(value(0))); len(keys) != len(want[value(0)]) {
+		t.Errorf("bucket for %q holds %d keys, want %d", value(0), len(keys), len(want[value(0)]))
+	}
+
+	// Databases created from a map are indexed too.
+	mdb, err := FromMap(ctx, map[string][]byte{"a": []byte("x"), "b": []byte("y"), "c": []byte("x")}, WithReverseIndex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := mdb.FindByValue(ctx, []byte("x")); !slices.Equal(got, []string{"a", "c"}) {
+		t.Errorf("FindByValue(x) = %q, want [a c]", got)
+	}
+
+	if _, err := New().FindByValue(ctx, []byte("x")); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("FindByValue without the index = %v, want errors.ErrUnsupported", err)
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+)
+
+// NextSequence allocates the next number from the sequence stored in the
+// counter key, starting from one. Numbers are allocated from the database
+// immediately, so concurrent transactions obtain distinct numbers without
+// conflicting on the counter key. Counter key is updated to the largest
+// allocated number when the transaction commits, following the normal commit
+// rules.
+//
+// Numbers allocated by the transactions that are rolled back or fail to commit
+// are not reused, which leaves gaps in the sequence. Counter key must hold a
+// decimal number and must only be updated through NextSequence; other updates
+// to the key are overwritten at commit time. Reading the counter key with the
+// other methods is subject to the usual conflict checks.
+//
+// Database keeps the last allocated number of every counter key in memory
+// for its whole lifetime, including after the counter key is deleted, so
+// that numbers allocated by the failed transactions are never handed out
+// again. Deleting the counter key doesn't restart the sequence. Counter keys
+// should therefore be a small, fixed set rather than created per entity.
+func (t *Transaction) NextSequence(ctx context.Context, counterKey string) (uint64, error) {
+	if err := t.check(); err != nil {
+		return 0, err
+	}
+	if err := t.db.checkKey(counterKey); err != nil {
+		return 0, err
+	}
+	ctx, cancel := t.db.withDefaultTimeout(ctx)
+	defer cancel()
+
+	if err := t.db.mu.LockContext(ctx); err != nil {
+		return 0, fmt.Errorf("could not lock the database: %w", err)
+	}
+	defer t.db.mu.Unlock()
+
+	last, ok := t.db.sequences[counterKey]
+	if !ok {
+		// Sequence begins from the committed value of the counter key.
+		if v := t.db.fetch(counterKey, math.MaxInt64); v != nil && !v.IsDeleted() {
+			n, err := strconv.ParseUint(v.Data(), 10, 64)
+			if err != nil {
+				return 0, fmt.Errorf("counter key %s holds a non-numeric value: %w", counterKey, os.ErrInvalid)
+			}
+			last = n
+		}
+	}
+	if last == math.MaxUint64 {
+		return 0, fmt.Errorf("sequence in counter key %s is exhausted: %w", counterKey, os.ErrInvalid)
+	}
+	last++
+	t.db.sequences[counterKey] = last
+
+	if t.sequences == nil {
+		t.sequences = make(map[string]struct{})
+	}
+	t.sequences[counterKey] = struct{}{}
+	s := strconv.FormatUint(last, 10)
+	t.stage(counterKey, &s)
+	return last, nil
+}
@@ -0,0 +1,104 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestNextSequence(t *testing.T) {
+	ctx := context.Background()
+
+	db := New()
+
+	const ntxes = 50
+	var wg sync.WaitGroup
+	seqs := make(chan uint64, ntxes)
+	for i := 0; i < ntxes; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			tx, err := db.NewTransaction(ctx)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer tx.Rollback(ctx)
+
+			n, err := tx.NextSequence(ctx, "orders/counter")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			tx.Set(ctx, fmt.Sprintf("orders/%06d", n), strings.NewReader("order"))
+			if err := tx.Commit(ctx); err != nil {
+				t.Errorf("sequence allocation must not conflict: %v", err)
+				return
+			}
+			seqs <- n
+		}()
+	}
+	wg.Wait()
+	close(seqs)
+
+	seen := make(map[uint64]bool)
+	for n := range seqs {
+		if seen[n] {
+			t.Errorf("sequence number %d is allocated twice", n)
+		}
+		seen[n] = true
+	}
+	if len(seen) != ntxes {
+		t.Fatalf("want %d sequence numbers, got %d", ntxes, len(seen))
+	}
+	snap, _ := db.NewSnapshot(ctx)
+	defer snap.Discard(ctx)
+	if v := mustGet(ctx, t, snap, "orders/counter"); v != fmt.Sprint(ntxes) {
+		t.Errorf("want counter %d, got %s", ntxes, v)
+	}
+
+	// Numbers allocated by rolled back transactions are not reused.
+	tx, _ := db.NewTransaction(ctx)
+	if n, _ := tx.NextSequence(ctx, "orders/counter"); n != ntxes+1 {
+		t.Errorf("want sequence %d, got %d", ntxes+1, n)
+	}
+	tx.Rollback(ctx)
+
+	tx, _ = db.NewTransaction(ctx)
+	defer tx.Rollback(ctx)
+	if n, _ := tx.NextSequence(ctx, "orders/counter"); n != ntxes+2 {
+		t.Errorf("want sequence %d after a rollback, got %d", ntxes+2, n)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Deleting the counter key doesn't restart the sequence.
+	tx, _ = db.NewTransaction(ctx)
+	tx.Delete(ctx, "orders/counter")
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	tx, _ = db.NewTransaction(ctx)
+	defer tx.Rollback(ctx)
+	if n, _ := tx.NextSequence(ctx, "orders/counter"); n != ntxes+3 {
+		t.Errorf("want sequence %d after deleting the counter, got %d", ntxes+3, n)
+	}
+	tx.Rollback(ctx)
+
+	// Sequences continue from the committed counter in a new database.
+	db2, err := FromMap(ctx, map[string][]byte{"counter": []byte("41")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, _ = db2.NewTransaction(ctx)
+	defer tx.Rollback(ctx)
+	if n, _ := tx.NextSequence(ctx, "counter"); n != 42 {
+		t.Errorf("want sequence 42 from the committed counter, got %d", n)
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Range represents the [Begin, End) range of keys. Empty Begin and End values
+// stand for the smallest and the largest keys respectively.
+type Range struct {
+	Begin, End string
+}
+
+// String returns the range in the "[begin, end)" format.
+func (r Range) String() string {
+	return fmt.Sprintf("[%s, %s)", r.Begin, r.End)
+}
+
+// contains returns true if the key belongs to the range.
+func (r Range) contains(key string) bool {
+	return (r.Begin == "" || key >= r.Begin) && (r.End == "" || key < r.End)
+}
+
+// Shards partitions the keys visible to the snapshot into at most n
+// contiguous, non-overlapping ranges with roughly equal number of keys each.
+// Ranges are returned in the ascending order and cover the whole key space,
+// so the first range has an empty Begin and the last range has an empty End.
+//
+// Snapshots are immutable, so the returned ranges can be scanned concurrently
+// with Ascend or Descend on the same snapshot. Returns a single range if n is
+// less than two or the snapshot has fewer than two keys. Snapshots with fewer
+// keys than n are split into one range per key.
+func (s *Snapshot) Shards(n int) []Range {
+	var keys []string
+	for _, key := range s.keys("", "") {
+		if v := s.fetch(key); v != nil && !v.IsDeleted() {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	n = min(n, len(keys))
+	if n < 2 {
+		return []Range{{}}
+	}
+
+	ranges := make([]Range, n)
+	for i := 1; i < n; i++ {
+		boundary := keys[i*len(keys)/n]
+		ranges[i-1].End = boundary
+		ranges[i].Begin = boundary
+	}
+	return ranges
+}
@@ -0,0 +1,77 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestSnapshotShards(t *testing.T) {
+	ctx := context.Background()
+
+	db := New()
+	tx, _ := db.NewTransaction(ctx)
+	var want []string
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key%03d", i)
+		tx.Set(ctx, key, strings.NewReader("value"))
+		want = append(want, key)
+	}
+	tx.Set(ctx, "deleted", strings.NewReader("value"))
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	tx, _ = db.NewTransaction(ctx)
+	tx.Delete(ctx, "deleted")
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	snap, _ := db.NewSnapshot(ctx)
+	defer snap.Discard(ctx)
+
+	for _, n := range []int{0, 1, 3, 7, 100, 200} {
+		ranges := snap.Shards(n)
+		if wantLen := min(max(n, 1), len(want)); len(ranges) != wantLen {
+			t.Errorf("Shards(%d) returned %d ranges, want %d", n, len(ranges), wantLen)
+		}
+		if ranges[0].Begin != "" || ranges[len(ranges)-1].End != "" {
+			t.Errorf("Shards(%d) = %v does not cover the whole key space", n, ranges)
+		}
+
+		// Scan all shards in parallel.
+		shardKeys := make([][]string, len(ranges))
+		errs := make([]error, len(ranges))
+		var wg sync.WaitGroup
+		for i, r := range ranges {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for k := range snap.Ascend(ctx, r.Begin, r.End, &errs[i]) {
+					shardKeys[i] = append(shardKeys[i], k)
+				}
+			}()
+		}
+		wg.Wait()
+
+		var got []string
+		for i := range ranges {
+			if errs[i] != nil {
+				t.Fatalf("Shards(%d): scan of %v failed: %v", n, ranges[i], errs[i])
+			}
+			if size, ideal := len(shardKeys[i]), len(want)/len(ranges); size < ideal || size > ideal+1 {
+				t.Errorf("Shards(%d): range %v has %d keys, want about %d", n, ranges[i], size, ideal)
+			}
+			got = append(got, shardKeys[i]...)
+		}
+		if !slices.IsSorted(got) || !reflect.DeepEqual(got, want) {
+			t.Errorf("Shards(%d): parallel scan keys = %v, want %v", n, got, want)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"log/slog"
+	"time"
+)
+
+// commitTiming holds the phase boundaries of a commit.
+type commitTiming struct {
+	start     time.Time
+	locked    time.Time
+	validated time.Time
+	applied   time.Time
+
+	// compacted is true if any of the updated keys were compacted.
+	compacted bool
+}
+
+// logSlowCommit logs the commit if its validation and apply phases together
+// took longer than the slow commit threshold.
+func (d *Database) logSlowCommit(tx *Transaction, t *commitTiming) {
+	if t.applied.Sub(t.locked) <= d.slowCommitThreshold {
+		return
+	}
+	d.logger.Warn("kvmemdb: slow commit",
+		slog.Uint64("tx", tx.id),
+		slog.Int("reads", len(tx.reads)),
+		slog.Int("writes", len(tx.writes)),
+		slog.Duration("lock", t.locked.Sub(t.start)),
+		slog.Duration("validate", t.validated.Sub(t.locked)),
+		slog.Duration("apply", t.applied.Sub(t.validated)),
+		slog.Bool("compacted", t.compacted))
+}
+
+// logSlowLock logs the transaction creation if it waited longer than the slow
+// commit threshold to acquire the database lock since start.
+func (d *Database) logSlowLock(start time.Time) {
+	if wait := time.Since(start); wait > d.slowCommitThreshold {
+		d.logger.Warn("kvmemdb: slow transaction creation", slog.Duration("lock", wait))
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordHandler is a slog.Handler that collects all records in memory.
+type recordHandler struct {
+	mu      sync.Mutex
+	records []slog.Record
+}
+
+func (h *recordHandler) Enabled(context.Context, slog.Level) bool { return true }
+func (h *recordHandler) WithAttrs([]slog.Attr) slog.Handler       { return h }
+func (h *recordHandler) WithGroup(string) slog.Handler            { return h }
+
+func (h *recordHandler) Handle(_ context.Context, r slog.Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.records = append(h.records, r.Clone())
+	return nil
+}
+
+// take returns and clears the collected records.
+func (h *recordHandler) take() []slog.Record {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	rs := h.records
+	h.records = nil
+	return rs
+}
+
+func attrsOf(r slog.Record) map[string]slog.Value {
+	m := make(map[string]slog.Value)
+	r.Attrs(func(a slog.Attr) bool {
+		m[a.Key] = a.Value
+		return true
+	})
+	return m
+}
+
+func TestSlowCommitLog(t *testing.T) {
+	ctx := context.Background()
+
+	const threshold = 10 * time.Millisecond
+	h := new(recordHandler)
+	db := New(WithLogger(slog.New(h)), WithSlowCommitThreshold(threshold))
+
+	// Fast commits are not logged.
+	mustSet(ctx, t, db, "key", "v1")
+	mustSet(ctx, t, db, "key", "v2")
+	if rs := h.take(); len(rs) != 0 {
+		t.Fatalf("fast commit is logged: %v", rs)
+	}
+
+	// Slow apply phase with compaction of an existing key.
+	db.hooks = func(point string) {
+		if point == hookCommitApply {
+			time.Sleep(2 * threshold)
+		}
+	}
+	tx, _ := db.NewTransaction(ctx)
+	mustGet(ctx, t, tx, "key")
+	tx.Delete(ctx, "key")
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	db.hooks = nil
+
+	rs := h.take()
+	if len(rs) != 1 || rs[0].Message != "kvmemdb: slow commit" {
+		t.Fatalf("got records %v, want a single slow commit record", rs)
+	}
+	attrs := attrsOf(rs[0])
+	if v := attrs["tx"]; v.Uint64() != tx.id {
+		t.Errorf("tx = %v, want %d", v, tx.id)
+	}
+	if v := attrs["reads"]; v.Int64() != 1 {
+		t.Errorf("reads = %v, want 1", v)
+	}
+	if v := attrs["writes"]; v.Int64() != 1 {
+		t.Errorf("writes = %v, want 1", v)
+	}
+	if v := attrs["apply"]; v.Duration() < 2*threshold {
+		t.Errorf("apply = %v, want at least %v", v, 2*threshold)
+	}
+	if v := attrs["compacted"]; !v.Bool() {
+		t.Errorf("compacted = %v, want true", v)
+	}
+	for _, key := range []string{"lock", "validate"} {
+		if _, ok := attrs[key]; !ok {
+			t.Errorf("slow commit record has no %q attribute", key)
+		}
+	}
+
+	// Update of an existing key whose older version is still visible to a
+	// snapshot is not compacted.
+	mustSet(ctx, t, db, "other", "v1")
+	snap, _ := db.NewSnapshot(ctx)
+	defer snap.Discard(ctx)
+	h.take()
+	db.hooks = func(point string) {
+		if point == hookCommitApply {
+			time.Sleep(2 * threshold)
+		}
+	}
+	mustSet(ctx, t, db, "other", "v2")
+	db.hooks = nil
+	if rs := h.take(); len(rs) != 1 {
+		t.Fatalf("got records %v, want a single slow commit record", rs)
+	} else if v := attrsOf(rs[0])["compacted"]; v.Bool() {
+		t.Errorf("compacted = %v with a live snapshot, want false", v)
+	}
+	snap.Discard(ctx)
+
+	// Transaction creation waiting for a slow validation.
+	p := pauseAt(db, hookCommitValidate)[hookCommitValidate]
+	defer p.resume()
+
+	errc := make(chan error, 1)
+	go func() { errc <- setKey(ctx, db, "key", "v3") }()
+	<-p.reached
+
+	txc := make(chan *Transaction, 1)
+	go func() {
+		tx, _ := db.NewTransaction(ctx)
+		txc <- tx
+	}()
+	time.Sleep(2 * threshold)
+	p.resume()
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	(<-txc).Rollback(ctx)
+
+	var found bool
+	for _, r := range h.take() {
+		if r.Message == "kvmemdb: slow transaction creation" {
+			found = true
+			if v := attrsOf(r)["lock"]; v.Duration() < threshold {
+				t.Errorf("lock = %v, want at least %v", v, threshold)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("slow transaction creation is not logged")
+	}
+}
@@ -5,53 +5,294 @@ package kvmemdb
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"iter"
 	"os"
 	"slices"
 	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/visvasity/kv"
+	"github.com/visvasity/kvmemdb/mvcc"
+)
+
+var _ kv.Snapshot = &Snapshot{}
+
+// Discarder is the interface implemented by both transactions and snapshots
+// for releasing them.
+type Discarder interface {
+	Discard(ctx context.Context) error
+}
+
+var (
+	_ Discarder = &Snapshot{}
+	_ Discarder = &Transaction{}
 )
 
 type Snapshot struct {
 	db *Database
 
+	// id is a unique number assigned to the snapshot by the database. It is
+	// zero for layered snapshots.
+	id uint64
+
+	// created holds the database clock time at the creation of this
+	// snapshot.
+	created time.Time
+
 	// snapshotVersion is the max value version readable by this snapshot. This
 	// is also the maxCommitVersion of the database at the creation of this
 	// snapshot.
 	snapshotVersion int64
+
+	// base and overlay are non-nil for layered snapshots, which read keys from
+	// the overlay snapshot first and fall back to the base snapshot.
+	base, overlay *Snapshot
+
+	// trackReads is true if the keys and ranges read by the snapshot are
+	// recorded in the reads and scanRanges fields, which are protected by the
+	// readMu, for upgrading the snapshot to a transaction.
+	trackReads bool
+	readMu     sync.Mutex
+	reads      map[string]struct{}
+	scanRanges []Range
+
+	// expiry is the timer expiring the snapshot when the database has a max
+	// snapshot age. expired is set when the timer fires.
+	expiry  *time.Timer
+	expired atomic.Bool
+
+	// staged, when non-nil, holds a copy of the updates staged by a
+	// transaction, which shadow the database values for snapshots created
+	// with Transaction.SnapshotViewWithOptions.
+	staged map[string]*mvcc.Value
+}
+
+// SnapshotOptions holds the options for creating a snapshot. Zero value holds
+// the default options.
+type SnapshotOptions struct {
+	// TrackReads, when true, records the keys and ranges read by the snapshot,
+	// so that they are validated for conflicts when the snapshot is upgraded
+	// to a transaction. Recording adds a mutex acquisition and an allocation
+	// for every new key read.
+	TrackReads bool
+}
+
+// NewLayeredSnapshot creates a read-only snapshot that merges two snapshots,
+// possibly from different databases. Keys are looked up in the overlay
+// snapshot first and then in the base snapshot, so that values and deletions
+// in the overlay shadow the base. Base and overlay snapshots must not be
+// discarded while the layered snapshot is in use.
+func NewLayeredSnapshot(ctx context.Context, base *Snapshot, overlay *Snapshot) (*Snapshot, error) {
+	if base == nil || overlay == nil || base.db == nil || overlay.db == nil {
+		return nil, os.ErrInvalid
+	}
+	s := &Snapshot{
+		db:              overlay.db,
+		snapshotVersion: overlay.snapshotVersion,
+		base:            base,
+		overlay:         overlay,
+	}
+	return s, nil
+}
+
+// fetch returns the value visible to the snapshot for the input key, which
+// could be a deleted value. Returns nil if key doesn't exist.
+func (s *Snapshot) fetch(key string) *mvcc.Value {
+	if s.overlay != nil {
+		if v := s.overlay.fetch(key); v != nil {
+			return v
+		}
+		return s.base.fetch(key)
+	}
+	if v, ok := s.staged[key]; ok {
+		return v
+	}
+	if s.trackReads {
+		s.recordRead(key)
+	}
+	if v := s.db.fetch(key, s.snapshotVersion); v != nil {
+		s.db.touchRead(key)
+		return v
+	}
+	return nil
+}
+
+// check returns a non-nil error if the snapshot, or any snapshot under a
+// layered snapshot, has expired or if its database is corrupted. It must be
+// called after fetching the values, so that the values fetched before the
+// expiry are not yet compacted.
+func (s *Snapshot) check() error {
+	if s.overlay != nil {
+		if err := s.overlay.check(); err != nil {
+			return err
+		}
+		return s.base.check()
+	}
+	if s.expired.Load() {
+		return fmt.Errorf("snapshot %d has expired: %w", s.id, os.ErrInvalid)
+	}
+	if s.db != nil {
+		return s.db.checkPoisoned()
+	}
+	return nil
 }
 
 // Get returns the value associated with the input key. Returns os.ErrNotExist
 // if key was deleted or doesn't exist.
 func (s *Snapshot) Get(ctx context.Context, key string) (io.Reader, error) {
-	if len(key) == 0 {
-		return nil, os.ErrInvalid
+	if err := s.db.checkKeySize(key); err != nil {
+		return nil, err
 	}
 
-	if mv, ok := s.db.kvs.Load(key); ok {
-		if v, ok := mv.Fetch(s.snapshotVersion); ok {
-			if v.IsDeleted() {
-				return nil, os.ErrNotExist
-			}
-			return strings.NewReader(v.Data()), nil
+	v := s.fetch(key)
+	if err := s.check(); err != nil {
+		return nil, err
+	}
+	if v == nil || v.IsDeleted() {
+		return nil, os.ErrNotExist
+	}
+	if m, ok := s.db.parseManifest(v.Data()); ok {
+		chunks, err := s.chunks(key, m)
+		if err != nil {
+			return nil, err
 		}
+		return newChunkReader(m, chunks), nil
 	}
-	return nil, os.ErrNotExist
+	return strings.NewReader(v.Data()), nil
+}
+
+// GetVersion returns the commit version of the key's value visible to the
+// snapshot. Returns os.ErrNotExist if key was deleted or doesn't exist.
+func (s *Snapshot) GetVersion(ctx context.Context, key string) (int64, error) {
+	if err := s.db.checkKeySize(key); err != nil {
+		return 0, err
+	}
+
+	v := s.fetch(key)
+	if err := s.check(); err != nil {
+		return 0, err
+	}
+	if v != nil && !v.IsDeleted() {
+		return v.Version(), nil
+	}
+	return 0, os.ErrNotExist
+}
+
+// VersionedValue holds a value of a key at a commit version.
+type VersionedValue struct {
+	// Version is the commit version of the value.
+	Version int64
+
+	// Deleted is true if the key is deleted at the version, in which case the
+	// Value is empty.
+	Deleted bool
+
+	// Value holds the value of the key at the version.
+	Value []byte
+}
+
+// GetVersionRange returns the values of the key committed at the versions in
+// the [from, to] window, in the increasing version order, including the
+// deletions. Versions after the snapshot are not visible, so the window is
+// limited to the snapshot version. Returns an empty slice if the key has no
+// values in the window.
+//
+// Returns an *ErrCompacted error if the from version is below the compaction
+// floor, where the values in the window may have been removed. Returns
+// os.ErrInvalid if from is larger than to. Overlay databases only report the
+// values committed to the overlay and layered snapshots are not supported.
+func (s *Snapshot) GetVersionRange(ctx context.Context, key string, from, to int64) ([]VersionedValue, error) {
+	if err := s.db.checkKeySize(key); err != nil {
+		return nil, err
+	}
+	if from > to {
+		return nil, fmt.Errorf("version range [%d, %d] is invalid: %w", from, to, os.ErrInvalid)
+	}
+	if s.overlay != nil {
+		return nil, fmt.Errorf("version ranges are not supported by layered snapshots: %w", errors.ErrUnsupported)
+	}
+	if s.trackReads {
+		s.recordRead(key)
+	}
+
+	mv, ok := s.db.kvs.Load(key)
+	// Compaction advances the floor before storing the compacted values, so
+	// the values loaded above are complete if the floor is still below from.
+	if retained := s.db.compactedVersion.Load(); from < retained {
+		return nil, &ErrCompacted{Version: from, Retained: retained}
+	}
+	if err := s.check(); err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, nil
+	}
+
+	to = min(to, s.snapshotVersion)
+	var values []VersionedValue
+	for _, v := range mv.Values() {
+		if v.Version() < from || v.Version() > to {
+			continue
+		}
+		values = append(values, VersionedValue{
+			Version: v.Version(),
+			Deleted: v.IsDeleted(),
+			Value:   []byte(v.Data()),
+		})
+	}
+	return values, nil
+}
+
+// GetInto copies the value associated with the input key into the dst slice,
+// growing it as necessary. No memory is allocated when dst has enough
+// capacity for the value. Returns os.ErrNotExist if key was deleted or doesn't
+// exist.
+func (s *Snapshot) GetInto(ctx context.Context, key string, dst *[]byte) error {
+	if dst == nil {
+		return os.ErrInvalid
+	}
+	if err := s.db.checkKeySize(key); err != nil {
+		return err
+	}
+
+	v := s.fetch(key)
+	if err := s.check(); err != nil {
+		return err
+	}
+	if v == nil || v.IsDeleted() {
+		return os.ErrNotExist
+	}
+	if m, ok := s.db.parseManifest(v.Data()); ok {
+		chunks, err := s.chunks(key, m)
+		if err != nil {
+			return err
+		}
+		*dst = (*dst)[:0]
+		for _, c := range chunks {
+			*dst = append(*dst, c...)
+		}
+		return nil
+	}
+	*dst = append((*dst)[:0], v.Data()...)
+	return nil
 }
 
 // keys returns all keys between the [begin, end) range in no-specific order.
+// Internal keys are not included.
 func (s *Snapshot) keys(begin, end string) []string {
 	kset := make(map[string]struct{})
-	for k := range s.db.kvs.Range {
-		if _, ok := kset[k]; !ok {
-			kset[k] = struct{}{}
-		}
-	}
+	s.collectKeys(kset, rangePrefix(begin, end))
 
 	keys := make([]string, 0, len(kset))
 	for k := range kset {
-		keys = append(keys, k)
+		if !s.db.hiddenKey(k) {
+			keys = append(keys, k)
+		}
 	}
 
 	keys = slices.DeleteFunc(keys, func(k string) bool {
@@ -70,10 +311,35 @@ func (s *Snapshot) keys(begin, end string) []string {
 	return keys
 }
 
+// collectKeys adds all keys with the input prefix in the snapshot's
+// database(s) to the input set.
+func (s *Snapshot) collectKeys(kset map[string]struct{}, prefix string) {
+	if s.overlay != nil {
+		s.base.collectKeys(kset, prefix)
+		s.overlay.collectKeys(kset, prefix)
+		return
+	}
+	for k := range s.db.prefixKeys(prefix) {
+		kset[k] = struct{}{}
+	}
+	for k := range s.staged {
+		if strings.HasPrefix(k, prefix) {
+			kset[k] = struct{}{}
+		}
+	}
+}
+
 // Scan implements kv.Scanner interface to range over all key-value pairs in
-// the database.
+// the database. Keys are yielded in an undefined order, which can differ
+// between the scans; use Ascend or Descend for ordered scans.
+//
+// Returned iterator can be ranged over multiple times. Every range performs a
+// fresh scan and resets *errp to nil when it begins, so errors from an earlier
+// range do not leak into the later ones. This applies to all scan methods.
 func (s *Snapshot) Scan(ctx context.Context, errp *error) iter.Seq2[string, io.Reader] {
 	return func(yield func(string, io.Reader) bool) {
+		*errp = nil
+		s.recordRange("", "")
 		for _, key := range s.keys("", "") {
 			value, err := s.Get(ctx, key)
 			if err != nil {
@@ -94,10 +360,12 @@ func (s *Snapshot) Scan(ctx context.Context, errp *error) iter.Seq2[string, io.R
 // 'begin' and 'end' keys in the database in ascending order.
 func (s *Snapshot) Ascend(ctx context.Context, begin, end string, errp *error) iter.Seq2[string, io.Reader] {
 	return func(yield func(string, io.Reader) bool) {
-		if begin != "" && end != "" && begin > end {
-			*errp = os.ErrInvalid
+		*errp = nil
+		if err := checkRange(begin, end); err != nil {
+			*errp = err
 			return
 		}
+		s.recordRange(begin, end)
 
 		keys := s.keys(begin, end)
 		sort.Strings(keys)
@@ -122,10 +390,12 @@ func (s *Snapshot) Ascend(ctx context.Context, begin, end string, errp *error) i
 // 'begin' and 'end' keys in the database in descending order.
 func (s *Snapshot) Descend(ctx context.Context, begin, end string, errp *error) iter.Seq2[string, io.Reader] {
 	return func(yield func(string, io.Reader) bool) {
-		if begin != "" && end != "" && begin > end {
-			*errp = os.ErrInvalid
+		*errp = nil
+		if err := checkRange(begin, end); err != nil {
+			*errp = err
 			return
 		}
+		s.recordRange(begin, end)
 
 		keys := s.keys(begin, end)
 		sort.Strings(keys)
@@ -146,6 +416,70 @@ func (s *Snapshot) Descend(ctx context.Context, begin, end string, errp *error)
 	}
 }
 
+// ScanPrefix is similar to Ascend, but ranges over the key-value pairs with
+// the input prefix in ascending order.
+func (s *Snapshot) ScanPrefix(ctx context.Context, prefix string, errp *error) iter.Seq2[string, io.Reader] {
+	return s.Ascend(ctx, prefix, prefixEnd(prefix), errp)
+}
+
+// Version returns the database version visible to the snapshot, which is the
+// version of the last transaction committed before the snapshot is created.
+// Versions of snapshots from different databases are not comparable. Layered
+// snapshots report the version of their overlay snapshot.
+func (s *Snapshot) Version() int64 {
+	return s.snapshotVersion
+}
+
+// IsNewerThan returns true if the snapshot observes a later database state
+// than the other snapshot.
+func (s *Snapshot) IsNewerThan(other *Snapshot) bool {
+	return s.snapshotVersion > other.snapshotVersion
+}
+
+// IsOlderThan returns true if the snapshot observes an earlier database state
+// than the other snapshot.
+func (s *Snapshot) IsOlderThan(other *Snapshot) bool {
+	return s.snapshotVersion < other.snapshotVersion
+}
+
+// String returns a short description of the snapshot for debugging, e.g.,
+// "Snapshot{id: 3, version: 42}". Key counts are not included, because they
+// are not available without a scan.
+func (s *Snapshot) String() string {
+	if s.overlay != nil {
+		return fmt.Sprintf("Snapshot{version: %d, layers: [%v, %v]}", s.snapshotVersion, s.overlay, s.base)
+	}
+	return fmt.Sprintf("Snapshot{id: %d, version: %d}", s.id, s.snapshotVersion)
+}
+
+// Subset creates a new, independent database holding only the input keys with
+// their values visible to this snapshot, and returns a snapshot of the new
+// database. Missing and deleted keys are omitted. New database is created with
+// the default options and is not connected to the original database.
+func (s *Snapshot) Subset(ctx context.Context, keys []string) (*Snapshot, error) {
+	m := make(map[string][]byte, len(keys))
+	for _, key := range keys {
+		if err := s.db.checkKeySize(key); err != nil {
+			return nil, err
+		}
+		if v := s.fetch(key); v != nil && !v.IsDeleted() {
+			data, err := s.value(key, v.Data())
+			if err != nil {
+				return nil, err
+			}
+			m[key] = []byte(data)
+		}
+	}
+	if err := s.check(); err != nil {
+		return nil, err
+	}
+	db, err := FromMap(ctx, m)
+	if err != nil {
+		return nil, err
+	}
+	return db.NewSnapshot(ctx)
+}
+
 // Discard releases the snapshot.
 func (s *Snapshot) Discard(ctx context.Context) error {
 	if s.db == nil {
@@ -154,3 +488,127 @@ func (s *Snapshot) Discard(ctx context.Context) error {
 	s.db.closeSnapshot(s)
 	return nil
 }
+
+// AscendFilter is similar to Ascend, but only yields the key-value pairs for
+// which the keep function returns true. Readers are not created for the
+// rejected key-value pairs.
+func (s *Snapshot) AscendFilter(ctx context.Context, begin, end string, keep func(key string, value []byte) bool, errp *error) iter.Seq2[string, io.Reader] {
+	return func(yield func(string, io.Reader) bool) {
+		*errp = nil
+		if err := checkRange(begin, end); err != nil {
+			*errp = err
+			return
+		}
+		s.recordRange(begin, end)
+
+		keys := s.keys(begin, end)
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			v := s.fetch(key)
+			if err := s.check(); err != nil {
+				*errp = err
+				return
+			}
+			if v == nil || v.IsDeleted() {
+				continue
+			}
+			data, err := s.value(key, v.Data())
+			if err != nil {
+				*errp = err
+				return
+			}
+			if !keep(key, []byte(data)) {
+				continue
+			}
+			if !yield(key, strings.NewReader(data)) {
+				return
+			}
+		}
+	}
+}
+
+// Entry holds the state of a key, including the deleted keys, visible to a
+// snapshot.
+type Entry struct {
+	// Value holds the value of the key; it is empty for the deleted keys.
+	Value []byte
+
+	// Deleted is true if the key is deleted.
+	Deleted bool
+
+	// Version is the commit version of the value or the deletion.
+	Version int64
+}
+
+// ScanAll returns an iterator over all keys visible to the snapshot, including
+// the deleted keys whose deletions are not yet compacted, in ascending order.
+// Unlike the other scans, deletions are yielded with the Deleted flag set, so
+// that they can be propagated to a replica. All entries are read at the
+// snapshot version. Iteration stops early if the context is canceled or the
+// snapshot expires.
+//
+// Deletions are compacted once no snapshot can observe the deleted key's older
+// values, so replicas that must observe every deletion should use a snapshot
+// that was live since their last sync, or DeletedSince.
+func (s *Snapshot) ScanAll(ctx context.Context) iter.Seq2[string, Entry] {
+	return func(yield func(string, Entry) bool) {
+		s.recordRange("", "")
+
+		keys := s.keys("", "")
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			if ctx.Err() != nil {
+				return
+			}
+			v := s.fetch(key)
+			if s.check() != nil {
+				return
+			}
+			if v == nil {
+				continue
+			}
+			e := Entry{Deleted: v.IsDeleted(), Version: v.Version()}
+			if !e.Deleted {
+				data, err := s.value(key, v.Data())
+				if err != nil {
+					return
+				}
+				e.Value = []byte(data)
+			}
+			if !yield(key, e) {
+				return
+			}
+		}
+	}
+}
+
+// Tombstones returns an iterator over the keys in the [begin, end) range, in
+// ascending order, whose visible value at the snapshot is a deletion that is
+// not yet compacted. It is meant for debugging the compaction behavior, since
+// deleted keys are otherwise invisible through the read methods. Invalid
+// ranges yield no keys.
+func (s *Snapshot) Tombstones(ctx context.Context, begin, end string) iter.Seq[string] {
+	return func(yield func(string) bool) {
+		if checkRange(begin, end) != nil {
+			return
+		}
+
+		keys := s.keys(begin, end)
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			v := s.fetch(key)
+			if s.check() != nil {
+				return
+			}
+			if v == nil || !v.IsDeleted() {
+				continue
+			}
+			if !yield(key) {
+				return
+			}
+		}
+	}
+}
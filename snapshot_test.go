@@ -0,0 +1,501 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/visvasity/kv"
+)
+
+// readAll returns all key-value pairs of the input range in ascending and
+// descending order.
+func readAll(ctx context.Context, t *testing.T, r kv.Reader, begin, end string) (ascend, descend []string) {
+	t.Helper()
+
+	var ascendErr, descendErr error
+	for k, v := range r.Ascend(ctx, begin, end, &ascendErr) {
+		data, err := io.ReadAll(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ascend = append(ascend, k+"="+string(data))
+	}
+	for k, v := range r.Descend(ctx, begin, end, &descendErr) {
+		data, err := io.ReadAll(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		descend = append(descend, k+"="+string(data))
+	}
+	if err := errors.Join(ascendErr, descendErr); err != nil {
+		t.Fatal(err)
+	}
+	return ascend, descend
+}
+
+func TestLayeredSnapshot(t *testing.T) {
+	ctx := context.Background()
+
+	baseDB := New()
+	tx, _ := baseDB.NewTransaction(ctx)
+	tx.Set(ctx, "a", strings.NewReader("base-a"))
+	tx.Set(ctx, "b", strings.NewReader("base-b"))
+	tx.Set(ctx, "c", strings.NewReader("base-c"))
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	overlayDB := New()
+	tx, _ = overlayDB.NewTransaction(ctx)
+	tx.Set(ctx, "b", strings.NewReader("overlay-b"))
+	tx.Set(ctx, "c", strings.NewReader("overlay-c"))
+	tx.Set(ctx, "d", strings.NewReader("overlay-d"))
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	tx, _ = overlayDB.NewTransaction(ctx)
+	tx.Delete(ctx, "b")
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	base, _ := baseDB.NewSnapshot(ctx)
+	defer base.Discard(ctx)
+	overlay, _ := overlayDB.NewSnapshot(ctx)
+	defer overlay.Discard(ctx)
+
+	layered, err := NewLayeredSnapshot(ctx, base, overlay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer layered.Discard(ctx)
+
+	if _, err := layered.Get(ctx, "b"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("key deleted in the overlay: want os.ErrNotExist, got %v", err)
+	}
+	if v, err := layered.Get(ctx, "a"); err != nil {
+		t.Error(err)
+	} else if data, _ := io.ReadAll(v); string(data) != "base-a" {
+		t.Errorf("got %q, want base-a", data)
+	}
+
+	ascend, descend := readAll(ctx, t, layered, "", "")
+	if want := []string{"a=base-a", "c=overlay-c", "d=overlay-d"}; !reflect.DeepEqual(ascend, want) {
+		t.Errorf("Ascend = %v, want %v", ascend, want)
+	}
+	if want := []string{"d=overlay-d", "c=overlay-c", "a=base-a"}; !reflect.DeepEqual(descend, want) {
+		t.Errorf("Descend = %v, want %v", descend, want)
+	}
+
+	ascend, _ = readAll(ctx, t, layered, "b", "d")
+	if want := []string{"c=overlay-c"}; !reflect.DeepEqual(ascend, want) {
+		t.Errorf("Ascend[b, d) = %v, want %v", ascend, want)
+	}
+}
+
+func TestFromMap(t *testing.T) {
+	ctx := context.Background()
+
+	db, err := FromMap(ctx, map[string][]byte{
+		"a":     []byte("1"),
+		"b":     []byte("2"),
+		"empty": nil,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snap, _ := db.NewSnapshot(ctx)
+	defer snap.Discard(ctx)
+	if v := snap.snapshotVersion; v != 1 {
+		t.Errorf("snapshot version = %d, want 1", v)
+	}
+	ascend, _ := readAll(ctx, t, snap, "", "")
+	if want := []string{"a=1", "b=2", "empty="}; !reflect.DeepEqual(ascend, want) {
+		t.Errorf("got %v, want %v", ascend, want)
+	}
+
+	// Subsequent transactions behave normally.
+	tx, _ := db.NewTransaction(ctx)
+	defer tx.Rollback(ctx)
+	if err := tx.Update(ctx, "a", func([]byte) ([]byte, error) { return []byte("10"), nil }); err != nil {
+		t.Fatal(err)
+	}
+	mustSet(ctx, t, db, "a", "concurrent")
+	if err := tx.Commit(ctx); err == nil {
+		t.Errorf("update must conflict with a concurrent update")
+	}
+	if got := mustGet(ctx, t, snap, "a"); got != "1" {
+		t.Errorf("snapshot at version 1 read %q, want 1", got)
+	}
+
+	if _, err := FromMap(ctx, map[string][]byte{"": nil}); !errors.Is(err, os.ErrInvalid) {
+		t.Errorf("empty key: want os.ErrInvalid, got %v", err)
+	}
+}
+
+func TestGetIntoAllocs(t *testing.T) {
+	ctx := context.Background()
+
+	value := strings.Repeat("x", 1024)
+	db, _ := FromMap(ctx, map[string][]byte{"key": []byte(value)})
+	snap, _ := db.NewSnapshot(ctx)
+	defer snap.Discard(ctx)
+
+	var buf []byte
+	if err := snap.GetInto(ctx, "key", &buf); err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != value {
+		t.Fatalf("GetInto returned a wrong value")
+	}
+	if err := snap.GetInto(ctx, "missing", &buf); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("want os.ErrNotExist, got %v", err)
+	}
+
+	getInto := testing.AllocsPerRun(1000, func() {
+		snap.GetInto(ctx, "key", &buf)
+	})
+	get := testing.AllocsPerRun(1000, func() {
+		r, _ := snap.Get(ctx, "key")
+		io.ReadAll(r)
+	})
+	if getInto != 0 || get < 2 {
+		t.Errorf("GetInto makes %v allocations and Get makes %v allocations per call", getInto, get)
+	}
+}
+
+func BenchmarkGet(b *testing.B) {
+	ctx := context.Background()
+
+	db, _ := FromMap(ctx, map[string][]byte{"key": bytes.Repeat([]byte("x"), 1024)})
+	snap, _ := db.NewSnapshot(ctx)
+	defer snap.Discard(ctx)
+
+	b.Run("Get", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r, _ := snap.Get(ctx, "key")
+			io.ReadAll(r)
+		}
+	})
+	b.Run("GetInto", func(b *testing.B) {
+		b.ReportAllocs()
+		var buf []byte
+		for i := 0; i < b.N; i++ {
+			snap.GetInto(ctx, "key", &buf)
+		}
+	})
+}
+
+func TestSnapshotOrder(t *testing.T) {
+	ctx := context.Background()
+
+	db := New()
+	before, _ := db.NewSnapshot(ctx)
+	defer before.Discard(ctx)
+	same, _ := db.NewSnapshot(ctx)
+	defer same.Discard(ctx)
+
+	mustSet(ctx, t, db, "key", "value")
+	after, _ := db.NewSnapshot(ctx)
+	defer after.Discard(ctx)
+
+	if before.Version() != same.Version() || after.Version() != before.Version()+1 {
+		t.Errorf("versions = %d, %d, %d, want consecutive versions for the snapshots around the commit", before.Version(), same.Version(), after.Version())
+	}
+	if !after.IsNewerThan(before) || before.IsNewerThan(after) {
+		t.Errorf("snapshot after the commit must be newer than the one before")
+	}
+	if !before.IsOlderThan(after) || after.IsOlderThan(before) {
+		t.Errorf("snapshot before the commit must be older than the one after")
+	}
+	if before.IsNewerThan(same) || before.IsOlderThan(same) {
+		t.Errorf("snapshots without commits in between must be neither newer nor older")
+	}
+}
+
+func TestTombstones(t *testing.T) {
+	ctx := context.Background()
+
+	db := New()
+	for _, key := range []string{"a", "b", "c", "d"} {
+		mustSet(ctx, t, db, key, "value")
+	}
+
+	// Snapshot retains the deleted versions from compaction.
+	old, _ := db.NewSnapshot(ctx)
+	defer old.Discard(ctx)
+
+	tx, _ := db.NewTransaction(ctx)
+	tx.Delete(ctx, "b")
+	tx.Delete(ctx, "d")
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	snap, _ := db.NewSnapshot(ctx)
+	defer snap.Discard(ctx)
+
+	if got := slices.Collect(snap.Tombstones(ctx, "", "")); !reflect.DeepEqual(got, []string{"b", "d"}) {
+		t.Errorf("Tombstones = %q, want [b d]", got)
+	}
+	if got := slices.Collect(snap.Tombstones(ctx, "a", "c")); !reflect.DeepEqual(got, []string{"b"}) {
+		t.Errorf("Tombstones in [a, c) = %q, want [b]", got)
+	}
+	if got := slices.Collect(old.Tombstones(ctx, "", "")); len(got) != 0 {
+		t.Errorf("Tombstones before the deletion = %q, want none", got)
+	}
+	if got := slices.Collect(snap.Tombstones(ctx, "c", "a")); len(got) != 0 {
+		t.Errorf("Tombstones in an invalid range = %q, want none", got)
+	}
+}
+
+func TestNewSnapshots(t *testing.T) {
+	ctx := context.Background()
+
+	db := New()
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			if err := setKey(ctx, db, "key", strconv.Itoa(i)); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+
+	for i := 0; i < 100; i++ {
+		snaps, err := db.NewSnapshots(ctx, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range snaps[1:] {
+			if s.Version() != snaps[0].Version() {
+				t.Errorf("snapshot versions %d and %d differ", snaps[0].Version(), s.Version())
+			}
+		}
+		for _, s := range snaps {
+			if err := s.Discard(ctx); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	close(stop)
+	<-done
+
+	if h := db.Health(); h.LiveSnapshots != 0 {
+		t.Errorf("Health = %+v, want no live snapshots", h)
+	}
+	if _, err := db.NewSnapshots(ctx, 0); !errors.Is(err, os.ErrInvalid) {
+		t.Errorf("NewSnapshots(0) = %v, want os.ErrInvalid", err)
+	}
+}
+
+func TestGetVersionRange(t *testing.T) {
+	ctx := context.Background()
+
+	db := New()
+	old, _ := db.NewSnapshot(ctx)
+	defer old.Discard(ctx)
+
+	mustSet(ctx, t, db, "key", "1")   // 1
+	mustSet(ctx, t, db, "other", "1") // 2
+	mustSet(ctx, t, db, "key", "3")   // 3
+	tx, _ := db.NewTransaction(ctx)
+	tx.Delete(ctx, "key")
+	if err := tx.Commit(ctx); err != nil { // 4
+		t.Fatal(err)
+	}
+	snap, _ := db.NewSnapshot(ctx)
+	defer snap.Discard(ctx)
+	mustSet(ctx, t, db, "key", "5") // 5, invisible to snap
+
+	format := func(vs []VersionedValue) []string {
+		var out []string
+		for _, v := range vs {
+			if v.Deleted {
+				out = append(out, fmt.Sprintf("deleted@%d", v.Version))
+			} else {
+				out = append(out, fmt.Sprintf("%s@%d", v.Value, v.Version))
+			}
+		}
+		return out
+	}
+
+	for _, test := range []struct {
+		from, to int64
+		want     []string
+	}{
+		{1, 10, []string{"1@1", "3@3", "deleted@4"}},
+		{2, 3, []string{"3@3"}},
+		{4, 4, []string{"deleted@4"}},
+		{5, 10, nil},
+	} {
+		got, err := snap.GetVersionRange(ctx, "key", test.from, test.to)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(format(got), test.want) {
+			t.Errorf("GetVersionRange(%d, %d) = %q, want %q", test.from, test.to, format(got), test.want)
+		}
+	}
+	if got, err := snap.GetVersionRange(ctx, "missing", 1, 10); err != nil || len(got) != 0 {
+		t.Errorf("GetVersionRange on a missing key = %v, %v, want no values", got, err)
+	}
+	if _, err := snap.GetVersionRange(ctx, "key", 3, 2); !errors.Is(err, os.ErrInvalid) {
+		t.Errorf("GetVersionRange with from > to = %v, want os.ErrInvalid", err)
+	}
+
+	// Older versions are compacted once the older snapshots are discarded.
+	old.Discard(ctx)
+	snap.Discard(ctx)
+	mustSet(ctx, t, db, "key", "6")
+	latest, _ := db.NewSnapshot(ctx)
+	defer latest.Discard(ctx)
+
+	var cerr *ErrCompacted
+	if _, err := latest.GetVersionRange(ctx, "key", 1, 10); !errors.As(err, &cerr) || cerr.Version != 1 {
+		t.Fatalf("GetVersionRange below the compaction floor = %v, want an *ErrCompacted", err)
+	}
+	got, err := latest.GetVersionRange(ctx, "key", cerr.Retained, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(got); n == 0 || string(got[n-1].Value) != "6" {
+		t.Errorf("GetVersionRange from the compaction floor = %q, want the latest value", format(got))
+	}
+}
+
+func TestSnapshotSubset(t *testing.T) {
+	ctx := context.Background()
+
+	db := New()
+	mustSet(ctx, t, db, "a", "1")
+	mustSet(ctx, t, db, "b", "2")
+	mustSet(ctx, t, db, "c", "3")
+	tx, _ := db.NewTransaction(ctx)
+	tx.Delete(ctx, "c")
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	snap, _ := db.NewSnapshot(ctx)
+	defer snap.Discard(ctx)
+	sub, err := snap.Subset(ctx, []string{"a", "c", "missing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Discard(ctx)
+
+	// Updates to the original database are not visible in the subset.
+	mustSet(ctx, t, db, "a", "updated")
+	mustSet(ctx, t, db, "missing", "created")
+	snap.Discard(ctx)
+
+	if got, _ := readAll(ctx, t, sub, "", ""); !slices.Equal(got, []string{"a=1"}) {
+		t.Errorf("subset holds %q, want [a=1]", got)
+	}
+	if sub.db == db {
+		t.Errorf("subset snapshot must belong to a new database")
+	}
+}
+
+func TestScanAll(t *testing.T) {
+	ctx := context.Background()
+
+	db := New()
+	mustSet(ctx, t, db, "b", "1") // 1
+	mustSet(ctx, t, db, "a", "2") // 2
+	mustSet(ctx, t, db, "c", "3") // 3
+
+	// An older snapshot retains the deleted value of b.
+	old, _ := db.NewSnapshot(ctx)
+	defer old.Discard(ctx)
+	tx, _ := db.NewTransaction(ctx)
+	tx.Delete(ctx, "b")
+	if err := tx.Commit(ctx); err != nil { // 4
+		t.Fatal(err)
+	}
+
+	snap, _ := db.NewSnapshot(ctx)
+	defer snap.Discard(ctx)
+	mustSet(ctx, t, db, "d", "5") // invisible to snap
+
+	var got []string
+	for k, e := range snap.ScanAll(ctx) {
+		got = append(got, fmt.Sprintf("%s=%s/%v@%d", k, e.Value, e.Deleted, e.Version))
+	}
+	want := []string{"a=2/false@2", "b=/true@4", "c=3/false@3"}
+	if !slices.Equal(got, want) {
+		t.Errorf("ScanAll = %q, want %q", got, want)
+	}
+}
+
+func TestMaxSnapshotAge(t *testing.T) {
+	ctx := context.Background()
+
+	const age = 20 * time.Millisecond
+	h := new(recordHandler)
+	db := New(WithMaxSnapshotAge(age), WithLogger(slog.New(h)))
+	for i := 0; i < 10; i++ {
+		mustSet(ctx, t, db, fmt.Sprintf("key%d", i), "value")
+	}
+
+	// Discarded snapshots are not expired.
+	snap, _ := db.NewSnapshot(ctx)
+	snap.Discard(ctx)
+
+	snap, _ = db.NewSnapshot(ctx)
+	defer snap.Discard(ctx)
+
+	var err error
+	n := 0
+	for range snap.Ascend(ctx, "", "", &err) {
+		if n++; n == 2 {
+			// A slow consumer outlives the snapshot.
+			for db.Health().LiveSnapshots != 0 {
+				time.Sleep(age / 4)
+			}
+		}
+	}
+	if !errors.Is(err, os.ErrInvalid) || n != 2 {
+		t.Errorf("scan of an expired snapshot: want os.ErrInvalid after 2 keys, got %v after %d keys", err, n)
+	}
+	if _, err := snap.Get(ctx, "key0"); !errors.Is(err, os.ErrInvalid) {
+		t.Errorf("Get on an expired snapshot: want os.ErrInvalid, got %v", err)
+	}
+	if _, err := snap.Upgrade(ctx); !errors.Is(err, os.ErrInvalid) {
+		t.Errorf("Upgrade of an expired snapshot: want os.ErrInvalid, got %v", err)
+	}
+	if records := h.take(); len(records) != 1 {
+		t.Errorf("want one log record for the expired snapshot, got %d", len(records))
+	}
+
+	// Expired snapshots no longer hold back the compaction.
+	mustSet(ctx, t, db, "key0", "new")
+	mustSet(ctx, t, db, "key0", "newer")
+	if got := db.compactedVersion.Load(); got <= snap.Version() {
+		t.Errorf("compacted version: want above %d, got %d", snap.Version(), got)
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"context"
+	"iter"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// SizeStats holds the number of keys and their sizes in bytes.
+type SizeStats struct {
+	Keys       int64
+	KeyBytes   int64
+	ValueBytes int64
+}
+
+// PrefixStats groups the live keys by their first depth components separated
+// by sep and returns the key count and sizes for each group in the ascending
+// order of the group prefixes. Group prefixes include the trailing separator;
+// keys with depth or fewer components are grouped by themselves. All keys
+// are grouped under the empty prefix when depth is less than one.
+//
+// Statistics are computed by walking a snapshot of the database, so they
+// are consistent and do not block the commits. Iteration stops early if the
+// context is canceled.
+func (d *Database) PrefixStats(ctx context.Context, depth int, sep string) iter.Seq2[string, SizeStats] {
+	return func(yield func(string, SizeStats) bool) {
+		snap, err := d.NewSnapshot(ctx)
+		if err != nil {
+			return
+		}
+		defer snap.Discard(ctx)
+
+		groups := make(map[string]*SizeStats)
+		for key := range d.rangeKeys {
+			if ctx.Err() != nil {
+				return
+			}
+			if d.hiddenKey(key) {
+				continue
+			}
+			v := snap.fetch(key)
+			if v == nil || v.IsDeleted() {
+				continue
+			}
+			size := int64(len(v.Data()))
+			if m, ok := d.parseManifest(v.Data()); ok {
+				size = m.size
+			}
+			prefix := keyPrefix(key, depth, sep)
+			stats, ok := groups[prefix]
+			if !ok {
+				stats = new(SizeStats)
+				groups[prefix] = stats
+			}
+			stats.Keys++
+			stats.KeyBytes += int64(len(key))
+			stats.ValueBytes += size
+		}
+
+		prefixes := make([]string, 0, len(groups))
+		for prefix := range groups {
+			prefixes = append(prefixes, prefix)
+		}
+		slices.Sort(prefixes)
+		for _, prefix := range prefixes {
+			if !yield(prefix, *groups[prefix]) {
+				return
+			}
+		}
+	}
+}
+
+// VersionHistogram returns the number of keys for each number of versions
+// retained per key, including the deletion markers. It shows how much history
+// the keys accumulate between their compactions, which happen when the keys
+// are updated.
+//
+// Key set is copied first and the versions of each key are counted
+// independently, so the walk does not block the commits, but the result does
+// not reflect a single database version. Returns nil if the context is
+// canceled.
+func (d *Database) VersionHistogram(ctx context.Context) map[int]int {
+	var keys []string
+	for key := range d.kvs.Range {
+		keys = append(keys, key)
+	}
+
+	hist := make(map[int]int)
+	for _, key := range keys {
+		if ctx.Err() != nil {
+			return nil
+		}
+		if mv, ok := d.kvs.Load(key); ok {
+			hist[len(mv.Values())]++
+		}
+	}
+	return hist
+}
+
+// ValueSizeHistogram returns the number of live values in the snapshot for
+// each byte-size bucket. Buckets are the inclusive upper bounds of the value
+// sizes in the ascending order, so the i-th count is the number of values
+// larger than buckets[i-1] and up to buckets[i] bytes. Returned slice has an
+// extra count at the end for the values larger than the last bucket.
+//
+// Sizes of the chunked values are the sizes of the whole values. Values are
+// counted in a single pass without creating their readers.
+func (s *Snapshot) ValueSizeHistogram(buckets []int) []int {
+	counts := make([]int, len(buckets)+1)
+	for _, key := range s.keys("", "") {
+		v := s.fetch(key)
+		if v == nil || v.IsDeleted() {
+			continue
+		}
+		size := int64(len(v.Data()))
+		if m, ok := s.db.parseManifest(v.Data()); ok {
+			size = m.size
+		}
+		i := sort.Search(len(buckets), func(i int) bool { return size <= int64(buckets[i]) })
+		counts[i]++
+	}
+	return counts
+}
+
+// keyPrefix returns the first depth components of the key separated by sep,
+// including the trailing separator. Returns the key itself if it has depth or
+// fewer components.
+func keyPrefix(key string, depth int, sep string) string {
+	n := 0
+	for i := 0; i < depth; i++ {
+		j := strings.Index(key[n:], sep)
+		if j < 0 || sep == "" {
+			return key
+		}
+		n += j + len(sep)
+	}
+	return key[:n]
+}
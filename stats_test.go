@@ -0,0 +1,171 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPrefixStats(t *testing.T) {
+	ctx := context.Background()
+
+	db := New()
+	tx, _ := db.NewTransaction(ctx)
+	for key, value := range map[string]string{
+		"users/alice":          "12345",
+		"users/bob":            "123",
+		"orders/2024/1":        "1",
+		"orders/2024/2":        "22",
+		"orders/2025/1":        "333",
+		"config":               "1234567890",
+		"sessions/abc/expires": "",
+		"sessions/deleted":     "value",
+	} {
+		tx.Set(ctx, key, strings.NewReader(value))
+	}
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	tx, _ = db.NewTransaction(ctx)
+	tx.Delete(ctx, "sessions/deleted")
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	collect := func(depth int) map[string]SizeStats {
+		m := make(map[string]SizeStats)
+		for prefix, stats := range db.PrefixStats(ctx, depth, "/") {
+			m[prefix] = stats
+		}
+		return m
+	}
+
+	want1 := map[string]SizeStats{
+		"config":    {Keys: 1, KeyBytes: 6, ValueBytes: 10},
+		"orders/":   {Keys: 3, KeyBytes: 39, ValueBytes: 6},
+		"sessions/": {Keys: 1, KeyBytes: 20, ValueBytes: 0},
+		"users/":    {Keys: 2, KeyBytes: 20, ValueBytes: 8},
+	}
+	if got := collect(1); !reflect.DeepEqual(got, want1) {
+		t.Errorf("PrefixStats(1) = %v, want %v", got, want1)
+	}
+
+	want2 := map[string]SizeStats{
+		"config":        {Keys: 1, KeyBytes: 6, ValueBytes: 10},
+		"orders/2024/":  {Keys: 2, KeyBytes: 26, ValueBytes: 3},
+		"orders/2025/":  {Keys: 1, KeyBytes: 13, ValueBytes: 3},
+		"sessions/abc/": {Keys: 1, KeyBytes: 20, ValueBytes: 0},
+		"users/alice":   {Keys: 1, KeyBytes: 11, ValueBytes: 5},
+		"users/bob":     {Keys: 1, KeyBytes: 9, ValueBytes: 3},
+	}
+	if got := collect(2); !reflect.DeepEqual(got, want2) {
+		t.Errorf("PrefixStats(2) = %v, want %v", got, want2)
+	}
+
+	want0 := map[string]SizeStats{
+		"": {Keys: 7, KeyBytes: 85, ValueBytes: 24},
+	}
+	if got := collect(0); !reflect.DeepEqual(got, want0) {
+		t.Errorf("PrefixStats(0) = %v, want %v", got, want0)
+	}
+
+	// Groups are yielded in the ascending order of prefixes.
+	var prefixes []string
+	for prefix := range db.PrefixStats(ctx, 2, "/") {
+		prefixes = append(prefixes, prefix)
+	}
+	wantPrefixes := []string{"config", "orders/2024/", "orders/2025/", "sessions/abc/", "users/alice", "users/bob"}
+	if !reflect.DeepEqual(prefixes, wantPrefixes) {
+		t.Errorf("PrefixStats(2) prefixes = %v, want %v", prefixes, wantPrefixes)
+	}
+
+	// Snapshot is released after the iteration.
+	if h := db.Health(); h.LiveSnapshots != 0 {
+		t.Errorf("PrefixStats leaked a snapshot: %+v", h)
+	}
+}
+
+func TestVersionHistogram(t *testing.T) {
+	ctx := context.Background()
+
+	db := New()
+	for _, key := range []string{"a", "b", "c", "d"} {
+		mustSet(ctx, t, db, key, "v1")
+	}
+
+	// Live snapshots retain the older versions of the updated keys.
+	for i := 2; i <= 3; i++ {
+		snap, _ := db.NewSnapshot(ctx)
+		defer snap.Discard(ctx)
+		mustSet(ctx, t, db, "a", fmt.Sprintf("v%d", i))
+	}
+	snap, _ := db.NewSnapshot(ctx)
+	defer snap.Discard(ctx)
+	mustSet(ctx, t, db, "b", "v2")
+	tx, _ := db.NewTransaction(ctx)
+	tx.Delete(ctx, "c")
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[int]int{1: 1, 2: 2, 3: 1}
+	if got := db.VersionHistogram(ctx); !reflect.DeepEqual(got, want) {
+		t.Errorf("VersionHistogram = %v, want %v", got, want)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if got := db.VersionHistogram(canceled); got != nil {
+		t.Errorf("VersionHistogram with a canceled context = %v, want nil", got)
+	}
+}
+
+func TestValueSizeHistogram(t *testing.T) {
+	ctx := context.Background()
+
+	db := New()
+	tx, _ := db.NewTransaction(ctx)
+	for key, value := range map[string]string{
+		"empty":   "",
+		"small":   "1234",
+		"limit":   "12345678",
+		"medium":  "123456789",
+		"large":   strings.Repeat("x", 100),
+		"deleted": "123",
+	} {
+		tx.Set(ctx, key, strings.NewReader(value))
+	}
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	tx, _ = db.NewTransaction(ctx)
+	tx.Delete(ctx, "deleted")
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	snap, _ := db.NewSnapshot(ctx)
+	defer snap.Discard(ctx)
+
+	if got, want := snap.ValueSizeHistogram([]int{0, 8, 64}), []int{1, 2, 1, 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ValueSizeHistogram = %v, want %v", got, want)
+	}
+	if got, want := snap.ValueSizeHistogram(nil), []int{5}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ValueSizeHistogram(nil) = %v, want %v", got, want)
+	}
+
+	// Chunked values are counted with their whole sizes.
+	db = New(WithChunkSize(16))
+	if err := setKey(ctx, db, "chunked", strings.Repeat("x", 100)); err != nil {
+		t.Fatal(err)
+	}
+	snap, _ = db.NewSnapshot(ctx)
+	defer snap.Discard(ctx)
+	if got, want := snap.ValueSizeHistogram([]int{16, 64}), []int{0, 0, 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ValueSizeHistogram with chunks = %v, want %v", got, want)
+	}
+}
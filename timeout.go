@@ -0,0 +1,62 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+)
+
+// withDefaultTimeout returns a context bounded by the default timeout of the
+// database, if one is configured and the input context has no deadline.
+// Otherwise, returns the input context as is.
+func (d *Database) withDefaultTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if d.defaultTimeout <= 0 {
+		return ctx, func() {}
+	}
+	if _, ok := ctx.Deadline(); ok {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, d.defaultTimeout)
+}
+
+// readValue reads all data from the user supplied reader, but returns the
+// context error if the context is done before the reader returns. Stalled
+// reads cannot be interrupted, so the reader is abandoned to a goroutine that
+// finishes whenever the reader returns.
+func readValue(ctx context.Context, r io.Reader) ([]byte, error) {
+	return readContext(ctx, r, io.ReadAll)
+}
+
+// readContext is similar to readValue, but reads the data with the input
+// function.
+func readContext[T any](ctx context.Context, r io.Reader, read func(io.Reader) (T, error)) (T, error) {
+	switch r.(type) {
+	case *strings.Reader, *bytes.Reader, *bytes.Buffer:
+		// In-memory readers never block.
+		return read(r)
+	}
+	if ctx.Done() == nil {
+		return read(r)
+	}
+
+	type result struct {
+		data T
+		err  error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		data, err := read(r)
+		ch <- result{data, err}
+	}()
+
+	select {
+	case res := <-ch:
+		return res.data, res.err
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"iter"
+	"os"
+	"slices"
+)
+
+// ErrCompacted is the error returned for versions whose history may have been
+// removed by the compaction. It wraps os.ErrNotExist.
+type ErrCompacted struct {
+	// Version is the requested version.
+	Version int64
+
+	// Retained is the smallest version after which the history is retained.
+	Retained int64
+}
+
+func (e *ErrCompacted) Error() string {
+	return fmt.Sprintf("history after version %d is compacted; only history after version %d is retained", e.Version, e.Retained)
+}
+
+func (e *ErrCompacted) Unwrap() error {
+	return os.ErrNotExist
+}
+
+// advanceCompacted raises the compactedVersion to the input version.
+func (d *Database) advanceCompacted(version int64) {
+	for {
+		v := d.compactedVersion.Load()
+		if v >= version || d.compactedVersion.CompareAndSwap(v, version) {
+			return
+		}
+	}
+}
+
+// DeletedSince returns an iterator over the keys deleted after the input
+// version, with their deletion versions, in the ascending order of the
+// deletion versions. Every deletion is reported, even when the key is
+// recreated or deleted again later, so consumers must check the current
+// state of a key when they need it. Deletions up to the latest committed
+// version when the iteration begins are reported.
+//
+// Sets *errp to an *ErrCompacted error, without yielding any keys, if the
+// history after the input version may have been removed by the compaction.
+// As with the scans, the iterator can be ranged over multiple times.
+func (d *Database) DeletedSince(ctx context.Context, version int64, errp *error) iter.Seq2[string, int64] {
+	return func(yield func(string, int64) bool) {
+		*errp = nil
+
+		snap, err := d.NewSnapshot(ctx)
+		if err != nil {
+			*errp = err
+			return
+		}
+		defer snap.Discard(ctx)
+
+		// Concurrent commits can compact the history while the keys are
+		// walked, so the watermark is checked before and after the walk.
+		checkRetained := func() bool {
+			if retained := d.compactedVersion.Load(); version < retained {
+				*errp = &ErrCompacted{Version: version, Retained: retained}
+				return false
+			}
+			return true
+		}
+		if !checkRetained() {
+			return
+		}
+
+		type deletion struct {
+			key     string
+			version int64
+		}
+		var deletions []deletion
+		for key, mv := range d.kvs.Range {
+			if err := ctx.Err(); err != nil {
+				*errp = err
+				return
+			}
+			if d.hiddenKey(key) {
+				continue
+			}
+			for _, v := range mv.Values() {
+				if v.IsDeleted() && v.Version() > version && v.Version() <= snap.snapshotVersion {
+					deletions = append(deletions, deletion{key, v.Version()})
+				}
+			}
+		}
+		if !checkRetained() {
+			return
+		}
+		slices.SortFunc(deletions, func(a, b deletion) int {
+			return cmp.Or(cmp.Compare(a.version, b.version), cmp.Compare(a.key, b.key))
+		})
+
+		for _, del := range deletions {
+			if !yield(del.key, del.version) {
+				return
+			}
+		}
+	}
+}
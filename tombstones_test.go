@@ -0,0 +1,167 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDeletedSince(t *testing.T) {
+	ctx := context.Background()
+
+	db := New()
+	deletedSince := func(version int64) ([]string, error) {
+		var got []string
+		var err error
+		for key, v := range db.DeletedSince(ctx, version, &err) {
+			got = append(got, fmt.Sprintf("%s@%d", key, v))
+		}
+		return got, err
+	}
+	deleteKey := func(key string) {
+		tx, _ := db.NewTransaction(ctx)
+		tx.Delete(ctx, key)
+		if err := tx.Commit(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Snapshot retains the history of all keys from version 3.
+	mustSet(ctx, t, db, "a", "1") // 1
+	mustSet(ctx, t, db, "b", "1") // 2
+	mustSet(ctx, t, db, "c", "1") // 3
+	snap, _ := db.NewSnapshot(ctx)
+	defer snap.Discard(ctx)
+
+	deleteKey("b")                // 4
+	deleteKey("a")                // 5
+	mustSet(ctx, t, db, "a", "2") // 6, recreates a
+	deleteKey("a")                // 7
+
+	got, err := deletedSince(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Deletions are reported even when the key is recreated later.
+	if want := []string{"b@4", "a@5", "a@7"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("DeletedSince(3) = %q, want %q", got, want)
+	}
+	if got, _ := deletedSince(5); !reflect.DeepEqual(got, []string{"a@7"}) {
+		t.Errorf("DeletedSince(5) = %q, want [a@7]", got)
+	}
+	if got, _ := deletedSince(7); len(got) != 0 {
+		t.Errorf("DeletedSince(7) = %q, want none", got)
+	}
+
+	// History is compacted after the snapshot is discarded.
+	snap.Discard(ctx)
+	mustSet(ctx, t, db, "a", "3")
+	if _, err := deletedSince(3); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("DeletedSince(3) after compaction = %v, want an ErrCompacted", err)
+	} else {
+		var cerr *ErrCompacted
+		if !errors.As(err, &cerr) || cerr.Version != 3 || cerr.Retained <= 3 {
+			t.Errorf("DeletedSince(3) error = %#v, want an *ErrCompacted with the retained version", err)
+		}
+	}
+	if got, err := deletedSince(8); err != nil || len(got) != 0 {
+		t.Errorf("DeletedSince(8) = %q, %v, want no deletions", got, err)
+	}
+
+	// Blind deletion of a missing key is also a deletion.
+	tx, _ := db.NewTransaction(ctx)
+	tx.Set(ctx, "d", strings.NewReader("1"))
+	tx.Delete(ctx, "missing")
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := deletedSince(8); err != nil || !reflect.DeepEqual(got, []string{"missing@9"}) {
+		t.Errorf("DeletedSince(8) = %q, %v, want [missing@9]", got, err)
+	}
+}
+
+func TestCompactStraddlingTombstone(t *testing.T) {
+	ctx := context.Background()
+
+	db := New()
+	deleteKey := func(key string) {
+		tx, _ := db.NewTransaction(ctx)
+		tx.Delete(ctx, key)
+		if err := tx.Commit(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	observe := func(snap *Snapshot) string {
+		var entries []string
+		for key, e := range snap.ScanAll(ctx) {
+			if e.Deleted {
+				entries = append(entries, fmt.Sprintf("%s@%d:deleted", key, e.Version))
+			} else {
+				entries = append(entries, fmt.Sprintf("%s@%d:%s", key, e.Version, e.Value))
+			}
+		}
+		for key := range snap.Tombstones(ctx, "", "") {
+			entries = append(entries, "tombstone:"+key)
+		}
+		if _, err := snap.Get(ctx, "key"); err == nil {
+			entries = append(entries, "get:ok")
+		} else if errors.Is(err, os.ErrNotExist) {
+			entries = append(entries, "get:missing")
+		}
+		return strings.Join(entries, " ")
+	}
+
+	mustSet(ctx, t, db, "key", "v1") // 1
+	before, _ := db.NewSnapshot(ctx)
+	defer before.Discard(ctx)
+	deleteKey("key") // 2
+	deleted, _ := db.NewSnapshot(ctx)
+	defer deleted.Discard(ctx)
+	mustSet(ctx, t, db, "key", "v3") // 3
+	after, _ := db.NewSnapshot(ctx)
+	defer after.Discard(ctx)
+
+	want := map[*Snapshot]string{
+		before:  "key@1:v1 get:ok",
+		deleted: "key@2:deleted tombstone:key get:missing",
+		after:   "key@3:v3 get:ok",
+	}
+	for snap, w := range want {
+		if got := observe(snap); got != w {
+			t.Errorf("snapshot at %d observes %q, want %q", snap.Version(), got, w)
+		}
+	}
+
+	// Compaction with the snapshots after the first version retains the
+	// deletion, and the snapshots observe the same state as before.
+	before.Discard(ctx)
+	mustSet(ctx, t, db, "key", "v4") // 4
+	if got := observe(deleted); got != want[deleted] {
+		t.Errorf("snapshot at the deletion observes %q after compaction, want %q", got, want[deleted])
+	}
+	if got := observe(after); got != want[after] {
+		t.Errorf("snapshot after the recreation observes %q after compaction, want %q", got, want[after])
+	}
+
+	// Deletion is compacted once no snapshot can observe it.
+	deleted.Discard(ctx)
+	mustSet(ctx, t, db, "key", "v5") // 5
+	if got := observe(after); got != want[after] {
+		t.Errorf("snapshot after the recreation observes %q after the deletion is compacted, want %q", got, want[after])
+	}
+	versions, err := db.Versions(ctx, "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range versions {
+		if v.Deleted {
+			t.Errorf("deletion is retained after compaction: %v", versions)
+		}
+	}
+}
@@ -9,161 +9,919 @@ import (
 	"io"
 	"iter"
 	"log"
+	"maps"
 	"os"
 	"slices"
 	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/visvasity/kv"
 	"github.com/visvasity/kvmemdb/mvcc"
 )
 
+// ErrVersionMismatch is the error returned by SetWithVersion when the key is
+// not at the expected version.
+var ErrVersionMismatch = errors.New("version mismatch")
+
+// ErrNotDeleted is the error returned by Undelete when the key is not deleted.
+var ErrNotDeleted = errors.New("key is not deleted")
+
+// TxState represents the lifecycle state of a transaction.
+type TxState int
+
+const (
+	// TxActive is the state of a transaction that is not yet committed or
+	// rolled back.
+	TxActive TxState = iota
+
+	// TxCommitted is the state of a successfully committed transaction.
+	TxCommitted
+
+	// TxRolledBack is the state of a transaction that is rolled back or has
+	// failed to commit.
+	TxRolledBack
+)
+
+func (s TxState) String() string {
+	switch s {
+	case TxActive:
+		return "active"
+	case TxCommitted:
+		return "committed"
+	case TxRolledBack:
+		return "rolled-back"
+	}
+	return fmt.Sprintf("TxState(%d)", int(s))
+}
+
+// TxOptions holds the options for creating a transaction. Zero value holds
+// the default options.
+type TxOptions struct {
+	// DisableReadTracking, when true, stops recording the keys read by the
+	// transaction, which behaves like a Snapshot Isolation transaction: reads
+	// never cause conflicts, but all updates, including the blind writes, are
+	// checked for write-write conflicts with the concurrent transactions.
+	//
+	// It is meant for read-heavy transactions with a few writes that do not
+	// depend on the values read. Updates from other transactions can still
+	// fail to commit when they overwrite the keys read by them and updated by
+	// this transaction. Range reads through CountPhantomSafe are not tracked
+	// either.
+	DisableReadTracking bool
+
+	// ReportAllConflicts, when true, checks the transaction against all
+	// committed concurrent transactions at commit time instead of stopping at
+	// the first conflict, and reports all conflicting keys in the sorted order,
+	// so that the commit error is the same irrespective of the order in which
+	// the concurrent transactions are checked. It is meant for debugging and
+	// testing, because every commit performs all checks.
+	ReportAllConflicts bool
+
+	// IgnoreReadConflictKeys and IgnoreReadConflictPrefixes hold the keys, and
+	// the prefixes of the keys, whose reads are served from the transaction's
+	// snapshot but are not recorded in the read set, so that concurrent
+	// updates to them never conflict with this transaction. It is meant for
+	// hot keys whose values are known to be benign for the transaction, e.g.,
+	// a clock or a statistics counter.
+	//
+	// WARNING: This weakens the Serializable Snapshot Isolation guarantees for
+	// the ignored keys, like Forget. Updates that depend on the values read
+	// for the ignored keys can commit on top of stale values, which allows
+	// write skew and, for the ignored keys also updated by the transaction,
+	// lost updates, because their updates are treated as blind writes. Range
+	// reads through CountPhantomSafe still cover the ignored keys.
+	IgnoreReadConflictKeys     []string
+	IgnoreReadConflictPrefixes []string
+
+	// IdempotencyKey, when non-empty, identifies the logical update performed
+	// by the transaction, e.g., a client request id, so that retries of the
+	// update are committed at most once. When a transaction with the same key
+	// has committed within the WithIdempotencyWindow duration, the commit is
+	// a no-op that succeeds without any checks, and CommitVersion reports the
+	// version of the earlier commit. Callers can wait for that version with
+	// Database.WaitForVersion to observe the earlier updates. Failed commits
+	// do not record their keys.
+	IdempotencyKey string
+}
+
+var _ kv.Transaction = &Transaction{}
+
 type Transaction struct {
 	db *Database
 
+	// id is a unique number assigned to the transaction by the database.
+	id uint64
+
+	// state holds the lifecycle state of the transaction.
+	state TxState
+
 	// snapshotVersion is the max version number readable by this
 	// transaction. This is also the maxCommitVersion of the database at the
 	// creation of this transaction. Multiple transactions can exist with the
 	// same snapshotVersion value.
 	snapshotVersion int64
 
+	// group is non-nil for transactions created by a transaction group.
+	group *TransactionGroup
+
+	// pinned is true for transactions created at a version older than the
+	// maxCommitVersion, by a transaction group or by upgrading a snapshot.
+	// Commits after their version are not tracked as concurrent transactions,
+	// so their reads are validated against the current database state.
+	pinned bool
+
+	// created holds the database clock time at the creation of this
+	// transaction.
+	created time.Time
+
+	// trackReads is false if the read set is not maintained for the
+	// transaction.
+	trackReads bool
+
+	// reportAllConflicts is true if the commit reports all conflicting keys
+	// instead of the first conflict.
+	reportAllConflicts bool
+
+	// idempotencyKey, when non-empty, identifies the update performed by the
+	// transaction, which is committed at most once per idempotency window.
+	idempotencyKey string
+
+	// ignoredKeys and ignoredPrefixes hold the keys whose reads are not
+	// recorded in the read set.
+	ignoredKeys     map[string]struct{}
+	ignoredPrefixes []string
+
+	// aborted flag is set when the transaction is aborted by the database
+	// through AbortTransaction, possibly from a different goroutine.
+	aborted atomic.Bool
+
+	// commitVersion holds the version of the database state that includes the
+	// transaction's updates, once it is committed.
+	commitVersion int64
+
 	// committed flag is set to true when tx is committed. It remains false when
 	// tx live or if it is aborted.
 	committed bool
 
 	// reads map holds all key-value pairs read by this transaction. Updates to
 	// these key-value pairs will *move* the entry to the following 'writes' map.
+	// A nil value represents a key that did not exist at the snapshotVersion.
 	reads map[string]*mvcc.Value
 
 	// writes map holds all updates performed by this transaction. A nil string
 	// value for a key represents a deleted key.
 	writes map[string]*string
+
+	// numReads and numWrites hold the sizes of the read and write sets, so
+	// that they can be inspected from other goroutines.
+	numReads, numWrites atomic.Int64
+
+	// stages holds the number of times each key in the writes map is staged.
+	stages map[string]int
+
+	// sequences holds the counter keys used with NextSequence, which are
+	// updated at commit time without the conflict checks.
+	sequences map[string]struct{}
+
+	// dropped holds the values of the deleted keys removed by the compaction
+	// while applying the transaction's updates, which are reported to the
+	// OnEvict function after the commit.
+	dropped []droppedEntry
+
+	// scanRanges holds the key ranges whose contents were observed as a whole
+	// by this transaction. Updates to any key in these ranges by concurrent
+	// transactions, including the creation of new keys, are conflicts.
+	scanRanges []Range
+
+	// lockedRanges holds the key ranges locked with LockRange. Creation of new
+	// keys in these ranges by concurrent transactions are conflicts.
+	lockedRanges []Range
+
+	// invalidations, when non-nil, receives the keys in the read set that are
+	// updated by concurrent commits. It is set and closed under the database
+	// mutex.
+	invalidations chan string
+
+	// invalidationsClosed is set when the invalidations channel is closed, so
+	// that the commits of the concurrent transactions stop sending to it. It is
+	// protected by the database mutex.
+	invalidationsClosed bool
+
+	// invalidationsDropped flag is set when a notification is dropped because
+	// the invalidations channel is full.
+	invalidationsDropped atomic.Bool
+
+	// watched mirrors the read set for transactions subscribed for
+	// invalidations, so that it can be inspected by the committing
+	// transactions. It is protected by the watchMu.
+	watchMu sync.Mutex
+	watched map[string]struct{}
 }
 
 // Set creates or updates a key-value pair in the database. The input key
 // cannot be empty and input value cannot be nil.
+//
+// Value is read in full before Set returns. Input context, or the default
+// timeout of the database when the context has no deadline, bounds the time
+// spent reading the value.
 func (t *Transaction) Set(ctx context.Context, key string, value io.Reader) error {
-	if len(key) == 0 || value == nil {
+	if err := t.check(); err != nil {
+		return err
+	}
+	if value == nil {
 		return os.ErrInvalid
 	}
+	if err := t.db.checkKey(key); err != nil {
+		return err
+	}
+
+	ctx, cancel := t.db.withDefaultTimeout(ctx)
+	defer cancel()
 
-	data, err := io.ReadAll(value)
+	if t.db.chunkSize > 0 {
+		chunks, err := readChunks(ctx, value, t.db.chunkSize)
+		if err != nil {
+			return err
+		}
+		t.setChunked(key, chunks)
+		return nil
+	}
+
+	data, err := readValue(ctx, value)
 	if err != nil {
 		return err
 	}
 
 	s := string(data)
-	t.writes[key] = &s
+	t.stage(key, &s)
 	return nil
 }
 
+// stage records an update to the key in the write set. A nil value stages a
+// deletion.
+func (t *Transaction) stage(key string, value *string) {
+	t.writes[key] = value
+	if t.stages[key]++; t.stages[key] == 1 {
+		t.numWrites.Add(1)
+	}
+	if obs := t.db.stagingObserver; obs != nil && t.stages[key] == t.db.stagingLimit+1 {
+		obs(t.id, key, t.stages[key])
+	}
+}
+
 // Delete removes the input key and the associated value. Returns nil even when
 // the input key doesn't exist.
 func (t *Transaction) Delete(ctx context.Context, key string) error {
-	if len(key) == 0 {
-		return os.ErrInvalid
+	if err := t.check(); err != nil {
+		return err
+	}
+	if err := t.db.checkKey(key); err != nil {
+		return err
 	}
 
-	t.writes[key] = nil
+	t.deleteData(key)
 	return nil
 }
 
 // Get returns the value associated with the input key. Returns os.ErrNotExist
 // if key was deleted or doesn't exist.
 func (t *Transaction) Get(ctx context.Context, key string) (io.Reader, error) {
-	if len(key) == 0 {
-		return nil, os.ErrInvalid
+	if err := t.check(); err != nil {
+		return nil, err
+	}
+	if err := t.db.checkKeySize(key); err != nil {
+		return nil, err
 	}
 
+	data, err := t.getStored(key)
+	if err != nil {
+		return nil, err
+	}
+	if m, ok := t.db.parseManifest(data); ok {
+		chunks, err := t.chunks(key, m)
+		if err != nil {
+			return nil, err
+		}
+		return newChunkReader(m, chunks), nil
+	}
+	return strings.NewReader(data), nil
+}
+
+// get returns the value visible to the transaction for the input key and
+// records the key in the read set. Chunked values are stitched together.
+func (t *Transaction) get(key string) (string, error) {
+	data, err := t.getStored(key)
+	if err != nil {
+		return "", err
+	}
+	if m, ok := t.db.parseManifest(data); ok {
+		chunks, err := t.chunks(key, m)
+		if err != nil {
+			return "", err
+		}
+		return strings.Join(chunks, ""), nil
+	}
+	return data, nil
+}
+
+// getStored is similar to get, but returns the manifest for the chunked
+// values.
+func (t *Transaction) getStored(key string) (string, error) {
 	if v, ok := t.writes[key]; ok {
 		if v == nil {
-			return nil, fmt.Errorf("key %s is deleted by this tx: %w", key, os.ErrNotExist)
+			return "", fmt.Errorf("key %s is deleted by this tx: %w", key, os.ErrNotExist)
+		}
+		return *v, nil
+	}
+
+	v := t.fetch(key)
+	if v == nil {
+		return "", fmt.Errorf("key %s does not exist in the db: %w", key, os.ErrNotExist)
+	}
+	if v.IsDeleted() {
+		return "", fmt.Errorf("key %s is deleted at this tx read version: %w", key, os.ErrNotExist)
+	}
+	t.db.touchRead(key)
+	return v.Data(), nil
+}
+
+// fetch returns the value of the key at the transaction's snapshot version
+// and records it in the read set, if read tracking is enabled. Returns nil if
+// the key doesn't exist.
+func (t *Transaction) fetch(key string) *mvcc.Value {
+	if !t.trackReads || t.ignoredRead(key) {
+		return t.db.fetch(key, t.snapshotVersion)
+	}
+
+	v, ok := t.reads[key]
+	if !ok {
+		// Absent and deleted keys are also recorded in the read set, so that
+		// concurrent transactions creating the key are detected as conflicts.
+		v = t.db.fetch(key, t.snapshotVersion)
+		t.reads[key] = v
+		t.numReads.Add(1)
+		t.watch(key)
+	}
+	return v
+}
+
+// ignoredRead returns true if the reads of the key are not recorded in the
+// read set, because of the IgnoreReadConflictKeys or the
+// IgnoreReadConflictPrefixes options.
+func (t *Transaction) ignoredRead(key string) bool {
+	if _, ok := t.ignoredKeys[key]; ok {
+		return true
+	}
+	for _, prefix := range t.ignoredPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetVersion returns the commit version of the key's value visible to the
+// transaction. Updates staged by the transaction are not reflected. Returns
+// os.ErrNotExist if key was deleted or doesn't exist. Key is recorded in the
+// read set of the transaction.
+func (t *Transaction) GetVersion(ctx context.Context, key string) (int64, error) {
+	if err := t.check(); err != nil {
+		return 0, err
+	}
+	if err := t.db.checkKeySize(key); err != nil {
+		return 0, err
+	}
+
+	v := t.fetch(key)
+	if v == nil || v.IsDeleted() {
+		return 0, fmt.Errorf("key %s does not exist at this tx read version: %w", key, os.ErrNotExist)
+	}
+	return v.Version(), nil
+}
+
+// SetWithVersion updates the key only if the commit version of its value
+// visible to the transaction is equal to the expected version, which can be
+// zero for keys that must not exist. Returns an error wrapping
+// ErrVersionMismatch if the versions differ.
+//
+// Unlike comparing the values, comparing the versions doesn't need to read
+// the values. Key is recorded in the read set of the transaction, so the
+// commit fails if the key is updated concurrently after the check.
+func (t *Transaction) SetWithVersion(ctx context.Context, key string, value io.Reader, expectedVersion int64) error {
+	version, err := t.GetVersion(ctx, key)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if version != expectedVersion {
+		return fmt.Errorf("key %s is at version %d, want %d: %w", key, version, expectedVersion, ErrVersionMismatch)
+	}
+	return t.Set(ctx, key, value)
+}
+
+// SetIfAbsent stages the value for the key only if the key doesn't exist in
+// the transaction's view, which includes the updates staged by the
+// transaction, and returns true if the value is staged. Existence check is
+// recorded in the read set of the transaction, so the commit fails if another
+// transaction creates the key concurrently.
+func (t *Transaction) SetIfAbsent(ctx context.Context, key string, value io.Reader) (bool, error) {
+	if err := t.check(); err != nil {
+		return false, err
+	}
+	if value == nil {
+		return false, os.ErrInvalid
+	}
+	if err := t.db.checkKey(key); err != nil {
+		return false, err
+	}
+
+	if _, err := t.getStored(key); err == nil {
+		return false, nil
+	}
+	if err := t.Set(ctx, key, value); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// GetAndDelete returns the value associated with the input key and stages
+// the key for deletion. Key is recorded in the read set of the transaction,
+// so when multiple transactions delete the same key, only the first one to
+// commit succeeds. Returns os.ErrNotExist if key was deleted or doesn't exist,
+// in which case no deletion is staged.
+func (t *Transaction) GetAndDelete(ctx context.Context, key string) (io.Reader, error) {
+	if err := t.check(); err != nil {
+		return nil, err
+	}
+	if err := t.db.checkKey(key); err != nil {
+		return nil, err
+	}
+
+	data, err := t.get(key)
+	if err != nil {
+		return nil, err
+	}
+	t.deleteData(key)
+	return strings.NewReader(data), nil
+}
+
+// Update reads the current value of the input key and replaces it with the
+// value returned by the input function. Function receives a nil slice if the
+// key doesn't exist and an empty, non-nil slice if the key holds an empty
+// value. If the function returns a nil slice, key is deleted.
+//
+// Key is recorded in the read set of the transaction, so concurrent updates to
+// the key are detected as conflicts at commit time. If the function returns a
+// non-nil error, no update is staged and the error is returned as is.
+func (t *Transaction) Update(ctx context.Context, key string, fn func(old []byte) ([]byte, error)) error {
+	if err := t.check(); err != nil {
+		return err
+	}
+	if err := t.db.checkKey(key); err != nil {
+		return err
+	}
+
+	var old []byte
+	value, err := t.Get(ctx, key)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	} else {
+		if old, err = io.ReadAll(value); err != nil {
+			return err
+		}
+	}
+
+	data, err := fn(old)
+	if err != nil {
+		return err
+	}
+	if data == nil {
+		return t.Delete(ctx, key)
+	}
+
+	t.setData(key, string(data))
+	return nil
+}
+
+// Undelete stages the newest value of a deleted key that is still retained
+// by the database, at or before the transaction's snapshot version, as a new
+// value for the key. Restored value is committed as a normal update and the
+// key is recorded in the read set of the transaction, so concurrent updates to
+// the key are detected as conflicts.
+//
+// Returns an error wrapping ErrNotDeleted if the key is not deleted, which
+// includes the keys updated by this transaction. Returns os.ErrNotExist if no
+// older value of the key is retained, because the key never existed or its
+// older values are compacted.
+func (t *Transaction) Undelete(ctx context.Context, key string) error {
+	if err := t.check(); err != nil {
+		return err
+	}
+	if err := t.db.checkKey(key); err != nil {
+		return err
+	}
+
+	if v, ok := t.writes[key]; ok && v != nil {
+		return fmt.Errorf("key %s is updated by this tx: %w", key, ErrNotDeleted)
+	}
+	if v := t.fetch(key); v != nil && !v.IsDeleted() {
+		if _, ok := t.writes[key]; !ok {
+			return fmt.Errorf("key %s exists at this tx read version: %w", key, ErrNotDeleted)
+		}
+	}
+
+	v := t.db.lastLiveValue(key, t.snapshotVersion)
+	if v == nil {
+		return fmt.Errorf("key %s has no retained value to restore: %w", key, os.ErrNotExist)
+	}
+	data := v.Data()
+	if m, ok := t.db.parseManifest(data); ok {
+		if err := t.restoreChunks(key, m, v.Version()); err != nil {
+			return err
 		}
-		return strings.NewReader(*v), nil
 	}
+	t.stage(key, &data)
+	return nil
+}
+
+// Forget removes the input key from the transaction's read set, so that
+// updates to the key by other transactions do not conflict with this
+// transaction. Returns os.ErrInvalid if the key is updated by this
+// transaction.
+//
+// WARNING: Forget is an escape hatch that weakens the Serializable Snapshot
+// Isolation guarantees. Callers must ensure that no updates performed by this
+// transaction depend on the value that was read for the key.
+func (t *Transaction) Forget(ctx context.Context, key string) error {
+	if err := t.check(); err != nil {
+		return err
+	}
+	if err := t.db.checkKeySize(key); err != nil {
+		return err
+	}
+	if _, ok := t.writes[key]; ok {
+		return fmt.Errorf("key %s is updated by this tx: %w", key, os.ErrInvalid)
+	}
+	if _, ok := t.reads[key]; ok {
+		delete(t.reads, key)
+		t.unwatch(key)
+		t.numReads.Add(-1)
+	}
+	return nil
+}
 
-	if v, ok := t.reads[key]; ok {
-		return strings.NewReader(v.Data()), nil
+// CountPhantomSafe returns the number of keys visible to the transaction in
+// the [begin, end) range. The whole range is recorded in the read set of the
+// transaction, so the commit fails if a concurrent transaction creates,
+// updates or deletes any key in the range. This makes "count and then insert"
+// patterns safe from phantoms.
+func (t *Transaction) CountPhantomSafe(ctx context.Context, begin, end string) (int64, error) {
+	if err := t.check(); err != nil {
+		return 0, err
+	}
+	if err := checkRange(begin, end); err != nil {
+		return 0, err
 	}
 
-	if mv, ok := t.db.kvs.Load(key); ok {
-		if v, ok := mv.Fetch(t.snapshotVersion); ok {
-			if v.IsDeleted() {
-				return nil, fmt.Errorf("key %s is deleted at this tx read version: %w", key, os.ErrNotExist)
+	var n int64
+	for key := range t.keySeq(begin, end) {
+		if _, err := t.get(key); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
 			}
-			t.reads[key] = v
-			return strings.NewReader(v.Data()), nil
+			return 0, err
 		}
+		n++
 	}
-	return nil, fmt.Errorf("key %s does not exist in the db: %w", key, os.ErrNotExist)
+	if t.trackReads {
+		t.scanRanges = append(t.scanRanges, Range{Begin: begin, End: end})
+	}
+	return n, nil
+}
+
+// LockRange takes an advisory lock on the [begin, end) range against the
+// phantom inserts: the commit fails if a concurrent transaction creates any
+// key in the range after the transaction's snapshot version, which includes
+// the keys that were deleted at that version. Unlike CountPhantomSafe, updates
+// and deletions of the existing keys in the range are not conflicts and the
+// lock doesn't need the read tracking, so it is cheaper to validate.
+func (t *Transaction) LockRange(ctx context.Context, begin, end string) error {
+	if err := t.check(); err != nil {
+		return err
+	}
+	if err := checkRange(begin, end); err != nil {
+		return err
+	}
+	t.lockedRanges = append(t.lockedRanges, Range{Begin: begin, End: end})
+	return nil
+}
+
+// CopyRange copies all key-value pairs visible to the transaction in the
+// [srcBegin, srcEnd) range to new keys under the dstPrefix and returns the
+// number of key-value pairs copied. New keys are formed by replacing the
+// srcBegin prefix of the source keys with the dstPrefix; source keys without
+// the srcBegin prefix are appended to the dstPrefix as they are.
+//
+// All source keys are recorded in the read set of the transaction, so the
+// copy conflicts with concurrent updates to the source keys.
+func (t *Transaction) CopyRange(ctx context.Context, srcBegin, srcEnd, dstPrefix string) (int, error) {
+	return t.copyRange(ctx, srcBegin, srcEnd, dstPrefix, false /* move */)
+}
+
+// MoveRange is similar to CopyRange, but also deletes the source keys that are
+// not overwritten by the copy. All updates are committed atomically with the
+// transaction.
+func (t *Transaction) MoveRange(ctx context.Context, srcBegin, srcEnd, dstPrefix string) (int, error) {
+	return t.copyRange(ctx, srcBegin, srcEnd, dstPrefix, true /* move */)
+}
+
+func (t *Transaction) copyRange(ctx context.Context, srcBegin, srcEnd, dstPrefix string, move bool) (int, error) {
+	type pair struct {
+		src, dst string
+		data     string
+	}
+
+	var pairs []pair
+	var err error
+	for key, value := range t.Ascend(ctx, srcBegin, srcEnd, &err) {
+		data, err := io.ReadAll(value)
+		if err != nil {
+			return 0, err
+		}
+		dst := dstPrefix + strings.TrimPrefix(key, srcBegin)
+		if err := t.db.checkKey(dst); err != nil {
+			return 0, err
+		}
+		pairs = append(pairs, pair{src: key, dst: dst, data: string(data)})
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	// Source keys are deleted before the copies are staged, so that copies
+	// overwriting other source keys are preserved.
+	if move {
+		for _, p := range pairs {
+			t.deleteData(p.src)
+		}
+	}
+	for _, p := range pairs {
+		t.setData(p.dst, p.data)
+	}
+	return len(pairs), nil
 }
 
 // keys returns all keys between the [begin, end) range in no-specific order.
 func (t *Transaction) keys(begin, end string) []string {
-	kset := make(map[string]struct{})
+	return slices.Collect(t.keySeq(begin, end))
+}
+
+// keySeq returns an iterator over all keys between the [begin, end) range in
+// no-specific order. Keys from the transaction's read and write sets are
+// collected upfront, but the database keys are enumerated lazily, so that
+// loops exiting early do not pay for the whole key space.
+func (t *Transaction) keySeq(begin, end string) iter.Seq[string] {
+	r := Range{Begin: begin, End: end}
+
+	// Read and write sets are copied because they may be updated by the
+	// callers while the keys are yielded.
+	local := make(map[string]struct{}, len(t.reads)+len(t.writes))
 	for k := range t.reads {
-		kset[k] = struct{}{}
+		local[k] = struct{}{}
 	}
 	for k := range t.writes {
-		kset[k] = struct{}{}
+		local[k] = struct{}{}
 	}
-	for k := range t.db.kvs.Range {
-		if _, ok := kset[k]; !ok {
-			kset[k] = struct{}{}
+
+	return func(yield func(string) bool) {
+		for k := range local {
+			if r.contains(k) && !t.db.hiddenKey(k) && !yield(k) {
+				return
+			}
+		}
+		for k := range t.db.prefixKeys(rangePrefix(begin, end)) {
+			if _, ok := local[k]; ok || !r.contains(k) || t.db.hiddenKey(k) {
+				continue
+			}
+			if !yield(k) {
+				return
+			}
 		}
 	}
+}
+
+// TxStats holds the counters for the operations performed by a transaction.
+type TxStats struct {
+	// Reads is the number of keys in the read set.
+	Reads int
+
+	// Writes is the number of keys in the write set.
+	Writes int
+
+	// Stages is the number of updates staged, including the ones that were
+	// overwritten later.
+	Stages int
+
+	// Overwrites is the number of staged updates that replaced an earlier
+	// update to the same key.
+	Overwrites int
+}
 
-	keys := make([]string, 0, len(kset))
-	for k := range kset {
-		keys = append(keys, k)
+// Stats returns the operation counters of the transaction.
+func (t *Transaction) Stats() TxStats {
+	stats := TxStats{
+		Reads:  len(t.reads),
+		Writes: len(t.writes),
+	}
+	for _, n := range t.stages {
+		stats.Stages += n
+		stats.Overwrites += n - 1
 	}
+	return stats
+}
 
-	keys = slices.DeleteFunc(keys, func(k string) bool {
-		if begin == "" && end == "" {
-			return false
-		}
-		if begin != "" && end == "" {
-			return k < begin
-		}
-		if begin == "" && end != "" {
-			return k >= end
+// PendingWrite describes an update staged by a transaction.
+type PendingWrite struct {
+	// Value holds the staged value. It is nil for deletions.
+	Value []byte
+
+	// Deleted is true if the key is staged for deletion.
+	Deleted bool
+
+	// Stages is the number of times the key was staged in the transaction.
+	Stages int
+}
+
+// PendingWrites returns an iterator over the updates staged by the
+// transaction, in the ascending key order.
+func (t *Transaction) PendingWrites() iter.Seq2[string, PendingWrite] {
+	return func(yield func(string, PendingWrite) bool) {
+		keys := slices.Sorted(maps.Keys(t.writes))
+		for _, key := range keys {
+			w := PendingWrite{Stages: t.stages[key]}
+			if v := t.writes[key]; v == nil {
+				w.Deleted = true
+			} else {
+				w.Value = []byte(*v)
+			}
+			if !yield(key, w) {
+				return
+			}
 		}
-		return k < begin || k >= end
-	})
+	}
+}
 
-	return keys
+// State returns the lifecycle state of the transaction.
+func (t *Transaction) State() TxState {
+	if t.state == TxActive && t.aborted.Load() {
+		return TxRolledBack
+	}
+	return t.state
+}
+
+// CommitVersion returns the database version that includes the updates of
+// the committed transaction, which can be passed to Database.WaitForVersion
+// by other goroutines to read them. Read-only transactions report their
+// snapshot version. Returns zero if the transaction is not committed.
+func (t *Transaction) CommitVersion() int64 {
+	if t.state != TxCommitted {
+		return 0
+	}
+	return t.commitVersion
+}
+
+// SnapshotVersion returns the database version read by the transaction,
+// which is the version of the last transaction committed before the
+// transaction is created. Commits of other transactions after this version
+// are not visible to the transaction. It is comparable with the commit
+// versions and the snapshot versions from the same database.
+func (t *Transaction) SnapshotVersion() int64 {
+	return t.snapshotVersion
+}
+
+// ID returns the unique id assigned to the transaction by the database.
+func (t *Transaction) ID() uint64 {
+	return t.id
+}
+
+// String returns a short description of the transaction for debugging, e.g.,
+// "Transaction{id: 7, version: 42, reads: 3, writes: 7, committed: false}".
+func (t *Transaction) String() string {
+	return fmt.Sprintf("Transaction{id: %d, version: %d, reads: %d, writes: %d, committed: %t}",
+		t.id, t.snapshotVersion, t.numReads.Load(), t.numWrites.Load(), t.committed)
+}
+
+// check returns a non-nil error wrapping os.ErrClosed if the transaction is
+// already committed, rolled back or aborted, or wrapping ErrCorrupted if the
+// database is corrupted.
+func (t *Transaction) check() error {
+	switch t.state {
+	case TxCommitted:
+		return fmt.Errorf("tx is already committed: %w", os.ErrClosed)
+	case TxRolledBack:
+		return fmt.Errorf("tx is already rolled back: %w", os.ErrClosed)
+	}
+	if t.aborted.Load() {
+		return fmt.Errorf("tx %d: %w", t.id, ErrAborted)
+	}
+	if t.db != nil {
+		return t.db.checkPoisoned()
+	}
+	return nil
 }
 
 // Commit attempts to save all updates performed by the transaction to the
 // database. Returns nil on success. Transaction is effectively destroyed
 // irrespective of the result and no operations should be performed any
-// further.
+// further. Transaction state is TxRolledBack if the commit has failed.
+//
+// Returns an error wrapping os.ErrClosed if the transaction is already
+// committed or rolled back, and an *ErrConflict error if it conflicts with
+// the committed transactions.
+//
+// Input context bounds the time spent waiting behind other commits. An error
+// wrapping the context error is returned, without applying any updates, if
+// the context is canceled before the transaction is validated. Updates are
+// always applied in full once the validation succeeds.
 func (t *Transaction) Commit(ctx context.Context) error {
-	if t.db == nil {
-		return os.ErrInvalid
+	if err := t.check(); err != nil {
+		return err
 	}
-	defer t.db.closeTransaction(t)
+	db := t.db
+	ctx, cancel := db.withDefaultTimeout(ctx)
+	defer cancel()
 
-	if err := commit(t.db, t); err != nil {
+	if err := commit(ctx, db, t); err != nil {
+		t.state = TxRolledBack
+		if ctx.Err() != nil {
+			// The database mutex may still be held by the commits that this
+			// transaction was waiting behind, so the transaction is removed
+			// from the database in the background.
+			go db.closeTransaction(t)
+			return err
+		}
+		db.closeTransaction(t)
 		return err
 	}
+	t.state = TxCommitted
+	db.closeTransaction(t)
 	return nil
 }
 
+// CommitWithDeadline is similar to Commit, but gives up waiting behind other
+// commits after the duration d, with an error wrapping
+// context.DeadlineExceeded. Commit latency is bounded by d, plus the time to
+// apply the updates once the transaction is validated.
+//
+// Timed out transactions are rolled back, so a deferred Rollback remains
+// safe.
+func (t *Transaction) CommitWithDeadline(ctx context.Context, d time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, d)
+	defer cancel()
+	return t.Commit(ctx)
+}
+
 // Rollback drops all updates performed by the transaction. Transaction is
 // effectively destroyed and no operations should be performed any further.
+//
+// Rollback is a no-op if the transaction is already committed or rolled back,
+// so that it can always be deferred right after creating the transaction.
 func (t *Transaction) Rollback(ctx context.Context) error {
-	if t.db == nil {
-		return os.ErrInvalid
+	if t.state != TxActive {
+		return nil
+	}
+	t.state = TxRolledBack
+	if !t.aborted.Load() {
+		t.db.closeTransaction(t)
 	}
-	t.db.closeTransaction(t)
 	return nil
 }
 
+// Discard is same as Rollback. It allows releasing transactions and snapshots
+// through the common Discarder interface.
+func (t *Transaction) Discard(ctx context.Context) error {
+	return t.Rollback(ctx)
+}
+
 // Scan implements kv.Scanner interface to range over all key-value pairs in
-// the database.
+// the database. Keys are yielded in an undefined order, which can differ
+// between the scans; use Ascend or Descend for ordered scans.
+//
+// Returned iterator can be ranged over multiple times. Every range performs a
+// fresh scan and resets *errp to nil when it begins, so errors from an earlier
+// range do not leak into the later ones. This applies to all scan methods.
 func (t *Transaction) Scan(ctx context.Context, errp *error) iter.Seq2[string, io.Reader] {
 	return func(yield func(string, io.Reader) bool) {
-		for _, key := range t.keys("", "") {
+		*errp = nil
+		if err := t.check(); err != nil {
+			*errp = err
+			return
+		}
+		for key := range t.keySeq("", "") {
 			value, err := t.Get(ctx, key)
 			if err != nil {
 				if errors.Is(err, os.ErrNotExist) {
@@ -183,8 +941,13 @@ func (t *Transaction) Scan(ctx context.Context, errp *error) iter.Seq2[string, i
 // 'begin' and 'end' keys in the database in ascending order.
 func (t *Transaction) Ascend(ctx context.Context, begin, end string, errp *error) iter.Seq2[string, io.Reader] {
 	return func(yield func(string, io.Reader) bool) {
-		if begin != "" && end != "" && begin > end {
-			*errp = os.ErrInvalid
+		*errp = nil
+		if err := t.check(); err != nil {
+			*errp = err
+			return
+		}
+		if err := checkRange(begin, end); err != nil {
+			*errp = err
 			return
 		}
 
@@ -212,8 +975,13 @@ func (t *Transaction) Ascend(ctx context.Context, begin, end string, errp *error
 // 'begin' and 'end' keys in the database in descending order.
 func (t *Transaction) Descend(ctx context.Context, begin, end string, errp *error) iter.Seq2[string, io.Reader] {
 	return func(yield func(string, io.Reader) bool) {
-		if begin != "" && end != "" && begin > end {
-			*errp = os.ErrInvalid
+		*errp = nil
+		if err := t.check(); err != nil {
+			*errp = err
+			return
+		}
+		if err := checkRange(begin, end); err != nil {
+			*errp = err
 			return
 		}
 
@@ -237,3 +1005,81 @@ func (t *Transaction) Descend(ctx context.Context, begin, end string, errp *erro
 		}
 	}
 }
+
+// ScanPrefix is similar to Ascend, but ranges over the key-value pairs with
+// the input prefix in ascending order.
+func (t *Transaction) ScanPrefix(ctx context.Context, prefix string, errp *error) iter.Seq2[string, io.Reader] {
+	return t.Ascend(ctx, prefix, prefixEnd(prefix), errp)
+}
+
+// AscendFilter is similar to Ascend, but only yields the key-value pairs for
+// which the keep function returns true. Readers are not created for the
+// rejected key-value pairs.
+//
+// All keys visited in the range are recorded in the read set of the
+// transaction, including the rejected keys, because the filtering decision
+// depends on their values.
+func (t *Transaction) AscendFilter(ctx context.Context, begin, end string, keep func(key string, value []byte) bool, errp *error) iter.Seq2[string, io.Reader] {
+	return func(yield func(string, io.Reader) bool) {
+		*errp = nil
+		if err := t.check(); err != nil {
+			*errp = err
+			return
+		}
+		if err := checkRange(begin, end); err != nil {
+			*errp = err
+			return
+		}
+
+		keys := t.keys(begin, end)
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			data, err := t.get(key)
+			if err != nil {
+				if errors.Is(err, os.ErrNotExist) {
+					continue
+				}
+				*errp = err
+				return
+			}
+			if !keep(key, []byte(data)) {
+				continue
+			}
+			if !yield(key, strings.NewReader(data)) {
+				return
+			}
+		}
+	}
+}
+
+// Reader returns a read-only view of the transaction, which reflects the
+// updates staged by the transaction, but doesn't allow any further updates. It
+// can be passed to code that must not modify the database. Reads through the
+// view are part of the transaction and are validated when it commits.
+func (t *Transaction) Reader() kv.Reader {
+	return txReader{t: t}
+}
+
+var _ kv.Reader = txReader{}
+
+// txReader hides the update methods of a transaction.
+type txReader struct {
+	t *Transaction
+}
+
+func (r txReader) Get(ctx context.Context, key string) (io.Reader, error) {
+	return r.t.Get(ctx, key)
+}
+
+func (r txReader) Scan(ctx context.Context, errp *error) iter.Seq2[string, io.Reader] {
+	return r.t.Scan(ctx, errp)
+}
+
+func (r txReader) Ascend(ctx context.Context, begin, end string, errp *error) iter.Seq2[string, io.Reader] {
+	return r.t.Ascend(ctx, begin, end, errp)
+}
+
+func (r txReader) Descend(ctx context.Context, begin, end string, errp *error) iter.Seq2[string, io.Reader] {
+	return r.t.Descend(ctx, begin, end, errp)
+}
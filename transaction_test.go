@@ -0,0 +1,1006 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/visvasity/kv"
+)
+
+func TestForget(t *testing.T) {
+	ctx := context.Background()
+
+	db := New()
+	setup, _ := db.NewTransaction(ctx)
+	setup.Set(ctx, "key1", strings.NewReader("initial1"))
+	setup.Set(ctx, "key2", strings.NewReader("initial2"))
+	if err := setup.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	tx1, _ := db.NewTransaction(ctx)
+	defer tx1.Rollback(ctx)
+	tx2, _ := db.NewTransaction(ctx)
+	defer tx2.Rollback(ctx)
+
+	if _, err := tx1.Get(ctx, "key1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx1.Set(ctx, "key2", strings.NewReader("value2")); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx1.Forget(ctx, "key2"); !errors.Is(err, os.ErrInvalid) {
+		t.Fatalf("Forget on a written key: want os.ErrInvalid, got %v", err)
+	}
+	if err := tx1.Forget(ctx, "key1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tx2.Set(ctx, "key1", strings.NewReader("value1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx2.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Read of key1 is forgotten, so the concurrent update must not conflict.
+	if err := tx1.Commit(ctx); err != nil {
+		t.Fatalf("commit after Forget failed: %v", err)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	ctx := context.Background()
+
+	db := New()
+
+	increment := func(old []byte) ([]byte, error) {
+		if old == nil {
+			return []byte("1"), nil
+		}
+		n, err := strconv.Atoi(string(old))
+		if err != nil {
+			return nil, err
+		}
+		return []byte(strconv.Itoa(n + 1)), nil
+	}
+
+	for i := 0; i < 3; i++ {
+		tx, _ := db.NewTransaction(ctx)
+		if err := tx.Update(ctx, "counter", increment); err != nil {
+			t.Fatal(err)
+		}
+		if err := tx.Commit(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	snap, _ := db.NewSnapshot(ctx)
+	if v, err := snap.Get(ctx, "counter"); err != nil {
+		t.Fatal(err)
+	} else if data, _ := io.ReadAll(v); string(data) != "3" {
+		t.Errorf("counter = %s, want 3", data)
+	}
+	snap.Discard(ctx)
+
+	// Errors from the function abort staging.
+	errTest := errors.New("test error")
+	tx, _ := db.NewTransaction(ctx)
+	defer tx.Rollback(ctx)
+	if err := tx.Update(ctx, "counter", func([]byte) ([]byte, error) { return []byte("x"), errTest }); !errors.Is(err, errTest) {
+		t.Fatalf("want test error, got %v", err)
+	}
+	if v, err := tx.Get(ctx, "counter"); err != nil {
+		t.Fatal(err)
+	} else if data, _ := io.ReadAll(v); string(data) != "3" {
+		t.Errorf("counter = %s after failed update, want 3", data)
+	}
+
+	// Nil return value deletes the key.
+	if err := tx.Update(ctx, "counter", func([]byte) ([]byte, error) { return nil, nil }); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Get(ctx, "counter"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("want os.ErrNotExist, got %v", err)
+	}
+}
+
+func TestUpdateConflict(t *testing.T) {
+	ctx := context.Background()
+
+	db := New()
+
+	setOne := func([]byte) ([]byte, error) { return []byte("1"), nil }
+
+	// Both transactions observe the key as absent and create it.
+	tx1, _ := db.NewTransaction(ctx)
+	defer tx1.Rollback(ctx)
+	tx2, _ := db.NewTransaction(ctx)
+	defer tx2.Rollback(ctx)
+
+	if err := tx1.Update(ctx, "key", setOne); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx2.Update(ctx, "key", setOne); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx1.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx2.Commit(ctx); err == nil {
+		t.Fatalf("second update of an absent key must conflict")
+	}
+}
+
+func TestCommitRollbackOrdering(t *testing.T) {
+	ctx := context.Background()
+
+	db := New()
+	mustSet(ctx, t, db, "key", "initial")
+
+	type op struct {
+		name string
+		fn   func(*Transaction, context.Context) error
+	}
+	commit := op{"Commit", (*Transaction).Commit}
+	rollback := op{"Rollback", (*Transaction).Rollback}
+
+	tests := []struct {
+		ops       []op
+		conflict  bool
+		wantErrs  []error
+		wantState TxState
+	}{
+		{ops: []op{commit}, wantErrs: []error{nil}, wantState: TxCommitted},
+		{ops: []op{rollback}, wantErrs: []error{nil}, wantState: TxRolledBack},
+		{ops: []op{commit, commit}, wantErrs: []error{nil, os.ErrClosed}, wantState: TxCommitted},
+		{ops: []op{commit, rollback}, wantErrs: []error{nil, nil}, wantState: TxCommitted},
+		{ops: []op{rollback, rollback}, wantErrs: []error{nil, nil}, wantState: TxRolledBack},
+		{ops: []op{rollback, commit}, wantErrs: []error{nil, os.ErrClosed}, wantState: TxRolledBack},
+		{ops: []op{commit, rollback}, conflict: true, wantErrs: []error{errConflictForTest, nil}, wantState: TxRolledBack},
+		{ops: []op{commit, commit}, conflict: true, wantErrs: []error{errConflictForTest, os.ErrClosed}, wantState: TxRolledBack},
+	}
+
+	for _, test := range tests {
+		var names []string
+		for _, op := range test.ops {
+			names = append(names, op.name)
+		}
+		name := strings.Join(names, "-")
+		if test.conflict {
+			name = "Conflicting-" + name
+		}
+
+		t.Run(name, func(t *testing.T) {
+			tx, _ := db.NewTransaction(ctx)
+			defer tx.Rollback(ctx)
+
+			if state := tx.State(); state != TxActive {
+				t.Fatalf("new transaction state is %v, want %v", state, TxActive)
+			}
+
+			mustGet(ctx, t, tx, "key")
+			if err := tx.Set(ctx, "key", strings.NewReader(name)); err != nil {
+				t.Fatal(err)
+			}
+			if test.conflict {
+				mustSet(ctx, t, db, "key", "conflict")
+			}
+
+			for i, op := range test.ops {
+				err := op.fn(tx, ctx)
+				switch want := test.wantErrs[i]; {
+				case want == nil && err != nil:
+					t.Errorf("%s: unexpected error %v", op.name, err)
+				case want == errConflictForTest && err == nil:
+					t.Errorf("%s: want a conflict error, got nil", op.name)
+				case want != nil && want != errConflictForTest && !errors.Is(err, want):
+					t.Errorf("%s: want %v, got %v", op.name, want, err)
+				}
+			}
+
+			if state := tx.State(); state != test.wantState {
+				t.Errorf("transaction state is %v, want %v", state, test.wantState)
+			}
+			if _, err := tx.Get(ctx, "key"); !errors.Is(err, os.ErrClosed) {
+				t.Errorf("Get on a closed transaction: want os.ErrClosed, got %v", err)
+			}
+			if err := tx.Set(ctx, "key", strings.NewReader("value")); !errors.Is(err, os.ErrClosed) {
+				t.Errorf("Set on a closed transaction: want os.ErrClosed, got %v", err)
+			}
+		})
+	}
+}
+
+// errConflictForTest is a placeholder for any commit conflict error in tests.
+var errConflictForTest = errors.New("conflict")
+
+func TestCopyMoveRange(t *testing.T) {
+	ctx := context.Background()
+
+	setup := func(t *testing.T) *Database {
+		db := New()
+		tx, _ := db.NewTransaction(ctx)
+		tx.Set(ctx, "old/a", strings.NewReader("a"))
+		tx.Set(ctx, "old/b", strings.NewReader("b"))
+		tx.Set(ctx, "old/c/d", strings.NewReader("d"))
+		tx.Set(ctx, "other", strings.NewReader("other"))
+		if err := tx.Commit(ctx); err != nil {
+			t.Fatal(err)
+		}
+		return db
+	}
+
+	t.Run("Copy", func(t *testing.T) {
+		db := setup(t)
+
+		tx, _ := db.NewTransaction(ctx)
+		defer tx.Rollback(ctx)
+		if n, err := tx.CopyRange(ctx, "old/", "old0", "new/"); err != nil || n != 3 {
+			t.Fatalf("CopyRange = %d, %v, want 3, nil", n, err)
+		}
+		if err := tx.Commit(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		snap, _ := db.NewSnapshot(ctx)
+		defer snap.Discard(ctx)
+		ascend, _ := readAll(ctx, t, snap, "", "")
+		want := []string{"new/a=a", "new/b=b", "new/c/d=d", "old/a=a", "old/b=b", "old/c/d=d", "other=other"}
+		if !reflect.DeepEqual(ascend, want) {
+			t.Errorf("got %v, want %v", ascend, want)
+		}
+	})
+
+	t.Run("Move", func(t *testing.T) {
+		db := setup(t)
+
+		tx, _ := db.NewTransaction(ctx)
+		defer tx.Rollback(ctx)
+		if n, err := tx.MoveRange(ctx, "old/", "old0", "new/"); err != nil || n != 3 {
+			t.Fatalf("MoveRange = %d, %v, want 3, nil", n, err)
+		}
+		if err := tx.Commit(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		snap, _ := db.NewSnapshot(ctx)
+		defer snap.Discard(ctx)
+		ascend, _ := readAll(ctx, t, snap, "", "")
+		want := []string{"new/a=a", "new/b=b", "new/c/d=d", "other=other"}
+		if !reflect.DeepEqual(ascend, want) {
+			t.Errorf("got %v, want %v", ascend, want)
+		}
+	})
+
+	t.Run("MoveConflict", func(t *testing.T) {
+		db := setup(t)
+
+		tx, _ := db.NewTransaction(ctx)
+		defer tx.Rollback(ctx)
+		if _, err := tx.MoveRange(ctx, "old/", "old0", "new/"); err != nil {
+			t.Fatal(err)
+		}
+
+		mustSet(ctx, t, db, "old/b", "updated")
+
+		if err := tx.Commit(ctx); err == nil {
+			t.Fatalf("move must conflict with a concurrent update to the source range")
+		}
+	})
+}
+
+func TestCountPhantomSafe(t *testing.T) {
+	ctx := context.Background()
+
+	db := New()
+	mustSet(ctx, t, db, "user/alice", "admin")
+
+	// Both transactions check that no other admin exists in the range before
+	// creating one. Without range tracking both would commit, because neither
+	// reads a key the other one writes.
+	countAndInsert := func(key string) (*Transaction, error) {
+		tx, _ := db.NewTransaction(ctx)
+		n, err := tx.CountPhantomSafe(ctx, "admin/", "admin0")
+		if err != nil {
+			return nil, err
+		}
+		if n != 0 {
+			t.Fatalf("CountPhantomSafe = %d, want 0", n)
+		}
+		if err := tx.Set(ctx, key, strings.NewReader("admin")); err != nil {
+			return nil, err
+		}
+		return tx, nil
+	}
+
+	tx1, err := countAndInsert("admin/bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx1.Rollback(ctx)
+	tx2, err := countAndInsert("admin/carol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx2.Rollback(ctx)
+
+	if err := tx1.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx2.Commit(ctx); err == nil {
+		t.Fatalf("phantom insert into a counted range must fail to commit")
+	}
+
+	// Count includes the transaction's own updates and excludes keys
+	// outside the range.
+	tx, _ := db.NewTransaction(ctx)
+	defer tx.Rollback(ctx)
+	tx.Set(ctx, "admin/dave", strings.NewReader("admin"))
+	tx.Delete(ctx, "admin/bob")
+	if n, err := tx.CountPhantomSafe(ctx, "admin/", "admin0"); err != nil || n != 1 {
+		t.Errorf("CountPhantomSafe = %d, %v, want 1, nil", n, err)
+	}
+	if n, err := tx.CountPhantomSafe(ctx, "", ""); err != nil || n != 2 {
+		t.Errorf("CountPhantomSafe over all keys = %d, %v, want 2, nil", n, err)
+	}
+	if _, err := tx.CountPhantomSafe(ctx, "b", "a"); !errors.Is(err, os.ErrInvalid) {
+		t.Errorf("CountPhantomSafe on an invalid range = %v, want os.ErrInvalid", err)
+	}
+
+	// Writes outside of a counted range do not conflict.
+	tx3, _ := db.NewTransaction(ctx)
+	defer tx3.Rollback(ctx)
+	if _, err := tx3.CountPhantomSafe(ctx, "admin/", "admin0"); err != nil {
+		t.Fatal(err)
+	}
+	tx3.Set(ctx, "admin/erin", strings.NewReader("admin"))
+	mustSet(ctx, t, db, "user/bob", "user")
+	if err := tx3.Commit(ctx); err != nil {
+		t.Errorf("commit with writes outside of the counted range failed: %v", err)
+	}
+}
+
+func BenchmarkTransactionScan(b *testing.B) {
+	ctx := context.Background()
+
+	db := New()
+	tx, _ := db.NewTransaction(ctx)
+	for i := 0; i < 10000; i++ {
+		tx.Set(ctx, strconv.Itoa(i), strings.NewReader("value"))
+	}
+	if err := tx.Commit(ctx); err != nil {
+		b.Fatal(err)
+	}
+
+	for _, n := range []int{10, 10000} {
+		b.Run(fmt.Sprintf("first%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				tx, _ := db.NewTransaction(ctx)
+				var err error
+				count := 0
+				for range tx.Scan(ctx, &err) {
+					if count++; count == n {
+						break
+					}
+				}
+				if err != nil {
+					b.Fatal(err)
+				}
+				tx.Rollback(ctx)
+			}
+		})
+	}
+}
+
+func TestStagingCounters(t *testing.T) {
+	ctx := context.Background()
+
+	type report struct {
+		txID  uint64
+		key   string
+		count int
+	}
+	var reports []report
+	db := New(WithStagingObserver(100, func(txID uint64, key string, count int) {
+		reports = append(reports, report{txID, key, count})
+	}))
+	mustSet(ctx, t, db, "deleted", "value")
+
+	tx, _ := db.NewTransaction(ctx)
+	defer tx.Rollback(ctx)
+	for i := 0; i < 1000; i++ {
+		tx.Set(ctx, "hot", strings.NewReader(strconv.Itoa(i)))
+	}
+	tx.Set(ctx, "cold", strings.NewReader("value"))
+	tx.Delete(ctx, "deleted")
+
+	wantStats := TxStats{Writes: 3, Stages: 1002, Overwrites: 999}
+	if stats := tx.Stats(); stats != wantStats {
+		t.Errorf("Stats = %+v, want %+v", stats, wantStats)
+	}
+
+	want := map[string]PendingWrite{
+		"cold":    {Value: []byte("value"), Stages: 1},
+		"deleted": {Deleted: true, Stages: 1},
+		"hot":     {Value: []byte("999"), Stages: 1000},
+	}
+	var keys []string
+	for key, w := range tx.PendingWrites() {
+		keys = append(keys, key)
+		if !reflect.DeepEqual(w, want[key]) {
+			t.Errorf("pending write for %q = %+v, want %+v", key, w, want[key])
+		}
+	}
+	if !reflect.DeepEqual(keys, []string{"cold", "deleted", "hot"}) {
+		t.Errorf("PendingWrites keys = %v, want sorted keys", keys)
+	}
+
+	wantReports := []report{{tx.id, "hot", 101}}
+	if !reflect.DeepEqual(reports, wantReports) {
+		t.Errorf("staging observer reports = %v, want %v", reports, wantReports)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	mv, _ := db.kvs.Load("hot")
+	if vs := mv.Values(); len(vs) != 1 || vs[0].Data() != "999" {
+		t.Errorf("committed versions for the hot key = %v, want a single version", vs)
+	}
+}
+
+func TestSetWithVersion(t *testing.T) {
+	ctx := context.Background()
+
+	db := New()
+	mustSet(ctx, t, db, "key", "v1")
+
+	snap, _ := db.NewSnapshot(ctx)
+	version, err := snap.GetVersion(ctx, "key")
+	snap.Discard(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Versions match.
+	tx, _ := db.NewTransaction(ctx)
+	defer tx.Rollback(ctx)
+	if v, err := tx.GetVersion(ctx, "key"); err != nil || v != version {
+		t.Errorf("GetVersion = %d, %v, want %d, nil", v, err, version)
+	}
+	if err := tx.SetWithVersion(ctx, "key", strings.NewReader("v2"), version); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Versions don't match.
+	tx, _ = db.NewTransaction(ctx)
+	defer tx.Rollback(ctx)
+	if err := tx.SetWithVersion(ctx, "key", strings.NewReader("v3"), version); !errors.Is(err, ErrVersionMismatch) {
+		t.Errorf("SetWithVersion with a stale version = %v, want ErrVersionMismatch", err)
+	}
+	if _, ok := tx.writes["key"]; ok {
+		t.Errorf("SetWithVersion with a stale version staged an update")
+	}
+
+	// Zero version creates missing keys only.
+	if _, err := tx.GetVersion(ctx, "new"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("GetVersion on a missing key = %v, want os.ErrNotExist", err)
+	}
+	if err := tx.SetWithVersion(ctx, "new", strings.NewReader("value"), 0); err != nil {
+		t.Errorf("SetWithVersion on a missing key with zero version = %v, want nil", err)
+	}
+	if err := tx.SetWithVersion(ctx, "key", strings.NewReader("value"), 0); !errors.Is(err, ErrVersionMismatch) {
+		t.Errorf("SetWithVersion on an existing key with zero version = %v, want ErrVersionMismatch", err)
+	}
+	tx.Rollback(ctx)
+
+	// Version check conflicts with concurrent writers.
+	tx, _ = db.NewTransaction(ctx)
+	defer tx.Rollback(ctx)
+	version, _ = tx.GetVersion(ctx, "key")
+	mustSet(ctx, t, db, "key", "concurrent")
+	if err := tx.SetWithVersion(ctx, "key", strings.NewReader("v3"), version); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(ctx); err == nil {
+		t.Errorf("SetWithVersion must fail to commit after a concurrent update")
+	}
+	snap, _ = db.NewSnapshot(ctx)
+	defer snap.Discard(ctx)
+	if got := mustGet(ctx, t, snap, "key"); got != "concurrent" {
+		t.Errorf("key = %q, want the concurrent update", got)
+	}
+}
+
+func TestDisableReadTracking(t *testing.T) {
+	ctx := context.Background()
+
+	db := New()
+	mustSet(ctx, t, db, "key1", "initial1")
+	mustSet(ctx, t, db, "key2", "initial2")
+
+	opts := TxOptions{DisableReadTracking: true}
+
+	// Reads do not conflict, so the write skew between the transactions is
+	// allowed, unlike with the default options.
+	tx1, _ := db.NewTransactionWithOptions(ctx, opts)
+	defer tx1.Rollback(ctx)
+	tx2, _ := db.NewTransactionWithOptions(ctx, opts)
+	defer tx2.Rollback(ctx)
+
+	mustGet(ctx, t, tx1, "key1")
+	tx1.Set(ctx, "key2", strings.NewReader("value2"))
+	mustGet(ctx, t, tx2, "key2")
+	tx2.Set(ctx, "key1", strings.NewReader("value1"))
+	if len(tx1.reads) != 0 || len(tx2.reads) != 0 {
+		t.Errorf("reads are tracked with read tracking disabled")
+	}
+	if err := tx1.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx2.Commit(ctx); err != nil {
+		t.Errorf("read-only dependency caused a conflict with read tracking disabled: %v", err)
+	}
+
+	// Blind writes to the same key still conflict, so updates are not lost.
+	tx1, _ = db.NewTransactionWithOptions(ctx, opts)
+	defer tx1.Rollback(ctx)
+	tx2, _ = db.NewTransactionWithOptions(ctx, opts)
+	defer tx2.Rollback(ctx)
+
+	tx1.Set(ctx, "key1", strings.NewReader("tx1"))
+	tx2.Set(ctx, "key1", strings.NewReader("tx2"))
+	if err := tx1.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx2.Commit(ctx); err == nil {
+		t.Errorf("concurrent writes to the same key must conflict with read tracking disabled")
+	}
+
+	// Creation of the same key also conflicts.
+	tx1, _ = db.NewTransactionWithOptions(ctx, opts)
+	defer tx1.Rollback(ctx)
+	tx2, _ = db.NewTransactionWithOptions(ctx, opts)
+	defer tx2.Rollback(ctx)
+
+	tx1.Set(ctx, "new", strings.NewReader("tx1"))
+	tx2.Set(ctx, "new", strings.NewReader("tx2"))
+	if err := tx1.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx2.Commit(ctx); err == nil {
+		t.Errorf("concurrent creation of the same key must conflict with read tracking disabled")
+	}
+}
+
+func TestTransactionReader(t *testing.T) {
+	ctx := context.Background()
+
+	db := New()
+	mustSet(ctx, t, db, "a", "1")
+	mustSet(ctx, t, db, "b", "2")
+	mustSet(ctx, t, db, "c", "3")
+
+	tx, _ := db.NewTransaction(ctx)
+	defer tx.Rollback(ctx)
+	tx.Set(ctx, "b", strings.NewReader("staged"))
+	tx.Delete(ctx, "c")
+	tx.Set(ctx, "d", strings.NewReader("4"))
+
+	r := tx.Reader()
+	if _, ok := r.(kv.Writer); ok {
+		t.Errorf("transaction reader must not allow updates")
+	}
+	ascend, descend := readAll(ctx, t, r, "", "")
+	if want := []string{"a=1", "b=staged", "d=4"}; !reflect.DeepEqual(ascend, want) {
+		t.Errorf("Ascend = %q, want %q", ascend, want)
+	}
+	if want := []string{"d=4", "b=staged", "a=1"}; !reflect.DeepEqual(descend, want) {
+		t.Errorf("Descend = %q, want %q", descend, want)
+	}
+
+	// Staged updates are not visible outside the transaction.
+	snap, _ := db.NewSnapshot(ctx)
+	defer snap.Discard(ctx)
+	if ascend, _ := readAll(ctx, t, snap, "", ""); !reflect.DeepEqual(ascend, []string{"a=1", "b=2", "c=3"}) {
+		t.Errorf("snapshot Ascend = %q, want the committed values", ascend)
+	}
+}
+
+func TestTransactionDiscard(t *testing.T) {
+	ctx := context.Background()
+
+	db := New()
+	tx, _ := db.NewTransaction(ctx)
+	tx.Set(ctx, "key", strings.NewReader("value"))
+	snap, _ := db.NewSnapshot(ctx)
+
+	for _, d := range []Discarder{tx, snap} {
+		if err := d.Discard(ctx); err != nil {
+			t.Errorf("Discard(%T) = %v, want nil", d, err)
+		}
+	}
+	if s := tx.State(); s != TxRolledBack {
+		t.Errorf("discarded tx state = %v, want %v", s, TxRolledBack)
+	}
+	if h := db.Health(); h.LiveTransactions != 0 || h.LiveSnapshots != 0 {
+		t.Errorf("Health = %+v, want nothing live", h)
+	}
+
+	// Discard is a no-op after commit.
+	tx, _ = db.NewTransaction(ctx)
+	tx.Set(ctx, "key", strings.NewReader("value"))
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Discard(ctx); err != nil || tx.State() != TxCommitted {
+		t.Errorf("Discard after commit = %v with state %v, want nil and %v", err, tx.State(), TxCommitted)
+	}
+}
+
+func TestReportAllConflicts(t *testing.T) {
+	ctx := context.Background()
+
+	db := New()
+	for _, k := range []string{"a", "b", "c", "d", "e"} {
+		mustSet(ctx, t, db, k, "initial")
+	}
+
+	for i := 0; i < 10; i++ {
+		tx, _ := db.NewTransactionWithOptions(ctx, TxOptions{ReportAllConflicts: true})
+		defer tx.Rollback(ctx)
+		for _, k := range []string{"e", "a", "c", "d"} {
+			mustGet(ctx, t, tx, k)
+		}
+		tx.Set(ctx, "b", strings.NewReader("tx"))
+
+		// Concurrent transactions update the read keys, in both orders, and
+		// read the updated key.
+		others := [][]string{{"e", "c"}, {"a", "e"}, {"d"}}
+		for _, keys := range others {
+			other, _ := db.NewTransaction(ctx)
+			mustGet(ctx, t, other, "b")
+			for _, k := range keys {
+				other.Set(ctx, k, strings.NewReader("other"))
+			}
+			if err := other.Commit(ctx); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		err := tx.Commit(ctx)
+		if err == nil {
+			t.Fatalf("conflicting transaction committed successfully")
+		}
+		if want := "ssi: keys [a b c d e] conflict with the committed transactions"; err.Error() != want {
+			t.Fatalf("want %q, got %q", want, err.Error())
+		}
+	}
+}
+
+func TestUndelete(t *testing.T) {
+	ctx := context.Background()
+
+	db := New()
+	mustSet(ctx, t, db, "key", "old")
+	mustSet(ctx, t, db, "key", "original")
+
+	deleteKey := func() {
+		tx, _ := db.NewTransaction(ctx)
+		defer tx.Rollback(ctx)
+		tx.Delete(ctx, "key")
+		if err := tx.Commit(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tx, _ := db.NewTransaction(ctx)
+	defer tx.Rollback(ctx)
+	if err := tx.Undelete(ctx, "key"); !errors.Is(err, ErrNotDeleted) {
+		t.Errorf("Undelete on a live key = %v, want ErrNotDeleted", err)
+	}
+	if err := tx.Undelete(ctx, "missing"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Undelete on a missing key = %v, want os.ErrNotExist", err)
+	}
+
+	// Deletion staged by the transaction itself can be undone.
+	tx.Delete(ctx, "key")
+	if err := tx.Undelete(ctx, "key"); err != nil {
+		t.Fatal(err)
+	}
+	if v := mustGet(ctx, t, tx, "key"); v != "original" {
+		t.Errorf("want original value after undoing the deletion, got %q", v)
+	}
+	tx.Rollback(ctx)
+
+	deleteKey()
+
+	// Concurrent restores of the key conflict with each other.
+	tx1, _ := db.NewTransaction(ctx)
+	defer tx1.Rollback(ctx)
+	tx2, _ := db.NewTransaction(ctx)
+	defer tx2.Rollback(ctx)
+	if err := tx1.Undelete(ctx, "key"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx2.Undelete(ctx, "key"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx1.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx2.Commit(ctx); err == nil {
+		t.Errorf("concurrent undelete of the same key must conflict")
+	}
+
+	snap, _ := db.NewSnapshot(ctx)
+	if v := mustGet(ctx, t, snap, "key"); v != "original" {
+		t.Errorf("want original value after undelete, got %q", v)
+	}
+	snap.Discard(ctx)
+
+	// Older values are compacted when the deleted key is deleted again.
+	deleteKey()
+	deleteKey()
+	tx, _ = db.NewTransaction(ctx)
+	defer tx.Rollback(ctx)
+	if err := tx.Undelete(ctx, "key"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Undelete after compaction = %v, want os.ErrNotExist", err)
+	}
+}
+
+// stalledReader blocks all reads until it is closed.
+type stalledReader chan struct{}
+
+func (r stalledReader) Read([]byte) (int, error) {
+	<-r
+	return 0, io.EOF
+}
+
+func TestDefaultTimeout(t *testing.T) {
+	ctx := context.Background()
+
+	db := New(WithDefaultTimeout(50 * time.Millisecond))
+
+	r := make(stalledReader)
+	defer close(r)
+
+	tx, _ := db.NewTransaction(ctx)
+	defer tx.Rollback(ctx)
+	if err := tx.Set(ctx, "key", r); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Set with a stalled reader = %v, want context.DeadlineExceeded", err)
+	}
+
+	// Deadline of the caller takes precedence over the default timeout.
+	long, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	slow := io.MultiReader(&delayedReader{delay: 100 * time.Millisecond}, strings.NewReader("value"))
+	if err := tx.Set(long, "key", slow); err != nil {
+		t.Errorf("Set with a caller deadline = %v, want nil", err)
+	}
+
+	// Commit waiting for the database mutex also times out.
+	db.mu.Lock()
+	err := tx.Commit(ctx)
+	db.mu.Unlock()
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Commit behind a held lock = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+// delayedReader returns io.EOF after a delay.
+type delayedReader struct {
+	delay time.Duration
+}
+
+func (r *delayedReader) Read([]byte) (int, error) {
+	time.Sleep(r.delay)
+	return 0, io.EOF
+}
+
+func TestTransactionString(t *testing.T) {
+	ctx := context.Background()
+
+	db := New()
+	mustSet(ctx, t, db, "a", "1")
+
+	tx, _ := db.NewTransaction(ctx)
+	mustGet(ctx, t, tx, "a")
+	tx.Set(ctx, "b", strings.NewReader("2"))
+	tx.Set(ctx, "c", strings.NewReader("3"))
+	want := fmt.Sprintf("Transaction{id: %d, version: 1, reads: 1, writes: 2, committed: false}", tx.ID())
+	if got := tx.String(); got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	want = fmt.Sprintf("Transaction{id: %d, version: 1, reads: 1, writes: 2, committed: true}", tx.ID())
+	if got := fmt.Sprint(tx); got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+
+	snap, _ := db.NewSnapshot(ctx)
+	defer snap.Discard(ctx)
+	if got, want := fmt.Sprint(snap), "Snapshot{id: 1, version: 2}"; got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
+
+func TestIgnoreReadConflicts(t *testing.T) {
+	ctx := context.Background()
+
+	db := New()
+	for _, k := range []string{"clock", "stats/hits", "stats/misses", "data"} {
+		mustSet(ctx, t, db, k, "initial")
+	}
+
+	opts := TxOptions{
+		IgnoreReadConflictKeys:     []string{"clock"},
+		IgnoreReadConflictPrefixes: []string{"stats/"},
+	}
+	newTx := func(readKeys ...string) *Transaction {
+		tx, _ := db.NewTransactionWithOptions(ctx, opts)
+		for _, k := range readKeys {
+			mustGet(ctx, t, tx, k)
+		}
+		tx.Set(ctx, "result", strings.NewReader("tx"))
+		return tx
+	}
+
+	tx := newTx("clock", "stats/hits")
+	mustSet(ctx, t, db, "clock", "tick")
+	mustSet(ctx, t, db, "stats/hits", "1")
+
+	// Ignored keys are still read from the transaction's snapshot.
+	if got := mustGet(ctx, t, tx, "clock"); got != "initial" {
+		t.Errorf("ignored key: want the snapshot value, got %q", got)
+	}
+	if n := len(tx.reads); n != 0 {
+		t.Errorf("ignored keys: want an empty read set, got %d keys", n)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		t.Errorf("updates to the ignored keys: want no conflict, got %v", err)
+	}
+
+	// Other keys are still tracked.
+	tx = newTx("clock", "data")
+	mustSet(ctx, t, db, "data", "updated")
+	if err := tx.Commit(ctx); err == nil {
+		t.Errorf("update to a tracked key: want a conflict")
+	}
+}
+
+func TestSetIfAbsent(t *testing.T) {
+	ctx := context.Background()
+
+	db := New()
+	mustSet(ctx, t, db, "key", "v1")
+
+	tx, _ := db.NewTransaction(ctx)
+	defer tx.Rollback(ctx)
+	if ok, err := tx.SetIfAbsent(ctx, "key", strings.NewReader("v2")); err != nil || ok {
+		t.Errorf("SetIfAbsent on an existing key = %t, %v, want false, nil", ok, err)
+	}
+	if ok, err := tx.SetIfAbsent(ctx, "new", strings.NewReader("v1")); err != nil || !ok {
+		t.Errorf("SetIfAbsent on a missing key = %t, %v, want true, nil", ok, err)
+	}
+	// Keys staged by the transaction exist in its view.
+	if ok, err := tx.SetIfAbsent(ctx, "new", strings.NewReader("v2")); err != nil || ok {
+		t.Errorf("SetIfAbsent on a staged key = %t, %v, want false, nil", ok, err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	snap, _ := db.NewSnapshot(ctx)
+	defer snap.Discard(ctx)
+	if got := mustGet(ctx, t, snap, "key"); got != "v1" {
+		t.Errorf("existing key: want v1, got %q", got)
+	}
+	if got := mustGet(ctx, t, snap, "new"); got != "v1" {
+		t.Errorf("new key: want v1, got %q", got)
+	}
+
+	// Concurrent inserts of the same key conflict.
+	tx1, _ := db.NewTransaction(ctx)
+	defer tx1.Rollback(ctx)
+	tx2, _ := db.NewTransaction(ctx)
+	defer tx2.Rollback(ctx)
+	if ok, err := tx1.SetIfAbsent(ctx, "race", strings.NewReader("tx1")); err != nil || !ok {
+		t.Fatalf("tx1 SetIfAbsent = %t, %v, want true, nil", ok, err)
+	}
+	if ok, err := tx2.SetIfAbsent(ctx, "race", strings.NewReader("tx2")); err != nil || !ok {
+		t.Fatalf("tx2 SetIfAbsent = %t, %v, want true, nil", ok, err)
+	}
+	if err := tx1.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx2.Commit(ctx); err == nil {
+		t.Errorf("concurrent SetIfAbsent of the same key must conflict")
+	}
+	snap, _ = db.NewSnapshot(ctx)
+	defer snap.Discard(ctx)
+	if got := mustGet(ctx, t, snap, "race"); got != "tx1" {
+		t.Errorf("want tx1, got %q", got)
+	}
+}
+
+func TestLockRange(t *testing.T) {
+	ctx := context.Background()
+
+	db := New()
+	mustSet(ctx, t, db, "b", "v1")
+	mustSet(ctx, t, db, "d", "v1")
+
+	// Locks do not need the read tracking.
+	tx1, _ := db.NewTransactionWithOptions(ctx, TxOptions{DisableReadTracking: true})
+	defer tx1.Rollback(ctx)
+	if err := tx1.LockRange(ctx, "a", "m"); err != nil {
+		t.Fatal(err)
+	}
+	tx1.Set(ctx, "z", strings.NewReader("v1"))
+	mustSet(ctx, t, db, "c", "v1")
+	if err := tx1.Commit(ctx); err == nil || !strings.Contains(err.Error(), "locked ranges") {
+		t.Errorf("commit after a phantom insert in the locked range must fail with a locked range conflict, got %v", err)
+	}
+
+	// Updates and deletions of the existing keys are not conflicts, nor are
+	// the inserts outside the locked range.
+	tx2, _ := db.NewTransaction(ctx)
+	defer tx2.Rollback(ctx)
+	tx2.LockRange(ctx, "a", "m")
+	tx2.Set(ctx, "z", strings.NewReader("v2"))
+	other, _ := db.NewTransaction(ctx)
+	other.Set(ctx, "b", strings.NewReader("v2"))
+	other.Delete(ctx, "d")
+	other.Set(ctx, "n", strings.NewReader("v1"))
+	if err := other.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx2.Commit(ctx); err != nil {
+		t.Errorf("commit after updates to the existing keys: %v", err)
+	}
+
+	// Recreating a deleted key is an insert.
+	tx3, _ := db.NewTransaction(ctx)
+	defer tx3.Rollback(ctx)
+	tx3.LockRange(ctx, "d", "")
+	tx3.Set(ctx, "z", strings.NewReader("v3"))
+	mustSet(ctx, t, db, "d", "v2")
+	if err := tx3.Commit(ctx); err == nil || !strings.Contains(err.Error(), "locked ranges") {
+		t.Errorf("commit after recreating a deleted key in the locked range must fail with a locked range conflict, got %v", err)
+	}
+
+	// Group transactions detect the inserts committed before their creation.
+	g, _ := db.NewTransactionGroup(ctx)
+	defer g.Discard(ctx)
+	mustSet(ctx, t, db, "e", "v1")
+	tx4, _ := g.NewTransaction(ctx)
+	defer tx4.Rollback(ctx)
+	tx4.LockRange(ctx, "e", "f")
+	tx4.Set(ctx, "z", strings.NewReader("v4"))
+	if err := tx4.Commit(ctx); err == nil || !strings.Contains(err.Error(), "locked ranges") {
+		t.Errorf("group commit after a phantom insert in the locked range must fail with a locked range conflict, got %v", err)
+	}
+
+	tx5, _ := db.NewTransaction(ctx)
+	defer tx5.Rollback(ctx)
+	if err := tx5.LockRange(ctx, "m", "a"); !errors.Is(err, os.ErrInvalid) {
+		t.Errorf("LockRange with an invalid range: want os.ErrInvalid, got %v", err)
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+)
+
+// CommitInto applies the updates staged by the transaction into the other
+// database instead of the transaction's own database, which remains
+// unchanged. It is meant for staging changes in a scratch database and
+// promoting them into another database.
+//
+// The transaction's reads, including the scanned ranges, are validated
+// against its own database: commit fails if any of them were updated after
+// the transaction was created. Updates are then committed into the other
+// database through a new transaction that is subject to the other database's
+// key checks and write-write conflict checks. Concurrent transactions of the
+// other database that have read the updated keys fail to commit afterwards.
+//
+// Limitations: the operation is not atomic across the two databases. Updates
+// committed to the transaction's own database after its reads are validated
+// are not detected, and the values in the other database are overwritten
+// irrespective of what was read from the transaction's own database.
+//
+// Transaction is closed irrespective of the result, as with Commit, unless the
+// target database is nil. Committing into the transaction's own database is
+// same as Commit.
+func (t *Transaction) CommitInto(ctx context.Context, other *Database) error {
+	if err := t.check(); err != nil {
+		return err
+	}
+	if other == nil {
+		return fmt.Errorf("target database cannot be nil: %w", os.ErrInvalid)
+	}
+	if other == t.db {
+		return t.Commit(ctx)
+	}
+	db := t.db
+	ctx, cancel := db.withDefaultTimeout(ctx)
+	defer cancel()
+
+	if err := t.commitInto(ctx, other); err != nil {
+		t.state = TxRolledBack
+		if ctx.Err() != nil {
+			// See Commit for removing the transaction in the background.
+			go db.closeTransaction(t)
+			return err
+		}
+		db.closeTransaction(t)
+		return err
+	}
+	t.state = TxCommitted
+	db.closeTransaction(t)
+	return nil
+}
+
+func (t *Transaction) commitInto(ctx context.Context, other *Database) error {
+	if err := t.validateReads(ctx); err != nil {
+		return err
+	}
+	if len(t.writes) == 0 {
+		return nil
+	}
+
+	otx, err := other.NewTransactionWithOptions(ctx, TxOptions{DisableReadTracking: true})
+	if err != nil {
+		return err
+	}
+	defer otx.Rollback(ctx)
+
+	for _, key := range slices.Sorted(maps.Keys(t.writes)) {
+		if t.db.hiddenKey(key) {
+			// Chunks are copied as part of their values, which are chunked
+			// again as per the other database's options.
+			continue
+		}
+		if value := t.writes[key]; value == nil {
+			err = otx.Delete(ctx, key)
+		} else if m, ok := t.db.parseManifest(*value); ok {
+			var chunks []string
+			if chunks, err = t.chunks(key, m); err == nil {
+				err = otx.Set(ctx, key, newChunkReader(m, chunks))
+			}
+		} else {
+			err = otx.Set(ctx, key, strings.NewReader(*value))
+		}
+		if err != nil {
+			return fmt.Errorf("could not stage key %q into the target database: %w", key, err)
+		}
+	}
+	return otx.Commit(ctx)
+}
+
+// validateReads returns a non-nil error if any key read by the transaction is
+// updated in its database after the transaction was created.
+func (t *Transaction) validateReads(ctx context.Context) error {
+	db := t.db
+	if err := db.mu.LockContext(ctx); err != nil {
+		return fmt.Errorf("could not lock the database: %w", err)
+	}
+	defer db.mu.Unlock()
+
+	if t.aborted.Load() {
+		return fmt.Errorf("tx %d: %w", t.id, ErrAborted)
+	}
+	if ks := staleReads(db, t); len(ks) > 0 {
+		return fmt.Errorf("ssi: keys %v read were updated after this tx has begun", ks)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCommitInto(t *testing.T) {
+	ctx := context.Background()
+
+	scratch := New()
+	mustSet(ctx, t, scratch, "config/a", "1")
+	target := New()
+	mustSet(ctx, t, target, "config/a", "old")
+	mustSet(ctx, t, target, "config/b", "old")
+
+	tx, _ := scratch.NewTransaction(ctx)
+	defer tx.Rollback(ctx)
+	mustGet(ctx, t, tx, "config/a")
+	tx.Set(ctx, "config/a", strings.NewReader("2"))
+	tx.Delete(ctx, "config/b")
+	tx.Set(ctx, "config/c", strings.NewReader("3"))
+
+	// A target transaction that has read an updated key conflicts with the
+	// transfer.
+	reader, _ := target.NewTransaction(ctx)
+	defer reader.Rollback(ctx)
+	mustGet(ctx, t, reader, "config/b")
+	reader.Set(ctx, "other", strings.NewReader("value"))
+
+	if err := tx.CommitInto(ctx, target); err != nil {
+		t.Fatal(err)
+	}
+	if s := tx.State(); s != TxCommitted {
+		t.Errorf("tx state = %v, want %v", s, TxCommitted)
+	}
+	if err := reader.Commit(ctx); err == nil {
+		t.Errorf("target transaction reading a transferred key must fail to commit")
+	}
+
+	snap, _ := target.NewSnapshot(ctx)
+	defer snap.Discard(ctx)
+	if got, _ := readAll(ctx, t, snap, "", ""); !reflect.DeepEqual(got, []string{"config/a=2", "config/c=3"}) {
+		t.Errorf("target database = %q, want the transferred updates", got)
+	}
+	ssnap, _ := scratch.NewSnapshot(ctx)
+	defer ssnap.Discard(ctx)
+	if got, _ := readAll(ctx, t, ssnap, "", ""); !reflect.DeepEqual(got, []string{"config/a=1"}) {
+		t.Errorf("scratch database = %q, want no changes", got)
+	}
+	if h := scratch.Health(); h.LiveTransactions != 0 {
+		t.Errorf("scratch Health = %+v, want no live transactions", h)
+	}
+}
+
+func TestCommitIntoConflicts(t *testing.T) {
+	ctx := context.Background()
+
+	scratch := New()
+	mustSet(ctx, t, scratch, "key", "initial")
+
+	// Reads are validated against the transaction's own database.
+	target := New()
+	tx, _ := scratch.NewTransaction(ctx)
+	defer tx.Rollback(ctx)
+	mustGet(ctx, t, tx, "key")
+	tx.Set(ctx, "result", strings.NewReader("value"))
+	mustSet(ctx, t, scratch, "key", "updated")
+	if err := tx.CommitInto(ctx, target); err == nil {
+		t.Errorf("CommitInto with a stale read must fail")
+	}
+	if s := tx.State(); s != TxRolledBack {
+		t.Errorf("tx state = %v, want %v", s, TxRolledBack)
+	}
+	if h := target.Health(); h.LiveTransactions != 0 {
+		t.Errorf("target Health = %+v, want no live transactions", h)
+	}
+
+	// Scanned ranges are validated as well.
+	tx, _ = scratch.NewTransaction(ctx)
+	defer tx.Rollback(ctx)
+	if _, err := tx.CountPhantomSafe(ctx, "a", "z"); err != nil {
+		t.Fatal(err)
+	}
+	tx.Set(ctx, "result", strings.NewReader("value"))
+	mustSet(ctx, t, scratch, "new", "value")
+	if err := tx.CommitInto(ctx, target); err == nil {
+		t.Errorf("CommitInto with a phantom in the scanned range must fail")
+	}
+
+	// Keys are checked by the target database.
+	strict := New(WithKeySchema(KeySchema{RequiredPrefix("allowed/")}))
+	tx, _ = scratch.NewTransaction(ctx)
+	defer tx.Rollback(ctx)
+	tx.Set(ctx, "result", strings.NewReader("value"))
+	if err := tx.CommitInto(ctx, strict); !errors.Is(err, os.ErrInvalid) {
+		t.Errorf("CommitInto with a key rejected by the target = %v, want os.ErrInvalid", err)
+	}
+
+	tx, _ = scratch.NewTransaction(ctx)
+	if err := tx.CommitInto(ctx, nil); !errors.Is(err, os.ErrInvalid) {
+		t.Errorf("CommitInto a nil database = %v, want os.ErrInvalid", err)
+	}
+	tx.Rollback(ctx)
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+)
+
+// recordRead adds the key to the read set of a snapshot that tracks reads.
+func (s *Snapshot) recordRead(key string) {
+	s.readMu.Lock()
+	defer s.readMu.Unlock()
+
+	s.reads[key] = struct{}{}
+}
+
+// recordRange adds the range to the scanned ranges of a snapshot that tracks
+// reads.
+func (s *Snapshot) recordRange(begin, end string) {
+	if !s.trackReads {
+		return
+	}
+	s.readMu.Lock()
+	defer s.readMu.Unlock()
+
+	s.scanRanges = append(s.scanRanges, Range{Begin: begin, End: end})
+}
+
+// Upgrade creates a read-write transaction at the snapshot's version, so that
+// a reader can start writing without losing the consistency with what it has
+// read. Keys and ranges read through the snapshot so far are seeded into the
+// transaction's read set and validated for conflicts when it commits, as if
+// they were read by the transaction. Snapshots created without the TrackReads
+// option have unknown reads, so their transactions conflict with every
+// update committed after the snapshot's version.
+//
+// Snapshot remains usable and must be discarded separately. Reads through the
+// snapshot after the upgrade are not tracked by the transaction. Layered
+// snapshots cannot be upgraded.
+func (s *Snapshot) Upgrade(ctx context.Context) (*Transaction, error) {
+	if s.db == nil || s.overlay != nil {
+		return nil, fmt.Errorf("snapshot cannot be upgraded: %w", os.ErrInvalid)
+	}
+	d := s.db
+
+	var keys []string
+	ranges := []Range{{}}
+	if s.trackReads {
+		s.readMu.Lock()
+		keys = make([]string, 0, len(s.reads))
+		for key := range s.reads {
+			keys = append(keys, key)
+		}
+		ranges = slices.Clone(s.scanRanges)
+		s.readMu.Unlock()
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// Expired snapshots are removed under the database mutex, so the
+	// transaction protects the snapshot version if it has not expired yet.
+	if err := s.check(); err != nil {
+		return nil, err
+	}
+
+	d.hook(hookNewTransaction)
+	t := d.newTransactionLocked(s.snapshotVersion, TxOptions{})
+	t.pinned = true
+	for _, key := range keys {
+		if mv, ok := d.kvs.Load(key); ok {
+			t.reads[key], _ = mv.Fetch(t.snapshotVersion)
+		} else {
+			t.reads[key] = nil
+		}
+	}
+	t.numReads.Store(int64(len(t.reads)))
+	t.scanRanges = ranges
+	return t, nil
+}
@@ -0,0 +1,80 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestSnapshotUpgrade(t *testing.T) {
+	ctx := context.Background()
+
+	db := New()
+	mustSet(ctx, t, db, "a", "1")
+	mustSet(ctx, t, db, "b", "1")
+
+	tracked := SnapshotOptions{TrackReads: true}
+
+	// upgrade reads the key "a" and the range [r, s) in a snapshot, lets the
+	// update function change the database and commits a write from the
+	// upgraded transaction.
+	upgrade := func(opts SnapshotOptions, update func()) error {
+		snap, _ := db.NewSnapshotWithOptions(ctx, opts)
+		defer snap.Discard(ctx)
+
+		mustGet(ctx, t, snap, "a")
+		var err error
+		for range snap.Ascend(ctx, "r", "s", &err) {
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		update()
+		tx, err := snap.Upgrade(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer tx.Rollback(ctx)
+
+		// Transaction reads the database state at the snapshot's version.
+		if got := mustGet(ctx, t, tx, "a"); got != "1" {
+			t.Errorf("upgraded tx reads a = %q, want 1", got)
+		}
+		tx.Set(ctx, "result", strings.NewReader("value"))
+		return tx.Commit(ctx)
+	}
+
+	if err := upgrade(tracked, func() { mustSet(ctx, t, db, "b", "2") }); err != nil {
+		t.Errorf("unrelated update conflicts with the upgraded tx: %v", err)
+	}
+	if err := upgrade(tracked, func() { mustSet(ctx, t, db, "a", "2") }); err == nil {
+		t.Errorf("update to a key read by the snapshot must conflict")
+	}
+	mustSet(ctx, t, db, "a", "1")
+	if err := upgrade(tracked, func() { mustSet(ctx, t, db, "r5", "new") }); err == nil {
+		t.Errorf("new key in a range scanned by the snapshot must conflict")
+	}
+
+	// Reads are unknown for snapshots without read tracking.
+	if err := upgrade(SnapshotOptions{}, func() {}); err != nil {
+		t.Errorf("upgraded tx without any concurrent updates = %v, want nil", err)
+	}
+	if err := upgrade(SnapshotOptions{}, func() { mustSet(ctx, t, db, "b", "3") }); err == nil {
+		t.Errorf("any update must conflict with a snapshot upgraded without read tracking")
+	}
+
+	// Concurrent updates after the upgrade are detected as well.
+	snap, _ := db.NewSnapshotWithOptions(ctx, tracked)
+	defer snap.Discard(ctx)
+	mustGet(ctx, t, snap, "a")
+	tx, _ := snap.Upgrade(ctx)
+	defer tx.Rollback(ctx)
+	mustSet(ctx, t, db, "a", "4")
+	tx.Set(ctx, "result", strings.NewReader("value"))
+	if err := tx.Commit(ctx); err == nil {
+		t.Errorf("concurrent update to a key read by the snapshot must conflict")
+	}
+}
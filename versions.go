@@ -0,0 +1,56 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"context"
+	"fmt"
+	"os"
+)
+
+// VersionInfo describes a retained value of a key, without its data.
+type VersionInfo struct {
+	// Version is the commit version of the value.
+	Version int64
+
+	// Deleted is true if the key is deleted at the version.
+	Deleted bool
+}
+
+// Versions returns the commit versions of all retained values of the key, in
+// the increasing version order, including the deletions. Only the versions
+// visible to new snapshots are reported, so versions being applied by the
+// concurrent commits are not included. Older versions are removed by the
+// compaction when no live snapshot or transaction can read them.
+//
+// It is meant for validating the cached values cheaply, because the values
+// are not copied. Returns os.ErrNotExist if the key has no retained values.
+// Overlay databases only report the versions committed to the overlay.
+func (d *Database) Versions(ctx context.Context, key string) ([]VersionInfo, error) {
+	if err := d.checkKeySize(key); err != nil {
+		return nil, err
+	}
+	if err := d.checkPoisoned(); err != nil {
+		return nil, err
+	}
+
+	// Multi-values are replaced, not modified, by the commits, so the loaded
+	// multi-value is a consistent copy.
+	maxVersion := d.maxCommitVersion.Load()
+	mv, ok := d.loadKey(key)
+	if !ok || d.hiddenKey(key) {
+		return nil, fmt.Errorf("key %q: %w", key, os.ErrNotExist)
+	}
+
+	var infos []VersionInfo
+	for _, v := range mv.Values() {
+		if v.Version() > maxVersion {
+			break
+		}
+		infos = append(infos, VersionInfo{Version: v.Version(), Deleted: v.IsDeleted()})
+	}
+	if len(infos) == 0 {
+		return nil, fmt.Errorf("key %q: %w", key, os.ErrNotExist)
+	}
+	return infos, nil
+}
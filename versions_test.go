@@ -0,0 +1,59 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestVersions(t *testing.T) {
+	ctx := context.Background()
+
+	db := New()
+	if _, err := db.Versions(ctx, "key"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Versions of a missing key = %v, want os.ErrNotExist", err)
+	}
+
+	// Snapshot retains all versions of the key.
+	mustSet(ctx, t, db, "other", "1") // 1
+	snap, _ := db.NewSnapshot(ctx)
+	defer snap.Discard(ctx)
+
+	mustSet(ctx, t, db, "key", "1") // 2
+	mustSet(ctx, t, db, "key", "2") // 3
+	tx, _ := db.NewTransaction(ctx)
+	tx.Delete(ctx, "key")
+	if err := tx.Commit(ctx); err != nil { // 4
+		t.Fatal(err)
+	}
+	got, err := db.Versions(ctx, "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []VersionInfo{{Version: 2}, {Version: 3}, {Version: 4, Deleted: true}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Versions = %v, want %v", got, want)
+	}
+
+	// Compaction removes the versions that are no longer readable, but retains
+	// the deletion visible to the snapshots at the previous version.
+	snap.Discard(ctx)
+	mustSet(ctx, t, db, "key", "3") // 5
+	got, err = db.Versions(ctx, "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []VersionInfo{{Version: 4, Deleted: true}, {Version: 5}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Versions after compaction = %v, want %v", got, want)
+	}
+	if got, err := db.Versions(ctx, "other"); err != nil || !reflect.DeepEqual(got, []VersionInfo{{Version: 1}}) {
+		t.Errorf("Versions(other) = %v, %v, want [{1 false}]", got, err)
+	}
+	if _, err := db.Versions(ctx, ""); !errors.Is(err, os.ErrInvalid) {
+		t.Errorf("Versions of an empty key = %v, want os.ErrInvalid", err)
+	}
+}
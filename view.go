@@ -0,0 +1,60 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"context"
+
+	"github.com/visvasity/kvmemdb/mvcc"
+)
+
+// ViewOptions holds the options for creating a snapshot view of a
+// transaction. Zero value holds the default options.
+type ViewOptions struct {
+	// StagedWrites, when true, makes the updates staged by the transaction at
+	// the creation of the view visible through the view. Updates staged after
+	// the view is created are not visible.
+	StagedWrites bool
+}
+
+// SnapshotView returns a read-only snapshot of the database at the
+// transaction's snapshot version. Reads through the snapshot are not recorded
+// in the read set of the transaction, so they are never validated for
+// conflicts, which makes it suitable for large, weakly consistent scans in
+// the middle of a transaction.
+//
+// Returned snapshot must be discarded independently of the transaction and
+// remains usable after the transaction is committed or rolled back.
+func (t *Transaction) SnapshotView(ctx context.Context) (*Snapshot, error) {
+	return t.SnapshotViewWithOptions(ctx, ViewOptions{})
+}
+
+// SnapshotViewWithOptions is similar to SnapshotView, but with non-default
+// options. Staged values visible through the view report the version
+// following the transaction's snapshot version through GetVersion.
+func (t *Transaction) SnapshotViewWithOptions(ctx context.Context, opts ViewOptions) (*Snapshot, error) {
+	if err := t.check(); err != nil {
+		return nil, err
+	}
+
+	var staged map[string]*mvcc.Value
+	if opts.StagedWrites {
+		staged = make(map[string]*mvcc.Value, len(t.writes))
+		for key, value := range t.writes {
+			v := mvcc.NewValue(t.snapshotVersion + 1)
+			if value == nil {
+				v.Delete()
+			} else {
+				v.SetData(*value)
+			}
+			staged[key] = v
+		}
+	}
+
+	t.db.mu.Lock()
+	defer t.db.mu.Unlock()
+
+	s := t.db.newSnapshotLocked(t.snapshotVersion)
+	s.staged = staged
+	return s, nil
+}
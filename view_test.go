@@ -0,0 +1,80 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package kvmemdb
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSnapshotView(t *testing.T) {
+	ctx := context.Background()
+
+	db := New()
+	for i := 0; i < 100; i++ {
+		mustSet(ctx, t, db, fmt.Sprintf("key%03d", i), "value")
+	}
+
+	tx, _ := db.NewTransaction(ctx)
+	defer tx.Rollback(ctx)
+	mustGet(ctx, t, tx, "key000")
+	tx.Set(ctx, "key001", strings.NewReader("staged"))
+	tx.Delete(ctx, "key002")
+	tx.Set(ctx, "new", strings.NewReader("staged"))
+
+	// Concurrent commits after the transaction are not visible to the view.
+	mustSet(ctx, t, db, "key003", "concurrent")
+
+	view, err := tx.SnapshotView(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ascend, _ := readAll(ctx, t, view, "", "")
+	if len(ascend) != 100 || ascend[1] != "key001=value" || ascend[2] != "key002=value" || ascend[3] != "key003=value" {
+		t.Errorf("view without staged writes: got %q", ascend[:4])
+	}
+	if n := len(tx.reads); n != 1 {
+		t.Errorf("scan through the view: want 1 key in the read set, got %d", n)
+	}
+	view.Discard(ctx)
+
+	view, err = tx.SnapshotViewWithOptions(ctx, ViewOptions{StagedWrites: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer view.Discard(ctx)
+
+	// Updates staged after the view is created are not visible.
+	tx.Set(ctx, "key004", strings.NewReader("late"))
+
+	ascend, _ = readAll(ctx, t, view, "key000", "key005")
+	if want := []string{"key000=value", "key001=staged", "key003=value", "key004=value"}; !reflect.DeepEqual(ascend, want) {
+		t.Errorf("view with staged writes: want %q, got %q", want, ascend)
+	}
+	if got := mustGet(ctx, t, view, "new"); got != "staged" {
+		t.Errorf("view with staged writes: want new key, got %q", got)
+	}
+	if version, err := view.GetVersion(ctx, "new"); err != nil || version != tx.snapshotVersion+1 {
+		t.Errorf("GetVersion(new) = %d, %v, want %d", version, err, tx.snapshotVersion+1)
+	}
+	if n := len(tx.reads); n != 1 {
+		t.Errorf("reads through the view: want 1 key in the read set, got %d", n)
+	}
+
+	// Transaction is not affected by discarding the view and the view
+	// remains usable after the transaction is closed.
+	view2, _ := tx.SnapshotView(ctx)
+	view2.Discard(ctx)
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := mustGet(ctx, t, view, "key000"); got != "value" {
+		t.Errorf("view after commit: got %q", got)
+	}
+	if _, err := tx.SnapshotView(ctx); err == nil {
+		t.Errorf("SnapshotView on a committed transaction: want an error")
+	}
+}